@@ -0,0 +1,41 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// keys of the functionConfig in the ResourceContext sent to a function
+const (
+	// FunctionConfigVarsKey holds an object with the non KRM variables of the function
+	FunctionConfigVarsKey = "vars"
+	// FunctionConfigInputKey holds the input of the function as defined in the controller config
+	FunctionConfigInputKey = "input"
+	// FunctionConfigContextKey holds the FunctionContext
+	FunctionConfigContextKey = "context"
+)
+
+// FunctionContext provides the context of the controller in which the function is executed
+type FunctionContext struct {
+	Controller  string            `json:"controller"`
+	ForResource ResourceReference `json:"forResource"`
+	VertexName  string            `json:"vertexName"`
+	// RangeIndex is the index of the item when the function is executed in a range
+	RangeIndex *int `json:"rangeIndex,omitempty"`
+}
+
+type ResourceReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
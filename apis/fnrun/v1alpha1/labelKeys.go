@@ -21,6 +21,24 @@ const (
 	Domain            = "fnrun.io"
 	FunctionLabelKey  = "fnrun.io/image"
 	ConfigMapLabelKey = "fnrun.io/configmap"
+	// OwnerUIDLabelKey tracks the owner of the children which cannot have an
+	// owner reference, i.e. children in another namespace than the owner and
+	// cluster scoped children of a namespaced owner
+	OwnerUIDLabelKey = "fnrun.io/owner-uid"
+	// annotations
+	// MemoizeExcludeAnnotationKey lists the images of a controller configmap,
+	// comma separated, whose executions are never memoized
+	MemoizeExcludeAnnotationKey = "fnrun.io/memoize-exclude"
+	// ReplicasAnnotationKey sets the replicas of the deployments of the images
+	// of a controller configmap, default 2. With a single replica a drain of
+	// the node leaves the image without a pod.
+	ReplicasAnnotationKey = "fnrun.io/replicas"
+	// DigestAnnotationKey holds the digest of the image on the pod template
+	DigestAnnotationKey = "fnrun.io/digest"
+	// the owner of a child tracked with the OwnerUIDLabelKey
+	OwnerGVKAnnotationKey       = "fnrun.io/owner-gvk"
+	OwnerNamespaceAnnotationKey = "fnrun.io/owner-namespace"
+	OwnerNameAnnotationKey      = "fnrun.io/owner-name"
 
 	// pod spec
 	InitContainerName     = "copy-fnwrapper-server"
@@ -28,9 +46,26 @@ const (
 	VolumeName            = VolumeMountPath
 	VolumeMountPath       = "fnwrapper-server-tools"
 	WrapperServerBin      = "fnwrapper-server"
+	HealthProbeBin        = "grpc_health_probe" // shipped next to the fnwrapper server, probes the pods with mTLS
 	DefaultFnWrapperImage = "europe-docker.pkg.dev/srlinux/eu.gcr.io/fnwrapper-image:latest"
+	// the certificates of the fnwrapper server when mTLS is enabled
+	TLSVolumeName         = "fnrun-tls"
+	TLSMountPath          = "/etc/fnrun/tls"
 	FnGRPCServerPort      = 9446
 	FnProxyGRPCServerPort = 9445
 	// env
 	EnvFnWrapperImage = "FN-WRAPPER-IMAGE"
+	// grpc metadata
+	// CacheHeaderKey is set by the proxy in the response header of an
+	// execution served from the memoization cache
+	CacheHeaderKey = "fnrun-cache"
+	CacheHit       = "hit"
+	// grpc status
+	// ReasonClientNotReady is the reason of the unavailable status the proxy
+	// returns when no client of the image got ready within the request
+	// deadline, the status holds the delay after which to retry
+	ReasonClientNotReady = "CLIENT_NOT_READY"
+	// ReasonCircuitOpen is the reason of the unavailable status the proxy
+	// returns without calling the image while its circuit breaker is open
+	ReasonCircuitOpen = "CIRCUIT_OPEN"
 )
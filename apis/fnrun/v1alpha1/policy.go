@@ -0,0 +1,51 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// NamespaceForResource in the namespaces of the OutputPolicy allows the
+// namespace of the for-resource
+const NamespaceForResource = "$forResource"
+
+// OutputPolicy restricts the final output a controller applies, it is
+// defined in the outputPolicy key of the controller configmap
+type OutputPolicy struct {
+	// Resources allowlists the output GVKs, every GVK is allowed when empty
+	Resources []ResourcePolicy `json:"resources,omitempty"`
+	// Namespaces allowlists the namespaces of the namespaced output, every
+	// namespace is allowed when empty. The cluster scoped resources are not
+	// restricted by the namespaces, they are allowlisted by the Resources.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// MaxObjects limits the amount of objects in the output
+	MaxObjects *int `json:"maxObjects,omitempty"`
+	// ChildNamespace is the namespace of the namespaced children without
+	// namespace of a cluster scoped for-resource, the children of a
+	// namespaced for-resource default to the namespace of the for-resource
+	ChildNamespace string `json:"childNamespace,omitempty"`
+	// ServiceAccount is impersonated to apply the output, so the RBAC of the
+	// service account in the namespace of the controller configmap applies
+	// rather than the one of the manager. The manager needs the impersonate
+	// verb on serviceaccounts, granted by its cluster role.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+}
+
+type ResourcePolicy struct {
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the resource, * allows every kind of the apiVersion
+	Kind string `json:"kind"`
+	// MaxObjects limits the amount of objects of the GVK in the output
+	MaxObjects *int `json:"maxObjects,omitempty"`
+}
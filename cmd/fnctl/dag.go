@@ -0,0 +1,55 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/ctrlcfg"
+	"github.com/fnrunner/fnruntime/pkg/exec/dagexport"
+)
+
+// runDAG renders the runtime DAGs of a ControllerConfig (or a ConfigMap
+// holding one) in dot, mermaid or json format
+func runDAG(args []string) error {
+	fs := flag.NewFlagSet("dag", flag.ExitOnError)
+	file := fs.String("file", "", "ControllerConfig or ConfigMap file")
+	format := fs.String("format", string(dagexport.FormatDOT), "output format: dot, mermaid or json")
+	out := fs.String("output", "", "output file, defaults to stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("a controller config file is required")
+	}
+
+	cc, err := ctrlcfg.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	b, err := dagexport.ExportExecutionContext(cc.CeCtx).Render(dagexport.Format(*format))
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(*out, b, 0644)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/ctrlcfg"
+	"github.com/fnrunner/fnruntime/pkg/exec/replay"
+	"github.com/fnrunner/fnruntime/pkg/exec/result"
+	"github.com/fnrunner/fnruntime/pkg/exec/schema"
+	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// runReplay re-runs a recorded bundle offline and prints the final outputs,
+// optionally with another controller config to bisect config changes
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	bundle := fs.String("bundle", "", "replay bundle file")
+	file := fs.String("file", "", "ControllerConfig or ConfigMap file replacing the recorded controller config")
+	schemas := fs.String("schemas", "", "directory with openapi v3 documents to validate the final outputs against")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *bundle == "" {
+		return fmt.Errorf("a replay bundle is required")
+	}
+
+	b, err := replay.ReadFile(*bundle)
+	if err != nil {
+		return err
+	}
+	var spec *ctrlcfgv1alpha1.ControllerConfigSpec
+	if *file != "" {
+		cc, err := ctrlcfg.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		spec = cc.Spec
+	}
+	var validator schema.Validator
+	if *schemas != "" {
+		r, err := schema.NewFileResolver(*schemas)
+		if err != nil {
+			return err
+		}
+		validator = schema.NewValidator(r)
+	}
+	res, err := replay.Run(context.Background(), b, spec)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range res.Result.Get() {
+		ri, ok := v.(*result.ResultInfo)
+		if !ok || ri.Success {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "vertex %s failed: %s\n", ri.VertexName, ri.Reason)
+	}
+	for _, d := range res.Diverged {
+		fmt.Fprintf(os.Stderr, "diverged from recording: %s\n", d)
+	}
+	for _, o := range res.Output.GetFinalOutput() {
+		y, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("---\n%s", string(y))
+	}
+	if validator != nil {
+		return validator.Validate(res.Output.GetFinalOutput())
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/fnmanager/admin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// runStatus queries the admin service on the proxy of a running fn manager
+// and prints its controllers, their images and the pods serving them. With
+// mTLS the manager issues the admin client certificate in the admin
+// certificate secret, e.g. fnrun-ca-admin, whose keys are read from the
+// tls-dir:
+//
+//	kubectl get secret fnrun-ca-admin -o go-template='{{index .data "tls.crt" | base64decode}}' > tls.crt
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	address := fs.String("address", fmt.Sprintf("localhost:%d", fnrunv1alpha1.FnProxyGRPCServerPort), "proxy address of the fn manager")
+	controller := fs.String("controller", "", "only show the controller")
+	output := fs.String("output", "table", "output format: table or json")
+	tlsDir := fs.String("tls-dir", "", "directory with the ca.crt, tls.crt and tls.key of the admin certificate secret of the fn manager (<ca-secret>-admin) when it runs with mTLS")
+	caCert := fs.String("ca-cert", "", "CA certificate of the proxy when it runs with mTLS, ca.crt of the admin certificate secret")
+	cert := fs.String("cert", "", "client certificate issued by the CA with the common name fnrun-admin, tls.crt of the admin certificate secret")
+	key := fs.String("key", "", "key of the client certificate, tls.key of the admin certificate secret")
+	serverName := fs.String("server-name", "localhost", "name the proxy certificate is verified against")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch *output {
+	case "table", "json":
+	default:
+		return fmt.Errorf("unsupported output format: %s", *output)
+	}
+
+	if *tlsDir != "" {
+		*caCert = filepath.Join(*tlsDir, "ca.crt")
+		*cert = filepath.Join(*tlsDir, "tls.crt")
+		*key = filepath.Join(*tlsDir, "tls.key")
+	}
+	creds := insecure.NewCredentials()
+	if *caCert != "" || *cert != "" || *key != "" {
+		tlsConfig, err := clientTLSConfig(*caCert, *cert, *key, *serverName)
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, *address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	controllers, err := admin.NewClient(conn).GetControllers(ctx, *controller)
+	if err != nil {
+		return err
+	}
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(controllers)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTROLLER\tCONFIGMAP\tRUNNING\tIMAGE\tKIND\tDIGEST\tREADY\tPODS\tERROR")
+	for _, c := range controllers {
+		cm := "-"
+		if c.ConfigMap != nil {
+			cm = fmt.Sprintf("%s/%s@%s", c.ConfigMap.Namespace, c.ConfigMap.Name, c.ConfigMap.ResourceVersion)
+		}
+		if len(c.Images) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t%t\t-\t-\t-\t-\t-\t%s\n", c.Name, cm, c.Running, c.Error)
+			continue
+		}
+		for _, img := range c.Images {
+			pods := make([]string, 0, len(img.Pods))
+			for _, p := range img.Pods {
+				pod := fmt.Sprintf("%s(%s)", p.Name, p.IP)
+				if !p.Ready {
+					pod += "!"
+				}
+				pods = append(pods, pod)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%s\t%t\t%s\t%s\n",
+				c.Name, cm, c.Running, img.Name, img.Kind, orNone(img.Digest), img.Ready, orNone(strings.Join(pods, ",")), c.Error)
+		}
+	}
+	return w.Flush()
+}
+
+func clientTLSConfig(caCert, cert, key, serverName string) (*tls.Config, error) {
+	if caCert == "" || cert == "" || key == "" {
+		return nil, errors.New("ca-cert, cert and key are required for mTLS")
+	}
+	ca, err := os.ReadFile(caCert)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %s", caCert)
+	}
+	kp, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{kp},
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
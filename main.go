@@ -24,6 +24,10 @@ import (
 
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
 	"github.com/fnrunner/fnruntime/pkg/fnmanager/fnmanager"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/fnproxy"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/memo"
+	"github.com/fnrunner/fnruntime/pkg/imgmanager/backend"
 	"github.com/pkg/profile"
 	"go.uber.org/zap/zapcore"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -32,6 +36,7 @@ import (
 
 func main() {
 	var metricsAddr string
+	var debugAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var debug bool
@@ -40,8 +45,34 @@ func main() {
 	var pollInterval time.Duration
 	var domain string
 	var uniqueID string
+	var traceEnabled bool
+	var traceMaxPerResource int
+	var traceConfigMap bool
+	var recordDir string
+	var memoize bool
+	var memoizeMaxEntries int
+	var memoizeMaxBytes int
+	var memoizeTTL time.Duration
+	var validateOutputs bool
+	var balancePolicy string
+	var proxyMaxRPC int64
+	var proxyMaxRPCPerController int64
+	var proxyMaxRPCPerImage int64
+	var proxyTimeout time.Duration
+	var mtls bool
+	var caSecret string
+	var proxyInMemory bool
+	var maxMsgSize int
+	var breakerEnabled bool
+	var breakerConsecutiveFailures int
+	var breakerErrorRate float64
+	var breakerOpenTimeout time.Duration
+	var defaultBackend string
+	var imageBackends string
+	var execDir string
 	//var configMap string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&debugAddr, "debug-bind-address", "127.0.0.1:8082", "The address the unauthenticated debug endpoints bind to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
@@ -52,6 +83,31 @@ func main() {
 	flag.BoolVar(&profiler, "profile", false, "Enable profiler")
 	flag.StringVar(&domain, "domain", fnrunv1alpha1.Domain, "The domain the operator belongs to")
 	flag.StringVar(&uniqueID, "unique-id", "abcd1234", "The unique id used in leader election")
+	flag.BoolVar(&traceEnabled, "trace", false, "Record the execution traces of the resources, every execution snapshots the input and output of its vertices")
+	flag.IntVar(&traceMaxPerResource, "trace-max-per-resource", 10, "The max amount of execution traces kept per resource")
+	flag.BoolVar(&traceConfigMap, "trace-configmap", false, "Persist the execution traces in a configmap per controller, it enables --trace")
+	flag.StringVar(&recordDir, "record-dir", "", "Record a replay bundle of every run in the directory keeping the newest 1000, disabled when empty")
+	flag.BoolVar(&memoize, "memoize", false, "Memoize the function executions with identical image digest and input")
+	flag.IntVar(&memoizeMaxEntries, "memoize-max-entries", 1000, "The max amount of memoized function executions")
+	flag.IntVar(&memoizeMaxBytes, "memoize-max-bytes", 64*1024*1024, "The max size of the memoized function executions")
+	flag.DurationVar(&memoizeTTL, "memoize-ttl", 0, "The time after which a memoized function execution expires, no expiry when 0")
+	flag.BoolVar(&validateOutputs, "validate-outputs", false, "Validate the final output against the openapi v3 schemas of the API server before it is applied")
+	flag.StringVar(&balancePolicy, "balance-policy", "roundRobin", "The policy balancing the function requests over the replicas of an image: roundRobin or leastOutstanding")
+	flag.Int64Var(&proxyMaxRPC, "proxy-max-rpc", 600, "The max amount of in-flight function RPCs of the proxy")
+	flag.Int64Var(&proxyMaxRPCPerController, "proxy-max-rpc-per-controller", 0, "The max amount of in-flight function RPCs of a controller, unlimited when 0")
+	flag.Int64Var(&proxyMaxRPCPerImage, "proxy-max-rpc-per-image", 0, "The max amount of in-flight function RPCs of an image of a controller, unlimited when 0")
+	flag.DurationVar(&proxyTimeout, "proxy-timeout", 1*time.Minute, "The deadline of a function RPC of the proxy")
+	flag.BoolVar(&mtls, "mtls", false, "Enable mTLS between the reconcilers, the proxy and the function pods. The pods of service images serve grpc themselves without the fnwrapper, they get no certificate and are called in plaintext")
+	flag.StringVar(&caSecret, "ca-secret", "fnrun-ca", "The secret of the CA issuing the mTLS certificates, created when it does not exist")
+	flag.BoolVar(&proxyInMemory, "proxy-in-memory", false, "Connect the reconcilers to the proxy in memory instead of over the loopback network")
+	flag.IntVar(&maxMsgSize, "max-msg-size", fnmanager.DefaultMaxMsgSize, "The max size of a grpc message between the reconcilers, the proxy and the function pods, it bounds the size of a resource context as the proxy executes a function with the resource context in a single message to the function pod")
+	flag.BoolVar(&breakerEnabled, "breaker", false, "Enable a circuit breaker per function image in the proxy")
+	flag.IntVar(&breakerConsecutiveFailures, "breaker-consecutive-failures", 5, "The amount of consecutive failed requests which open the circuit breaker of an image")
+	flag.Float64Var(&breakerErrorRate, "breaker-error-rate", 0, "The rate of failed requests within a minute which opens the circuit breaker of an image, disabled when 0")
+	flag.DurationVar(&breakerOpenTimeout, "breaker-open-timeout", 30*time.Second, "The time the circuit breaker of an image stays open before trial requests are let through")
+	flag.StringVar(&defaultBackend, "backend", string(backend.KindPod), "The backend running the function images: pod, exec or container")
+	flag.StringVar(&imageBackends, "image-backends", "", "The backend per function image as a comma separated list of <image>=<backend>")
+	flag.StringVar(&execDir, "exec-dir", "", "The dir of the executables of the exec backend named after the image repository, looked up in the PATH when empty")
 	//flag.StringVar(&configMap, "configMap", "configmap", "The configmap the controller uses")
 	opts := zap.Options{
 		Development: true,
@@ -81,15 +137,64 @@ func main() {
 
 	ctx := ctrl.SetupSignalHandler()
 
+	var memoization *memo.Config
+	if memoize {
+		memoization = &memo.Config{
+			MaxEntries: memoizeMaxEntries,
+			MaxBytes:   memoizeMaxBytes,
+			TTL:        memoizeTTL,
+		}
+	}
+
+	var circuitBreaker *breaker.Config
+	if breakerEnabled {
+		circuitBreaker = &breaker.Config{
+			ConsecutiveFailures: breakerConsecutiveFailures,
+			ErrorRate:           breakerErrorRate,
+			OpenTimeout:         breakerOpenTimeout,
+		}
+	}
+
+	images, err := backend.ParseImages(imageBackends)
+	if err != nil {
+		l.Error(err, "invalid image backends")
+		os.Exit(1)
+	}
+	backends := &backend.Selection{
+		Default: backend.Kind(defaultBackend),
+		Images:  images,
+		ExecDir: execDir,
+	}
+
 	mgr, err := fnmanager.New(&fnmanager.Config{
 		Domain:               domain,
 		UniqueID:             uniqueID,
 		ConfigMaps:           configMaps,
 		MetricAddress:        metricsAddr,
+		DebugAddress:         debugAddr,
 		ProbeAddress:         probeAddr,
 		EnableLeaderElection: enableLeaderElection,
 		Concurrency:          concurrency,
 		PollInterval:         pollInterval,
+		Trace:                traceEnabled,
+		TraceMaxPerResource:  traceMaxPerResource,
+		TraceConfigMap:       traceConfigMap,
+		RecordDir:            recordDir,
+		Memoization:          memoization,
+		Breaker:              circuitBreaker,
+		Backends:             backends,
+		ValidateOutputs:      validateOutputs,
+		BalancePolicy:        balancePolicy,
+		ProxyLimits: &fnproxy.Limits{
+			MaxRPC:              proxyMaxRPC,
+			MaxRPCPerController: proxyMaxRPCPerController,
+			MaxRPCPerImage:      proxyMaxRPCPerImage,
+			Timeout:             proxyTimeout,
+		},
+		MTLS:          mtls,
+		CASecret:      caSecret,
+		InMemoryProxy: proxyInMemory,
+		MaxMsgSize:    maxMsgSize,
 	})
 	if err != nil {
 		l.Error(err, "cannot create fn manager")
@@ -0,0 +1,439 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	defaultCAValidity    = 5 * 365 * 24 * time.Hour
+	defaultCertValidity  = 30 * 24 * time.Hour
+	defaultCheckInterval = time.Hour
+	// certificates are valid from before they are issued to tolerate clock skew
+	clockSkew = time.Hour
+
+	caCommonName = "fnrun-ca"
+	// CABundleKey is the key of the trusted CA certificates in the CA secret
+	// and the certificate secrets
+	CABundleKey = "ca.crt"
+)
+
+// Usage is the extended key usage of an issued certificate
+type Usage int
+
+const (
+	UsageServer Usage = 1 << iota
+	UsageClient
+)
+
+func (r Usage) extKeyUsages() []x509.ExtKeyUsage {
+	usages := []x509.ExtKeyUsage{}
+	if r&UsageServer != 0 {
+		usages = append(usages, x509.ExtKeyUsageServerAuth)
+	}
+	if r&UsageClient != 0 {
+		usages = append(usages, x509.ExtKeyUsageClientAuth)
+	}
+	return usages
+}
+
+type Config struct {
+	Client kubernetes.Interface
+	// Namespace and SecretName of the secret holding the CA
+	Namespace  string
+	SecretName string
+	// CAValidity and CertValidity are the lifetimes of the CA and of the
+	// certificates it issues
+	CAValidity   time.Duration
+	CertValidity time.Duration
+}
+
+// Authority is a self-managed CA stored in a secret. The CA and the
+// certificates it issues are renewed after 2/3 of their lifetime, the
+// previous CA stays trusted until it expires.
+type Authority interface {
+	// Load loads the CA from the secret, it creates the secret when it does
+	// not exist and rotates the CA when it expires soon
+	Load(ctx context.Context) error
+	// Start loads the CA periodically until the ctx is done
+	Start(ctx context.Context) error
+	// CABundle returns the pem encoded trusted CA certificates
+	CABundle() []byte
+	// Issue issues a certificate for server and/or client authentication, a
+	// certificate handed to user code is issued for one usage only
+	Issue(commonName string, dnsNames []string, ips []net.IP, usage Usage) (*KeyPair, error)
+	// NeedsRenewal returns true if the pem encoded certificate is not issued
+	// by the current CA or is within its renewal window
+	NeedsRenewal(certPEM []byte) bool
+	// ServerTLSConfig returns a server tls config with a certificate issued
+	// by the CA, it requires a client certificate issued by the CA with one of
+	// the clientCommonNames
+	ServerTLSConfig(dnsNames []string, ips []net.IP, clientCommonNames []string) *tls.Config
+	// ClientTLSConfig returns a client tls config with a certificate issued
+	// by the CA, it verifies the server certificate against the CA with the
+	// serverName or the dialed host if empty
+	ClientTLSConfig(commonName, serverName string) *tls.Config
+}
+
+// KeyPair is a pem encoded certificate and private key
+type KeyPair struct {
+	Cert []byte
+	Key  []byte
+}
+
+func New(cfg *Config) Authority {
+	r := &authority{
+		client:       cfg.Client,
+		namespace:    cfg.Namespace,
+		secretName:   cfg.SecretName,
+		caValidity:   cfg.CAValidity,
+		certValidity: cfg.CertValidity,
+		leaves:       map[string]*leaf{},
+		l:            ctrl.Log.WithName("certificate authority"),
+	}
+	if r.caValidity <= 0 {
+		r.caValidity = defaultCAValidity
+	}
+	if r.certValidity <= 0 {
+		r.certValidity = defaultCertValidity
+	}
+	return r
+}
+
+type authority struct {
+	client       kubernetes.Interface
+	namespace    string
+	secretName   string
+	caValidity   time.Duration
+	certValidity time.Duration
+	l            logr.Logger
+
+	m      sync.RWMutex
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+	bundle []byte
+	pool   *x509.CertPool
+	// leaves caches the client certificates per common name
+	leaves map[string]*leaf
+}
+
+func (r *authority) Start(ctx context.Context) error {
+	ticker := time.NewTicker(defaultCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Load(ctx); err != nil {
+				r.l.Error(err, "cannot load CA")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (r *authority) Load(ctx context.Context) error {
+	secret, err := r.client.CoreV1().Secrets(r.namespace).Get(ctx, r.secretName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		return r.create(ctx)
+	}
+	cert, key, err := parseKeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("cannot parse CA secret %s/%s: %s", r.namespace, r.secretName, err.Error())
+	}
+	if renewalDue(cert, time.Now()) {
+		return r.rotate(ctx, secret, cert)
+	}
+	return r.set(cert, key, secret.Data[CABundleKey])
+}
+
+// create creates the CA secret, the CA of another replica wins the race
+func (r *authority) create(ctx context.Context) error {
+	cert, key, kp, err := r.newCA()
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: r.namespace,
+			Name:      r.secretName,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       kp.Cert,
+			corev1.TLSPrivateKeyKey: kp.Key,
+			CABundleKey:             kp.Cert,
+		},
+	}
+	if _, err := r.client.CoreV1().Secrets(r.namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return r.Load(ctx)
+		}
+		return err
+	}
+	r.l.Info("CA created", "secret", r.secretName, "notAfter", cert.NotAfter)
+	return r.set(cert, key, kp.Cert)
+}
+
+// rotate replaces the CA, the bundle keeps the previous CAs until they expire
+func (r *authority) rotate(ctx context.Context, secret *corev1.Secret, old *x509.Certificate) error {
+	cert, key, kp, err := r.newCA()
+	if err != nil {
+		return err
+	}
+	bundle := append([]byte{}, kp.Cert...)
+	now := time.Now()
+	for _, c := range parseCerts(secret.Data[CABundleKey]) {
+		if now.Before(c.NotAfter) {
+			bundle = append(bundle, encodeCert(c.Raw)...)
+		}
+	}
+	secret = secret.DeepCopy()
+	secret.Data[corev1.TLSCertKey] = kp.Cert
+	secret.Data[corev1.TLSPrivateKeyKey] = kp.Key
+	secret.Data[CABundleKey] = bundle
+	if _, err := r.client.CoreV1().Secrets(r.namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return r.Load(ctx)
+		}
+		return err
+	}
+	r.l.Info("CA rotated", "secret", r.secretName, "previousNotAfter", old.NotAfter, "notAfter", cert.NotAfter)
+	return r.set(cert, key, bundle)
+}
+
+func (r *authority) set(cert *x509.Certificate, key crypto.Signer, bundle []byte) error {
+	if len(bundle) == 0 {
+		bundle = encodeCert(cert.Raw)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return fmt.Errorf("CA secret %s/%s has no valid CA bundle", r.namespace, r.secretName)
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.caCert = cert
+	r.caKey = key
+	r.bundle = bundle
+	r.pool = pool
+	return nil
+}
+
+func (r *authority) CABundle() []byte {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.bundle
+}
+
+func (r *authority) getPool() *x509.CertPool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.pool
+}
+
+func (r *authority) newCA() (*x509.Certificate, crypto.Signer, *KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: caCommonName},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(r.caValidity),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	kp, err := encodeKeyPair(der, key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cert, key, kp, nil
+}
+
+func (r *authority) Issue(commonName string, dnsNames []string, ips []net.IP, usage Usage) (*KeyPair, error) {
+	r.m.RLock()
+	caCert, caKey := r.caCert, r.caKey
+	r.m.RUnlock()
+	if caCert == nil {
+		return nil, fmt.Errorf("cannot issue certificate, CA not loaded")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	notAfter := now.Add(r.certValidity)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+		NotBefore:    now.Add(-clockSkew),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  usage.extKeyUsages(),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, err
+	}
+	return encodeKeyPair(der, key)
+}
+
+func (r *authority) NeedsRenewal(certPEM []byte) bool {
+	certs := parseCerts(certPEM)
+	if len(certs) == 0 {
+		return true
+	}
+	return r.needsRenewal(certs[0])
+}
+
+func (r *authority) needsRenewal(cert *x509.Certificate) bool {
+	r.m.RLock()
+	caCert := r.caCert
+	r.m.RUnlock()
+	if caCert == nil || cert.CheckSignatureFrom(caCert) != nil {
+		return true
+	}
+	return renewalDue(cert, time.Now())
+}
+
+// renewalDue returns true after 2/3 of the lifetime of the certificate
+func renewalDue(cert *x509.Certificate, now time.Time) bool {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return now.After(cert.NotBefore.Add(lifetime * 2 / 3))
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encodeKeyPair(der []byte, key *ecdsa.PrivateKey) (*KeyPair, error) {
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		Cert: encodeCert(der),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}),
+	}, nil
+}
+
+func parseKeyPair(certPEM, keyPEM []byte) (*x509.Certificate, crypto.Signer, error) {
+	certs := parseCerts(certPEM)
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("no certificate")
+	}
+	b, _ := pem.Decode(keyPEM)
+	if b == nil {
+		return nil, nil, fmt.Errorf("no private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return certs[0], signer, nil
+}
+
+func parseCerts(b []byte) []*x509.Certificate {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		certs = append(certs, c)
+	}
+}
+
+// EqualBundle returns true if the pem encoded bundles hold the same
+// certificates
+func EqualBundle(a, b []byte) bool {
+	x, y := parseCerts(a), parseCerts(b)
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !bytes.Equal(x[i].Raw, y[i].Raw) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testNamespace = "fnrun-system"
+	testSecret    = "fnrun-ca"
+)
+
+func newTestAuthority(t *testing.T, client kubernetes.Interface, caValidity time.Duration) *authority {
+	t.Helper()
+	r := New(&Config{
+		Client:     client,
+		Namespace:  testNamespace,
+		SecretName: testSecret,
+		CAValidity: caValidity,
+	}).(*authority)
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func getSecret(t *testing.T, client kubernetes.Interface) *corev1.Secret {
+	t.Helper()
+	s, err := client.CoreV1().Secrets(testNamespace).Get(context.Background(), testSecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func parseCert(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+	certs := parseCerts(certPEM)
+	if len(certs) != 1 {
+		t.Fatalf("expected a certificate, got %d", len(certs))
+	}
+	return certs[0]
+}
+
+func TestLoadCreate(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	a := newTestAuthority(t, client, 0)
+
+	s := getSecret(t, client)
+	ca := parseCert(t, s.Data[corev1.TLSCertKey])
+	if !ca.IsCA || ca.Subject.CommonName != caCommonName {
+		t.Errorf("expected a CA certificate, got %+v", ca.Subject)
+	}
+	if !EqualBundle(s.Data[CABundleKey], a.CABundle()) {
+		t.Error("expected the bundle of the secret to be loaded")
+	}
+
+	// another replica loads the same CA
+	b := newTestAuthority(t, client, 0)
+	if !EqualBundle(a.CABundle(), b.CABundle()) {
+		t.Error("expected the replicas to share the CA")
+	}
+	kp, err := a.Issue("client", nil, nil, UsageClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.NeedsRenewal(kp.Cert) {
+		t.Error("expected a certificate of the shared CA not to need renewal")
+	}
+}
+
+func TestLoadRotate(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	// the lifetime includes the clock skew, so the CA is due for renewal
+	// right away
+	a := newTestAuthority(t, client, time.Minute)
+	oldCA := parseCert(t, getSecret(t, client).Data[corev1.TLSCertKey])
+	kp, err := a.Issue("client", nil, nil, UsageClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldLeaf := parseCert(t, kp.Cert)
+	if !oldLeaf.NotAfter.Equal(oldCA.NotAfter) {
+		t.Errorf("expected the certificate not to outlive the CA, got %s, CA %s", oldLeaf.NotAfter, oldCA.NotAfter)
+	}
+
+	if err := a.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s := getSecret(t, client)
+	newCA := parseCert(t, s.Data[corev1.TLSCertKey])
+	if newCA.Equal(oldCA) {
+		t.Fatal("expected the CA to be rotated")
+	}
+	// the previous CA stays trusted until it expires
+	bundle := parseCerts(a.CABundle())
+	if len(bundle) != 2 || !bundle[0].Equal(newCA) || !bundle[1].Equal(oldCA) {
+		t.Fatalf("expected the new and the previous CA in the bundle, got %d certificates", len(bundle))
+	}
+	if _, err := oldLeaf.Verify(x509.VerifyOptions{Roots: a.getPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("expected a certificate of the previous CA to be trusted, got %v", err)
+	}
+	// the certificates of the previous CA are issued again
+	if !a.NeedsRenewal(kp.Cert) {
+		t.Error("expected a certificate of the previous CA to need renewal")
+	}
+}
+
+func TestLoadRotateExpired(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	expired := New(&Config{CAValidity: time.Nanosecond}).(*authority)
+	_, _, kp, err := expired.newCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Secrets(testNamespace).Create(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testSecret},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       kp.Cert,
+			corev1.TLSPrivateKeyKey: kp.Key,
+			CABundleKey:             kp.Cert,
+		},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAuthority(t, client, 0)
+	// the expired CA is dropped from the bundle
+	bundle := parseCerts(a.CABundle())
+	if len(bundle) != 1 || !bundle[0].Equal(parseCert(t, getSecret(t, client).Data[corev1.TLSCertKey])) {
+		t.Errorf("expected only the new CA in the bundle, got %d certificates", len(bundle))
+	}
+}
+
+func TestIssueUsage(t *testing.T) {
+	a := newTestAuthority(t, fake.NewSimpleClientset(), 0)
+	cases := map[string]struct {
+		usage    Usage
+		expected []x509.ExtKeyUsage
+	}{
+		"Server": {usage: UsageServer, expected: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}},
+		"Client": {usage: UsageClient, expected: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}},
+		"Both":   {usage: UsageServer | UsageClient, expected: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kp, err := a.Issue("name", []string{"name.ns.svc"}, nil, tc.usage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert := parseCert(t, kp.Cert)
+			if len(cert.ExtKeyUsage) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, cert.ExtKeyUsage)
+			}
+			for i := range tc.expected {
+				if cert.ExtKeyUsage[i] != tc.expected[i] {
+					t.Errorf("expected %v, got %v", tc.expected, cert.ExtKeyUsage)
+				}
+			}
+			if cert.IsCA {
+				t.Error("expected a leaf certificate")
+			}
+		})
+	}
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	a := newTestAuthority(t, fake.NewSimpleClientset(), 0)
+	other := newTestAuthority(t, fake.NewSimpleClientset(), 0)
+	kp, err := a.Issue("client", nil, nil, UsageClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKp, err := other.Issue("client", nil, nil, UsageClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]struct {
+		cert     []byte
+		expected bool
+	}{
+		"Valid":    {cert: kp.Cert},
+		"Empty":    {expected: true},
+		"Invalid":  {cert: []byte("not a certificate"), expected: true},
+		"OtherCA":  {cert: otherKp.Cert, expected: true},
+		"CACert":   {cert: encodeCert(a.caCert.Raw)},
+		"Rotating": {cert: a.CABundle()},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := a.NeedsRenewal(tc.cert); got != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+	if !renewalDue(&x509.Certificate{NotBefore: time.Unix(0, 0), NotAfter: time.Unix(3, 0)}, time.Unix(3, 0)) {
+		t.Error("expected the renewal after 2/3 of the lifetime")
+	}
+	if renewalDue(&x509.Certificate{NotBefore: time.Unix(0, 0), NotAfter: time.Unix(3, 0)}, time.Unix(1, 0)) {
+		t.Error("expected no renewal before 2/3 of the lifetime")
+	}
+}
+
+// handshake runs a tls handshake between the client and the server config
+func handshake(t *testing.T, serverCfg, clientCfg *tls.Config) error {
+	t.Helper()
+	sc, cc := net.Pipe()
+	defer sc.Close()
+	defer cc.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		s := tls.Server(sc, serverCfg)
+		errCh <- s.Handshake()
+		// unblock the client waiting for the server
+		s.Close()
+	}()
+	cerr := tls.Client(cc, clientCfg).Handshake()
+	// with tls 1.3 the client is done before the server verified its
+	// certificate, unblock the server sending its alert
+	cc.Close()
+	serr := <-errCh
+	if serr != nil {
+		return serr
+	}
+	return cerr
+}
+
+func TestTLSConfig(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	a := newTestAuthority(t, client, 0)
+	serverCfg := a.ServerTLSConfig([]string{"localhost"}, []net.IP{net.IPv4(127, 0, 0, 1)}, []string{"allowed"})
+
+	cases := map[string]struct {
+		clientCfg *tls.Config
+		ok        bool
+	}{
+		"Allowed":      {clientCfg: a.ClientTLSConfig("allowed", "localhost"), ok: true},
+		"NotAllowed":   {clientCfg: a.ClientTLSConfig("other", "localhost")},
+		"ServerName":   {clientCfg: a.ClientTLSConfig("allowed", "other")},
+		"OtherCA":      {clientCfg: newTestAuthority(t, fake.NewSimpleClientset(), 0).ClientTLSConfig("allowed", "localhost")},
+		"NoClientCert": {clientCfg: &tls.Config{InsecureSkipVerify: true}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := handshake(t, serverCfg, tc.clientCfg)
+			if tc.ok && err != nil {
+				t.Errorf("expected a handshake, got %v", err)
+			}
+			if !tc.ok && err == nil {
+				t.Error("expected the handshake to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// leaf is a certificate issued in memory, it is issued again when it is due
+// for renewal or the CA rotated
+type leaf struct {
+	a          *authority
+	commonName string
+	dnsNames   []string
+	ips        []net.IP
+	usage      Usage
+
+	m    sync.Mutex
+	cert *tls.Certificate
+}
+
+func (r *leaf) get() (*tls.Certificate, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.cert != nil && !r.a.needsRenewal(r.cert.Leaf) {
+		return r.cert, nil
+	}
+	kp, err := r.a.Issue(r.commonName, r.dnsNames, r.ips, r.usage)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(kp.Cert, kp.Key)
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	r.cert = &cert
+	return r.cert, nil
+}
+
+func (r *authority) ServerTLSConfig(dnsNames []string, ips []net.IP, clientCommonNames []string) *tls.Config {
+	commonName := caCommonName
+	if len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	}
+	l := &leaf{a: r, commonName: commonName, dnsNames: dnsNames, ips: ips, usage: UsageServer}
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return l.get()
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+		// the client CAs are resolved per connection since the CA rotates
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				NextProtos:     []string{"h2"},
+				GetCertificate: getCertificate,
+				ClientAuth:     tls.RequireAndVerifyClientCert,
+				ClientCAs:      r.getPool(),
+				// every certificate issued by the CA is verified, only the
+				// allowed common names are accepted as clients
+				VerifyConnection: func(cs tls.ConnectionState) error {
+					if len(cs.PeerCertificates) == 0 {
+						return fmt.Errorf("no client certificate")
+					}
+					cn := cs.PeerCertificates[0].Subject.CommonName
+					for _, allowed := range clientCommonNames {
+						if cn == allowed {
+							return nil
+						}
+					}
+					return fmt.Errorf("client %s not allowed", cn)
+				},
+			}, nil
+		},
+	}
+}
+
+func (r *authority) ClientTLSConfig(commonName, serverName string) *tls.Config {
+	r.m.Lock()
+	l, ok := r.leaves[commonName]
+	if !ok {
+		l = &leaf{a: r, commonName: commonName, usage: UsageClient}
+		r.leaves[commonName] = l
+	}
+	r.m.Unlock()
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return l.get()
+		},
+		// the server is verified in VerifyConnection against the CA bundle
+		// at the time of the handshake since the CA rotates
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return fmt.Errorf("no server certificate")
+			}
+			name := serverName
+			if name == "" {
+				name = cs.ServerName
+			}
+			opts := x509.VerifyOptions{
+				DNSName:       name,
+				Roots:         r.getPool(),
+				Intermediates: x509.NewCertPool(),
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}
+			for _, c := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(c)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}
+}
@@ -19,10 +19,14 @@ package eventhandler
 import (
 	"context"
 
+	"github.com/fnrunner/fnruntime/pkg/ctrlr/controllers/scope"
 	"github.com/fnrunner/fnruntime/pkg/exec/builder"
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/fnrunner/fnutils/pkg/meta"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -41,6 +45,10 @@ type Config struct {
 	RootVertexName string
 	GVK            *schema.GroupVersionKind
 	DAG            rtdag.RuntimeDAG
+	// FnClients are the clients to the fn proxy of the container functions
+	FnClients *clients.Clients
+	// Services resolve the conditioned outputs of the watch pipeline
+	Services service.Services
 }
 
 func New(c *Config) handler.EventHandler {
@@ -58,6 +66,8 @@ func New(c *Config) handler.EventHandler {
 		rootVertexName: c.RootVertexName,
 		gvk:            c.GVK,
 		d:              c.DAG,
+		fnClients:      c.FnClients,
+		services:       c.Services,
 		l:              ctrl.Log.WithName("fnrun eventhandler"),
 	}
 }
@@ -69,6 +79,8 @@ type eventhandler struct {
 	rootVertexName string
 	gvk            *schema.GroupVersionKind
 	d              rtdag.RuntimeDAG
+	fnClients      *clients.Clients
+	services       service.Services
 
 	l logr.Logger
 }
@@ -107,16 +119,22 @@ func (r *eventhandler) add(obj runtime.Object, queue adder) {
 		return
 	}
 
-	namespace := u.GetNamespace()
-	if u.GetNamespace() == "" {
-		namespace = "default"
+	// the namespace is empty for cluster scoped resources
+	namespaced, err := scope.IsNamespaced(r.client.RESTMapper(), u.GroupVersionKind())
+	if err != nil {
+		r.l.Error(err, "cannot get the scope", "gvk", u.GroupVersionKind())
+		return
+	}
+	if namespaced && u.GetNamespace() == "" {
+		r.l.Info("namespaced resource without namespace", "gvk", u.GroupVersionKind(), "name", u.GetName())
+		return
 	}
 
 	o := output.New()
 	result := result.New()
 	e := builder.New(&builder.Config{
 		Name:           u.GetName(),
-		Namespace:      namespace,
+		Namespace:      u.GetNamespace(),
 		ControllerName: r.controllerName,
 		Data:           x,
 		Client:         r.client,
@@ -124,6 +142,10 @@ func (r *eventhandler) add(obj runtime.Object, queue adder) {
 		DAG:            r.d,
 		Output:         o,
 		Result:         result,
+		FnClients:      r.fnClients,
+		Services:       r.services,
+		// the watch pipelines are apply pipelines
+		Operation: ccsyntax.OperationApply,
 	})
 
 	e.Run(context.TODO())
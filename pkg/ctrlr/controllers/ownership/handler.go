@@ -0,0 +1,46 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ownership
+
+import (
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnutils/pkg/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// EnqueueRequestForLabelOwner enqueues the owner of the children which are
+// tracked with the owner labels, it complements handler.EnqueueRequestForOwner
+func EnqueueRequestForLabelOwner(ownerGVK schema.GroupVersionKind) handler.EventHandler {
+	gvk := meta.GVKToString(&ownerGVK)
+	return handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+		if _, ok := o.GetLabels()[fnrunv1alpha1.OwnerUIDLabelKey]; !ok {
+			return nil
+		}
+		annotations := o.GetAnnotations()
+		if annotations[fnrunv1alpha1.OwnerGVKAnnotationKey] != gvk {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{
+			Namespace: annotations[fnrunv1alpha1.OwnerNamespaceAnnotationKey],
+			Name:      annotations[fnrunv1alpha1.OwnerNameAnnotationKey],
+		}}}
+	})
+}
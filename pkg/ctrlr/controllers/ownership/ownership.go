@@ -0,0 +1,103 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ownership
+
+import (
+	"context"
+	"fmt"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/ctrlr/controllers/scope"
+	"github.com/fnrunner/fnutils/pkg/meta"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SetOwner makes the owner the controller of the child. An owner reference
+// is used when the API server allows it, otherwise the owner is tracked in
+// the labels and annotations of the child.
+func SetOwner(mapper apimeta.RESTMapper, owner, child *unstructured.Unstructured) error {
+	ownerNamespaced, err := scope.IsNamespaced(mapper, owner.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	childNamespaced, err := scope.IsNamespaced(mapper, child.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	// a namespaced owner can only own children in its own namespace
+	if ownerNamespaced && (!childNamespaced || child.GetNamespace() != owner.GetNamespace()) {
+		setOwnerLabels(owner, child)
+		return nil
+	}
+	return setControllerReference(owner, child)
+}
+
+func setControllerReference(owner, child *unstructured.Unstructured) error {
+	ref := *metav1.NewControllerRef(owner, owner.GroupVersionKind())
+	// blocking the deletion of the owner requires update on the finalizers of
+	// the owner when the OwnerReferencesPermissionEnforcement admission plugin
+	// is enabled, which neither the manager nor an impersonated service
+	// account is granted for arbitrary for-resources
+	ref.BlockOwnerDeletion = nil
+	refs := []metav1.OwnerReference{}
+	for _, r := range child.GetOwnerReferences() {
+		if r.UID == ref.UID {
+			continue
+		}
+		if r.Controller != nil && *r.Controller {
+			return fmt.Errorf("%s %s is already controlled by %s %s", child.GetKind(), child.GetName(), r.Kind, r.Name)
+		}
+		refs = append(refs, r)
+	}
+	child.SetOwnerReferences(append(refs, ref))
+	return nil
+}
+
+func setOwnerLabels(owner, child *unstructured.Unstructured) {
+	gvk := owner.GroupVersionKind()
+	meta.AddLabels(child, map[string]string{
+		fnrunv1alpha1.OwnerUIDLabelKey: string(owner.GetUID()),
+	})
+	meta.AddAnnotations(child, map[string]string{
+		fnrunv1alpha1.OwnerGVKAnnotationKey:       meta.GVKToString(&gvk),
+		fnrunv1alpha1.OwnerNamespaceAnnotationKey: owner.GetNamespace(),
+		fnrunv1alpha1.OwnerNameAnnotationKey:      owner.GetName(),
+	})
+}
+
+// DeleteLabelOwned deletes the children of the gvks which are tracked with
+// the owner labels, since the garbage collector does not delete them
+func DeleteLabelOwned(ctx context.Context, r client.Reader, c client.Client, owner *unstructured.Unstructured, gvks []schema.GroupVersionKind) error {
+	for _, gvk := range gvks {
+		gvk := gvk
+		l := meta.GetUnstructuredListFromGVK(&gvk)
+		if err := r.List(ctx, l, client.MatchingLabels{fnrunv1alpha1.OwnerUIDLabelKey: string(owner.GetUID())}); err != nil {
+			return err
+		}
+		for _, child := range l.Items {
+			child := child
+			if err := c.Delete(ctx, &child); meta.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/healthhandler"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// conditionImagesReady reports if the images of the controller serve the
+	// functions of the for resource
+	conditionImagesReady = "ImagesReady"
+
+	reasonServing     = "Serving"
+	reasonNotServing  = "NotServing"
+	reasonCircuitOpen = "CircuitOpen"
+
+	healthCheckTimeout = 2 * time.Second
+)
+
+// imagesReadyCondition returns the condition of the images of the controller
+// and their circuit breakers as reported by the fn proxy, nil when the proxy
+// does not report it
+func (r *reconciler) imagesReadyCondition(ctx context.Context, fnc *clients.Clients, generation int64) *metav1.Condition {
+	if fnc.Health == nil {
+		return nil
+	}
+	c := &metav1.Condition{
+		Type:               conditionImagesReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             reasonServing,
+	}
+	breakers, err := checkService(ctx, fnc.Health, healthhandler.BreakerService(r.ceCtx.GetName()))
+	if err != nil {
+		r.l.Info("cannot check the circuit breakers of the images", "error", err)
+		return nil
+	}
+	if breakers == healthpb.HealthCheckResponse_NOT_SERVING {
+		c.Status = metav1.ConditionFalse
+		c.Reason = reasonCircuitOpen
+		c.Message = "the circuit breaker of an image is open"
+		return c
+	}
+	images, err := checkService(ctx, fnc.Health, r.ceCtx.GetName())
+	if err != nil {
+		r.l.Info("cannot check the images", "error", err)
+		return nil
+	}
+	if images == healthpb.HealthCheckResponse_NOT_SERVING {
+		c.Status = metav1.ConditionFalse
+		c.Reason = reasonNotServing
+		c.Message = "an image is not serving"
+	}
+	return c
+}
+
+// checkService returns the serving status of the health service, a service
+// unknown to the proxy is serving as the controller has no images
+func checkService(ctx context.Context, hc healthpb.HealthClient, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	resp, err := hc.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return healthpb.HealthCheckResponse_SERVING, nil
+		}
+		return healthpb.HealthCheckResponse_UNKNOWN, err
+	}
+	return resp.GetStatus(), nil
+}
+
+// setCondition sets the condition in the status of the resource, a nil
+// condition leaves the status unchanged
+func setCondition(cr *unstructured.Unstructured, c *metav1.Condition) error {
+	if c == nil {
+		return nil
+	}
+	existing, _, err := unstructured.NestedSlice(cr.Object, "status", "conditions")
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	conditions := []metav1.Condition{}
+	if err := json.Unmarshal(b, &conditions); err != nil {
+		return err
+	}
+	apimeta.SetStatusCondition(&conditions, *c)
+	if b, err = json.Marshal(conditions); err != nil {
+		return err
+	}
+	updated := []any{}
+	if err := json.Unmarshal(b, &updated); err != nil {
+		return err
+	}
+	return unstructured.SetNestedSlice(cr.Object, updated, "status", "conditions")
+}
@@ -18,25 +18,40 @@ package reconciler
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/fnrunner/fnproto/pkg/executor/execclient"
 	"github.com/fnrunner/fnproto/pkg/service/svcclient"
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/fnrunner/fnruntime/internal/ctrlr/event"
-	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnruntime/pkg/ctrlr/controllers/ownership"
+	"github.com/fnrunner/fnruntime/pkg/ctrlr/controllers/scope"
 	"github.com/fnrunner/fnruntime/pkg/exec/builder"
 	"github.com/fnrunner/fnruntime/pkg/exec/fnmap"
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"github.com/fnrunner/fnruntime/pkg/exec/policy"
+	"github.com/fnrunner/fnruntime/pkg/exec/replay"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
+	"github.com/fnrunner/fnruntime/pkg/exec/schema"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnruntime/pkg/exec/trace"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
 	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/fnrunner/fnutils/pkg/applicator"
 	"github.com/fnrunner/fnutils/pkg/meta"
@@ -47,6 +62,9 @@ import (
 const (
 	// const
 	defaultFinalizerName = "fnrun.io/finalizer"
+	// maxRecordBundles bounds the replay bundles kept in the record dir, the
+	// oldest are removed
+	maxRecordBundles = 1000
 	// errors
 	errGetCr        = "cannot get resource"
 	errUpdateStatus = "cannot update resource status"
@@ -61,6 +79,29 @@ type Config struct {
 	PollInterval time.Duration
 	CeCtx        ccsyntax.ConfigExecutionContext
 	FnMap        fnmap.FuncMap
+	Services     service.Services
+	TraceStore   trace.Store
+	// ControllerConfig is the spec the execution context is parsed from
+	ControllerConfig *ctrlcfgv1alpha1.ControllerConfigSpec
+	// RecordDir enables recording a replay bundle of every run in the dir,
+	// the newest bundles are kept
+	RecordDir string
+	// FnClients are long-lived clients to the fn proxy, e.g. shared by the
+	// controllers of a manager or to an in-process server, they are not closed
+	// by the reconciler. A connection is dialed per reconcile when nil.
+	FnClients *clients.Clients
+	// Validator validates the final output before it is applied
+	Validator schema.Validator
+	// Policy restricts the final output, it is enforced before the apply
+	Policy *fnrunv1alpha1.OutputPolicy
+	// ApplyClient applies the final output, e.g. impersonating the service
+	// account of the policy, defaults to Client
+	ApplyClient client.Client
+	// APIReader lists the children tracked with the owner labels in every
+	// namespace, defaults to Client
+	APIReader client.Reader
+	// ProxyTLS enables mTLS to the fn proxy, insecure when nil
+	ProxyTLS *tls.Config
 }
 
 func New(c *Config) reconcile.Reconciler {
@@ -71,11 +112,31 @@ func New(c *Config) reconcile.Reconciler {
 		ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 	*/
 
+	applyClient := c.ApplyClient
+	if applyClient == nil {
+		applyClient = c.Client
+	}
+	var apiReader client.Reader = c.Client
+	if c.APIReader != nil {
+		apiReader = c.APIReader
+	}
+
 	return &reconciler{
 		client:       applicator.ClientApplicator{Client: c.Client, Applicator: applicator.NewAPIPatchingApplicator(c.Client)},
+		applicator:   applicator.NewAPIPatchingApplicator(applyClient),
+		apiReader:    apiReader,
 		pollInterval: c.PollInterval,
 		ceCtx:        c.CeCtx,
 		fnMap:        c.FnMap,
+		services:     c.Services,
+		traceStore:   c.TraceStore,
+		ctrlCfg:      c.ControllerConfig,
+		recordDir:    c.RecordDir,
+		fnClients:    c.FnClients,
+		proxyTLS:     c.ProxyTLS,
+		validator:    c.Validator,
+		policy:       policy.New(c.Policy),
+		childNs:      getChildNamespace(c.Policy),
 		l:            ctrl.Log.WithName("fnrun reconcile"),
 		f:            meta.NewAPIFinalizer(c.Client, defaultFinalizerName),
 		record:       event.NewNopRecorder(),
@@ -84,9 +145,20 @@ func New(c *Config) reconcile.Reconciler {
 
 type reconciler struct {
 	client       applicator.ClientApplicator
+	applicator   applicator.Applicator
+	apiReader    client.Reader
 	pollInterval time.Duration
 	ceCtx        ccsyntax.ConfigExecutionContext
 	fnMap        fnmap.FuncMap
+	services     service.Services
+	traceStore   trace.Store
+	ctrlCfg      *ctrlcfgv1alpha1.ControllerConfigSpec
+	recordDir    string
+	fnClients    *clients.Clients
+	proxyTLS     *tls.Config
+	validator    schema.Validator
+	policy       policy.Enforcer
+	childNs      string
 	f            meta.Finalizer
 	l            logr.Logger
 	record       event.Recorder
@@ -119,39 +191,63 @@ func (r *reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
 	}
 
-	fnc, err := r.getFnClients()
-	if err != nil {
-		r.l.Error(err, "get svc clients")
-		return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
+	fnc := r.fnClients
+	if fnc == nil {
+		fnc, err = r.getFnClients()
+		if err != nil {
+			r.l.Error(err, "get svc clients")
+			return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
+		}
+		// clients sharing a connection close it once
+		defer fnc.Execclient.Close()
+		defer fnc.Svcclient.Close()
 	}
-	defer fnc.Execclient.Close()
-	defer fnc.Svcclient.Close()
 
 	// delete branch -> used for delete
 	if meta.WasDeleted(cr) {
 		r.l.Info("reconcile delete started...")
 		// handle delete branch
 		deleteDAGCtx := r.ceCtx.GetDAGCtx(ccsyntax.FOWFor, gvk, ccsyntax.OperationDelete)
+		start := time.Now()
+		runID := string(uuid.NewUUID())
+		rec := r.newRecorder(runID, ccsyntax.OperationDelete, cr)
 
 		o := output.New()
 		result := result.New()
 		e := builder.New(&builder.Config{
-			Name:      req.Name,
-			Namespace: req.Namespace,
+			Name:           req.Name,
+			Namespace:      req.Namespace,
 			ControllerName: r.ceCtx.GetName(),
-			Data:      x,
-			Client:    r.client,
-			GVK:       gvk,
-			DAG:       deleteDAGCtx.DAG,
-			Output:    o,
-			Result:    result,
-			FnClients: fnc,
+			Data:           x,
+			Client:         rec.client(r.client),
+			GVK:            gvk,
+			DAG:            deleteDAGCtx.DAG,
+			Output:         o,
+			Result:         result,
+			FnClients:      rec.fnClients(fnc),
+			Services:       r.services,
+			Operation:      ccsyntax.OperationDelete,
 		})
 
 		// TODO should be per crName
 		e.Run(ctx)
 		//o.Print()
 		result.Print()
+		runErr := result.Err()
+		r.recordTrace(req, runID, ccsyntax.OperationDelete, start, result, runErr)
+		r.saveRecording(req, rec)
+
+		// the finalizer is kept until the delete pipeline succeeded, e.g. the
+		// services released the conditioned resources
+		if runErr != nil {
+			r.l.Error(runErr, "cannot run the delete pipeline")
+			return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
+		}
+
+		if err := ownership.DeleteLabelOwned(ctx, r.apiReader, r.client, cr, r.ownGVKs()); err != nil {
+			r.l.Error(err, "cannot delete children tracked by owner labels")
+			return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
+		}
 
 		if err := r.f.RemoveFinalizer(ctx, cr); err != nil {
 			r.l.Error(err, "cannot remove finalizer")
@@ -166,49 +262,105 @@ func (r *reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	// apply branch -> used for create and update
 	r.l.Info("reconcile apply started...")
 	applyDAGCtx := r.ceCtx.GetDAGCtx(ccsyntax.FOWFor, gvk, ccsyntax.OperationApply)
+	start := time.Now()
+	runID := string(uuid.NewUUID())
+	rec := r.newRecorder(runID, ccsyntax.OperationApply, cr)
 
 	o := output.New()
 	result := result.New()
 	e := builder.New(&builder.Config{
-		Name:      req.Name,
-		Namespace: req.Namespace,
+		Name:           req.Name,
+		Namespace:      req.Namespace,
 		ControllerName: r.ceCtx.GetName(),
-		Data:      x,
-		Client:    r.client,
-		GVK:       gvk,
-		DAG:       applyDAGCtx.DAG,
-		Output:    o,
-		Result:    result,
-		FnClients: fnc,
+		Data:           x,
+		Client:         rec.client(r.client),
+		GVK:            gvk,
+		DAG:            applyDAGCtx.DAG,
+		Output:         o,
+		Result:         result,
+		FnClients:      rec.fnClients(fnc),
+		Services:       r.services,
+		Operation:      ccsyntax.OperationApply,
 	})
 
 	e.Run(ctx)
 	//o.Print()
 	result.Print()
+	r.saveRecording(req, rec)
+
+	// the images condition is reported with every status update of the apply
+	imagesReady := r.imagesReadyCondition(ctx, fnc, cr.GetGeneration())
+	if err := setCondition(cr, imagesReady); err != nil {
+		r.l.Error(err, "cannot set the images condition")
+	}
+
+	// the trace is recorded when the apply of the final output is done
+	var applyErr error
+	defer func() {
+		r.recordTrace(req, runID, ccsyntax.OperationApply, start, result, applyErr)
+	}()
 
 	// TODO check result if failed, return an error
 
-	for _, output := range o.GetFinalOutput() {
+	finalOutput, err := r.defaultNamespaces(req.Namespace, o.GetFinalOutput())
+	if err != nil {
+		r.l.Error(err, "cannot default the namespace of the final output")
+		applyErr = err
+		return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
+	}
+
+	// nothing is applied when any of the output is invalid
+	if err := r.policy.Enforce(*gvk, req.NamespacedName, finalOutput); err != nil {
+		r.l.Error(err, "final output violates the output policy")
+		applyErr = err
+		return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
+	}
+	if r.validator != nil {
+		if err := r.validator.Validate(finalOutput); err != nil {
+			r.l.Error(err, "invalid final output")
+			applyErr = err
+			return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
+		}
+	}
+
+	owner := cr
+	ownGVKs := r.ceCtx.GetFOW(ccsyntax.FOWOwn)
+	for _, output := range finalOutput {
 		b, err := json.MarshalIndent(output, "", "  ")
 		if err != nil {
 			r.l.Error(err, "cannot marshal the content")
+			applyErr = err
 			return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
 		}
 		//r.l.Info("final output", "jsin string", string(b))
 		u := &unstructured.Unstructured{}
 		if err := json.Unmarshal(b, u); err != nil {
 			r.l.Error(err, "cannot unmarshal the content")
+			applyErr = err
 			return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
 		}
 		r.l.Info("final output", "unstructured", u)
 
 		r.l.Info("gvk", "cr", cr.GroupVersionKind(), "u", u.GroupVersionKind())
 
-		if u.GroupVersionKind() == cr.GroupVersionKind() {
+		// only the status of the for-resource is updated, the other objects of
+		// its gvk are applied like the other objects
+		if policy.IsForResource(u, cr.GroupVersionKind(), req.NamespacedName) {
+			if err := setCondition(u, imagesReady); err != nil {
+				r.l.Error(err, "cannot set the images condition")
+			}
 			cr = u
 		} else {
-			if err := r.client.Apply(ctx, u); err != nil {
+			if _, ok := ownGVKs[u.GroupVersionKind()]; ok {
+				if err := ownership.SetOwner(r.client.RESTMapper(), owner, u); err != nil {
+					r.l.Error(err, "cannot set the owner")
+					applyErr = err
+					return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
+				}
+			}
+			if err := r.applicator.Apply(ctx, u); err != nil {
 				r.l.Error(err, "cannot apply the content")
+				applyErr = err
 				return reconcile.Result{RequeueAfter: 5 * time.Second}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
 			}
 		}
@@ -218,7 +370,132 @@ func (r *reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	return reconcile.Result{}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateStatus)
 }
 
+// recordTrace records the execution of the DAG in the trace store
+func (r *reconciler) recordTrace(req ctrl.Request, runID string, op ccsyntax.Operation, start time.Time, res result.Result, err error) {
+	if r.traceStore == nil {
+		return
+	}
+	gvk := r.ceCtx.GetForGVK()
+	t := &trace.Trace{
+		RunID:      runID,
+		Controller: r.ceCtx.GetName(),
+		Namespace:  req.Namespace,
+		Name:       req.Name,
+		DAG:        fmt.Sprintf("%s/%s/%s", ccsyntax.FOWFor, meta.GVKToString(gvk), op),
+		StartTime:  start,
+		EndTime:    time.Now(),
+	}
+	t.SetResult(res, r.traceStore.GetRedactor())
+	if err != nil {
+		t.Status = trace.StatusFailed
+		t.Reason = err.Error()
+	}
+	r.traceStore.Add(t)
+	r.l.Info("trace recorded", "runID", t.RunID, "status", t.Status)
+}
+
+// defaultNamespaces sets the namespace of the namespaced objects without
+// namespace, to the namespace of a namespaced for-resource or to the child
+// namespace of the policy for a cluster scoped for-resource. The namespace of
+// the cluster scoped objects is cleared.
+func (r *reconciler) defaultNamespaces(forNamespace string, objs []any) ([]any, error) {
+	namespace := forNamespace
+	if namespace == "" {
+		namespace = r.childNs
+	}
+	out := make([]any, 0, len(objs))
+	for _, o := range objs {
+		u, err := output.ToUnstructured(o)
+		if err != nil {
+			return nil, err
+		}
+		if err := scope.DefaultNamespace(r.client.RESTMapper(), u, namespace); err != nil {
+			return nil, err
+		}
+		out = append(out, u.Object)
+	}
+	return out, nil
+}
+
+func getChildNamespace(p *fnrunv1alpha1.OutputPolicy) string {
+	if p == nil {
+		return ""
+	}
+	return p.ChildNamespace
+}
+
+func (r *reconciler) ownGVKs() []k8sschema.GroupVersionKind {
+	gvks := []k8sschema.GroupVersionKind{}
+	for gvk := range r.ceCtx.GetFOW(ccsyntax.FOWOwn) {
+		gvks = append(gvks, gvk)
+	}
+	return gvks
+}
+
+// runRecorder records the run in a replay bundle, a nil recorder passes the
+// clients through
+type runRecorder struct {
+	replay.Recorder
+}
+
+func (r *runRecorder) client(c client.Client) client.Client {
+	if r == nil {
+		return c
+	}
+	return r.Client(c)
+}
+
+func (r *runRecorder) fnClients(c *clients.Clients) *clients.Clients {
+	if r == nil {
+		return c
+	}
+	return r.FnClients(c)
+}
+
+func (r *reconciler) newRecorder(runID string, op ccsyntax.Operation, cr *unstructured.Unstructured) *runRecorder {
+	if r.recordDir == "" {
+		return nil
+	}
+	return &runRecorder{Recorder: replay.NewRecorder(&replay.RecorderConfig{
+		RunID:            runID,
+		Controller:       r.ceCtx.GetName(),
+		Operation:        op,
+		ControllerConfig: r.ctrlCfg,
+		ForResource:      cr.UnstructuredContent(),
+	})}
+}
+
+// saveRecording writes the replay bundle of the run in the record dir
+func (r *reconciler) saveRecording(req ctrl.Request, rec *runRecorder) {
+	if rec == nil {
+		return
+	}
+	b := rec.Bundle()
+	if err := os.MkdirAll(r.recordDir, 0755); err != nil {
+		r.l.Error(err, "cannot create record dir", "dir", r.recordDir)
+		return
+	}
+	path := filepath.Join(r.recordDir, fmt.Sprintf("%s-%s-%s-%s-%s.json", b.Controller, req.Namespace, req.Name, b.Operation, b.RunID))
+	if err := b.WriteFile(path); err != nil {
+		r.l.Error(err, "cannot write replay bundle", "path", path)
+		return
+	}
+	r.l.Info("replay bundle recorded", "path", path)
+	if err := replay.Prune(r.recordDir, maxRecordBundles); err != nil {
+		r.l.Error(err, "cannot prune replay bundles", "dir", r.recordDir)
+	}
+}
+
 func (r *reconciler) getFnClients() (*clients.Clients, error) {
+	if r.proxyTLS != nil {
+		conn, err := grpc.Dial(fmt.Sprintf("%s:%d", "127.0.0.1", fnrunv1alpha1.FnProxyGRPCServerPort),
+			grpc.WithTransportCredentials(credentials.NewTLS(r.proxyTLS)))
+		if err != nil {
+			r.l.Error(err, "cannot create new client")
+			return nil, err
+		}
+		return clients.NewFromConn(conn), nil
+	}
 	svcClient, err := svcclient.New(&svcclient.Config{
 		Address:  fmt.Sprintf("%s:%d", "127.0.0.1", fnrunv1alpha1.FnProxyGRPCServerPort),
 		Insecure: true,
@@ -0,0 +1,55 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"fmt"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// IsNamespaced returns true if the gvk is namespace scoped
+func IsNamespaced(mapper apimeta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	m, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+	return m.Scope.Name() == apimeta.RESTScopeNameNamespace, nil
+}
+
+// DefaultNamespace sets the namespace of a namespaced object without
+// namespace and clears the namespace of a cluster scoped object
+func DefaultNamespace(mapper apimeta.RESTMapper, u *unstructured.Unstructured, namespace string) error {
+	namespaced, err := IsNamespaced(mapper, u.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	if !namespaced {
+		u.SetNamespace("")
+		return nil
+	}
+	if u.GetNamespace() != "" {
+		return nil
+	}
+	if namespace == "" {
+		return fmt.Errorf("%s %s has no namespace", u.GroupVersionKind(), u.GetName())
+	}
+	u.SetNamespace(namespace)
+	return nil
+}
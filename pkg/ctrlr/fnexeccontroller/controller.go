@@ -19,8 +19,12 @@ package fnexeccontroller
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/fnrunner/fnruntime/pkg/ctrlr/controllers/eventhandler"
+	"github.com/fnrunner/fnruntime/pkg/ctrlr/controllers/ownership"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
 	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/fnrunner/fnutils/pkg/meta"
 	"github.com/go-logr/logr"
@@ -55,14 +59,40 @@ type fnctrlr struct {
 	ge    chan event.GenericEvent
 
 	globalPredicates []predicate.Predicate
+	// fnClients are the clients to the fn proxy of the watch eventhandlers
+	fnClients *clients.Clients
+	// services resolve the conditioned outputs of the watch eventhandlers
+	services service.Services
 
+	// m protects the cancel and the err, they are read by the admin api
+	m      sync.RWMutex
 	cancel context.CancelFunc
 	err    error
-	l      logr.Logger
+	// run identifies the current run, a crash of a stopped run does not
+	// stop the run started after it
+	run uint64
+	l   logr.Logger
 }
 
-func New(mgr manager.Manager, ceCtx ccsyntax.ConfigExecutionContext, ge chan event.GenericEvent) Controller {
-	return &fnctrlr{
+type Option func(*fnctrlr)
+
+// WithFnClients sets the clients to the fn proxy of the watch eventhandlers
+func WithFnClients(fnc *clients.Clients) Option {
+	return func(r *fnctrlr) {
+		r.fnClients = fnc
+	}
+}
+
+// WithServices sets the services resolving the conditioned outputs of the
+// watch eventhandlers
+func WithServices(services service.Services) Option {
+	return func(r *fnctrlr) {
+		r.services = services
+	}
+}
+
+func New(mgr manager.Manager, ceCtx ccsyntax.ConfigExecutionContext, ge chan event.GenericEvent, opts ...Option) Controller {
+	r := &fnctrlr{
 		mgr:   mgr,
 		ceCtx: ceCtx,
 		ge:    ge,
@@ -71,17 +101,44 @@ func New(mgr manager.Manager, ceCtx ccsyntax.ConfigExecutionContext, ge chan eve
 		cancel:           nil,
 		err:              nil,
 	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
 }
 
 func (r *fnctrlr) IsRunning() bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
 	return r.cancel != nil
 }
 
 func (r *fnctrlr) Error() error {
+	r.m.RLock()
+	defer r.m.RUnlock()
 	return r.err
 }
 
 func (r *fnctrlr) Stop(ctx context.Context) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+// crashed records the error the cache or the controller of the run stopped
+// with and cancels the other, the controller is no longer running
+func (r *fnctrlr) crashed(run uint64, err error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if run != r.run {
+		return
+	}
+	if err != nil {
+		r.err = err
+	}
 	if r.cancel != nil {
 		r.cancel()
 		r.cancel = nil
@@ -92,7 +149,11 @@ func (r *fnctrlr) Start(ctx context.Context, name string, o controller.Options)
 	r.l = log.FromContext(ctx).WithValues("name", name)
 	r.l.Info("start fncontroller")
 	ctx, cancel := context.WithCancel(ctx)
+	r.m.Lock()
 	r.cancel = cancel
+	r.run++
+	run := r.run
+	r.m.Unlock()
 
 	cache, err := cache.New(r.mgr.GetConfig(), cache.Options{Scheme: r.mgr.GetScheme(), Mapper: r.mgr.GetRESTMapper()})
 	if err != nil {
@@ -142,6 +203,14 @@ func (r *fnctrlr) Start(ctx context.Context, name string, o controller.Options)
 		); err != nil {
 			return fmt.Errorf("%s, err: %s", errCreateWatch, err)
 		}
+		// children which cannot have an owner reference are tracked by labels
+		if err := ctrl.Watch(
+			&source.Kind{Type: meta.GetUnstructuredFromGVK(&gvk)},
+			ownership.EnqueueRequestForLabelOwner(forSrcKind.GroupVersionKind()),
+			allPredicates...,
+		); err != nil {
+			return fmt.Errorf("%s, err: %s", errCreateWatch, err)
+		}
 	}
 	// watch watch
 	for gvk, od := range r.ceCtx.GetFOW(ccsyntax.FOWWatch) {
@@ -155,10 +224,13 @@ func (r *fnctrlr) Start(ctx context.Context, name string, o controller.Options)
 		src := &source.Kind{Type: obj}
 
 		eh := eventhandler.New(&eventhandler.Config{
+			ControllerName: name,
 			Client:         r.mgr.GetClient(),
 			RootVertexName: od[ccsyntax.OperationApply].RootVertexName,
 			GVK:            &gvk,
 			DAG:            od[ccsyntax.OperationApply].DAG,
+			FnClients:      r.fnClients,
+			Services:       r.services,
 		})
 
 		if err := ctrl.Watch(src, eh, allPredicates...); err != nil {
@@ -169,25 +241,20 @@ func (r *fnctrlr) Start(ctx context.Context, name string, o controller.Options)
 	go func() {
 		<-r.mgr.Elected()
 		r.l.Info("start fncontroller cache")
-		r.err = cache.Start(ctx)
-		if r.err != nil {
+		err := cache.Start(ctx)
+		if err != nil {
 			r.l.Error(err, errStartCache)
 		}
-
-		if r.cancel != nil {
-			r.cancel()
-		}
+		r.crashed(run, err)
 	}()
 	go func() {
 		<-r.mgr.Elected()
 		r.l.Info("start fncontroller controller")
-		r.err = ctrl.Start(ctx)
-		if r.err != nil {
+		err := ctrl.Start(ctx)
+		if err != nil {
 			r.l.Error(err, errStartController)
 		}
-		if r.cancel != nil {
-			r.cancel()
-		}
+		r.crashed(run, err)
 	}()
 	return nil
 }
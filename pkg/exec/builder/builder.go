@@ -23,13 +23,17 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/fnrunner/fnutils/pkg/executor"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Config struct {
+	// Name and Namespace of the for-resource, the namespace is empty for a
+	// cluster scoped for-resource
 	Name           string
 	Namespace      string
 	ControllerName string
@@ -40,6 +44,8 @@ type Config struct {
 	Output         output.Output
 	Result         result.Result
 	FnClients      *clients.Clients
+	Services       service.Services
+	Operation      ccsyntax.Operation
 }
 
 func New(c *Config) executor.Executor {
@@ -55,6 +61,8 @@ func New(c *Config) executor.Executor {
 		Result:         c.Result,
 		FnClients:      c.FnClients,
 		ControllerName: c.ControllerName,
+		Services:       c.Services,
+		Operation:      c.Operation,
 	})
 
 	// Initialize the initial data
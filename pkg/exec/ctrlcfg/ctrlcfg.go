@@ -0,0 +1,178 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctrlcfg
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
+	"github.com/fnrunner/fnutils/pkg/meta"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConfigMapKey is the key in the configmap data holding the controller config
+	ConfigMapKey = "controllerConfig"
+	// PolicyConfigMapKey is the key in the configmap data holding the output
+	// policy of the controller
+	PolicyConfigMapKey = "outputPolicy"
+	kindConfigMap      = "ConfigMap"
+)
+
+// ControllerConfig is the parsed controller config
+type ControllerConfig struct {
+	Name   string
+	Spec   *ctrlcfgv1alpha1.ControllerConfigSpec
+	CeCtx  ccsyntax.ConfigExecutionContext
+	Images []*fnrunv1alpha1.Image
+	Policy *fnrunv1alpha1.OutputPolicy
+}
+
+type object struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec *ctrlcfgv1alpha1.ControllerConfigSpec `json:"spec,omitempty"`
+	Data map[string]string                     `json:"data,omitempty"`
+}
+
+// ReadFile reads a ControllerConfig or a ConfigMap holding a controller config
+// from a file and parses it
+func ReadFile(path string) (*ControllerConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name, spec, err := Unmarshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("file %s, err: %s", path, err.Error())
+	}
+	cc, err := Parse(name, spec)
+	if err != nil {
+		return nil, err
+	}
+	o := &object{}
+	if err := yaml.Unmarshal(b, o); err != nil {
+		return nil, err
+	}
+	if cc.Policy, err = UnmarshalPolicy(o.Data[PolicyConfigMapKey]); err != nil {
+		return nil, fmt.Errorf("file %s, err: %s", path, err.Error())
+	}
+	return cc, nil
+}
+
+// UnmarshalPolicy returns the output policy in the policy data of a
+// configmap, nil if there is no policy
+func UnmarshalPolicy(s string) (*fnrunv1alpha1.OutputPolicy, error) {
+	if s == "" {
+		return nil, nil
+	}
+	p := &fnrunv1alpha1.OutputPolicy{}
+	if err := yaml.Unmarshal([]byte(s), p); err != nil {
+		return nil, fmt.Errorf("invalid output policy: %s", err.Error())
+	}
+	return p, nil
+}
+
+// Unmarshal returns the name and the spec of a ControllerConfig or a
+// ConfigMap holding a controller config
+func Unmarshal(b []byte) (string, *ctrlcfgv1alpha1.ControllerConfigSpec, error) {
+	o := &object{}
+	if err := yaml.Unmarshal(b, o); err != nil {
+		return "", nil, err
+	}
+	if o.Kind == kindConfigMap {
+		spec := &ctrlcfgv1alpha1.ControllerConfigSpec{}
+		if err := yaml.Unmarshal([]byte(o.Data[ConfigMapKey]), spec); err != nil {
+			return "", nil, err
+		}
+		return o.Metadata.Name, spec, nil
+	}
+	if o.Spec == nil {
+		return "", nil, fmt.Errorf("controller config %s without spec", o.Metadata.Name)
+	}
+	return o.Metadata.Name, o.Spec, nil
+}
+
+// Parse validates and parses the controller config spec, the resulting
+// runtime DAGs are validated structurally
+func Parse(name string, spec *ctrlcfgv1alpha1.ControllerConfigSpec) (*ControllerConfig, error) {
+	p, result := ccsyntax.NewParser(name, spec)
+	if len(result) > 0 {
+		return nil, fmt.Errorf("failed ccsyntax validation, result %v", result)
+	}
+	ceCtx, result := p.Parse()
+	if len(result) != 0 {
+		return nil, fmt.Errorf("failed ccsyntax parsing, result %v", result)
+	}
+	if err := Validate(ceCtx); err != nil {
+		return nil, err
+	}
+	return &ControllerConfig{
+		Name:   name,
+		Spec:   spec,
+		CeCtx:  ceCtx,
+		Images: p.GetImages(),
+	}, nil
+}
+
+// ValidationError contains the structural problems found in the runtime DAGs
+// of a controller config, keyed by <fow>/<gvk>/<operation>
+type ValidationError map[string]rtdag.Problems
+
+func (r ValidationError) Error() string {
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := make([]string, 0, len(r))
+	for _, k := range keys {
+		s = append(s, fmt.Sprintf("dag %s: %s", k, r[k].Error()))
+	}
+	return strings.Join(s, ", ")
+}
+
+// Validate validates the runtime DAGs of the execution context, it returns a
+// ValidationError if problems are found
+func Validate(ceCtx ccsyntax.ConfigExecutionContext) error {
+	verr := ValidationError{}
+	for _, fow := range []ccsyntax.FOWS{ccsyntax.FOWFor, ccsyntax.FOWOwn, ccsyntax.FOWWatch} {
+		for gvk, opCtx := range ceCtx.GetFOW(fow) {
+			gvk := gvk
+			for op, dagCtx := range opCtx {
+				if dagCtx == nil || dagCtx.DAG == nil {
+					continue
+				}
+				if problems := dagCtx.DAG.Validate(); len(problems) > 0 {
+					verr[fmt.Sprintf("%s/%s/%s", fow, meta.GVKToString(&gvk), op)] = problems
+				}
+			}
+		}
+	}
+	if len(verr) > 0 {
+		return verr
+	}
+	return nil
+}
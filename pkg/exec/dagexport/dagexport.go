@@ -0,0 +1,175 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dagexport
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
+	"github.com/fnrunner/fnutils/pkg/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Document is the exported representation of all the runtime DAGs of a controller
+type Document struct {
+	Controller string `json:"controller"`
+	DAGs       []*DAG `json:"dags"`
+}
+
+// DAG is the exported representation of a runtime DAG
+type DAG struct {
+	// Name of the DAG: <fow>/<gvk>/<operation> for a pipeline DAG
+	// or the name of the block vertex for a nested block DAG
+	Name      string    `json:"name"`
+	FOW       string    `json:"fow,omitempty"`
+	GVK       string    `json:"gvk,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	Root      string    `json:"root"`
+	Vertices  []*Vertex `json:"vertices"`
+}
+
+// Vertex is the exported representation of a vertex in the runtime DAG
+type Vertex struct {
+	Name         string    `json:"name"`
+	Kind         string    `json:"kind"`
+	Type         string    `json:"type,omitempty"`
+	Image        string    `json:"image,omitempty"`
+	References   []string  `json:"references,omitempty"`
+	Range        string    `json:"range,omitempty"`
+	Condition    string    `json:"condition,omitempty"`
+	Outputs      []*Output `json:"outputs,omitempty"`
+	DownVertices []string  `json:"downVertices,omitempty"`
+	Block        *DAG      `json:"block,omitempty"`
+}
+
+// Output is the exported representation of a vertex output
+type Output struct {
+	VarName     string `json:"varName"`
+	GVK         string `json:"gvk,omitempty"`
+	Internal    bool   `json:"internal,omitempty"`
+	Conditioned bool   `json:"conditioned,omitempty"`
+}
+
+// ExportExecutionContext exports all the runtime DAGs of the execution context
+// of a controller sorted by fow, gvk and operation
+func ExportExecutionContext(ceCtx ccsyntax.ConfigExecutionContext) *Document {
+	doc := &Document{
+		Controller: ceCtx.GetName(),
+		DAGs:       []*DAG{},
+	}
+	for _, fow := range []ccsyntax.FOWS{ccsyntax.FOWFor, ccsyntax.FOWOwn, ccsyntax.FOWWatch} {
+		gvks := []schema.GroupVersionKind{}
+		opCtxs := ceCtx.GetFOW(fow)
+		for gvk := range opCtxs {
+			gvks = append(gvks, gvk)
+		}
+		sort.Slice(gvks, func(i, j int) bool {
+			return gvks[i].String() < gvks[j].String()
+		})
+		for _, gvk := range gvks {
+			gvk := gvk
+			for _, op := range []ccsyntax.Operation{ccsyntax.OperationApply, ccsyntax.OperationDelete} {
+				dagCtx, ok := opCtxs[gvk][op]
+				if !ok || dagCtx == nil || dagCtx.DAG == nil {
+					continue
+				}
+				gvkString := meta.GVKToString(&gvk)
+				d := Export(fmt.Sprintf("%s/%s/%s", fow, gvkString, op), dagCtx.DAG)
+				d.FOW = string(fow)
+				d.GVK = gvkString
+				d.Operation = string(op)
+				doc.DAGs = append(doc.DAGs, d)
+			}
+		}
+	}
+	return doc
+}
+
+// Export exports a runtime DAG, including the nested block DAGs
+func Export(name string, d rtdag.RuntimeDAG) *DAG {
+	e := &DAG{
+		Name:     name,
+		Root:     d.GetRootVertex(),
+		Vertices: []*Vertex{},
+	}
+	for vertexName, v := range d.GetVertices() {
+		ev := &Vertex{
+			Name:         vertexName,
+			DownVertices: sortedCopy(d.GetDownVertexes(vertexName)),
+		}
+		vc, ok := v.(*rtdag.VertexContext)
+		if !ok {
+			ev.Kind = "unknown"
+			e.Vertices = append(e.Vertices, ev)
+			continue
+		}
+		ev.Kind = string(vc.Kind)
+		ev.Type = string(vc.Function.Type)
+		ev.Image = vc.Function.Image
+		ev.References = sortedCopy(vc.References)
+		if vc.Function.Range != nil {
+			ev.Range = vc.Function.Range.Value
+		}
+		if vc.Function.Condition != nil {
+			ev.Condition = vc.Function.Condition.Expression
+		}
+		ev.Outputs = exportOutputs(vc.Outputs)
+		if vc.BlockDAG != nil {
+			ev.Block = Export(vertexName, vc.BlockDAG)
+		}
+		e.Vertices = append(e.Vertices, ev)
+	}
+	sort.Slice(e.Vertices, func(i, j int) bool {
+		return e.Vertices[i].Name < e.Vertices[j].Name
+	})
+	return e
+}
+
+func exportOutputs(o output.Output) []*Output {
+	if o == nil {
+		return nil
+	}
+	outputs := []*Output{}
+	for varName, v := range o.Get() {
+		eo := &Output{VarName: varName}
+		if oi, ok := v.(*output.OutputInfo); ok {
+			eo.Internal = oi.Internal
+			eo.Conditioned = oi.Conditioned
+			if oi.GVK != nil {
+				eo.GVK = meta.GVKToString(oi.GVK)
+			}
+		}
+		outputs = append(outputs, eo)
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].VarName < outputs[j].VarName
+	})
+	return outputs
+}
+
+func sortedCopy(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	c := make([]string, len(s))
+	copy(c, s)
+	sort.Strings(c)
+	return c
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dagexport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
+)
+
+const HandlerPath = "/debug/dag/"
+
+// NewHandler returns a http handler serving the runtime DAGs of the controllers
+// in the controller store
+//
+// GET /debug/dag/                               -> list of controllers
+// GET /debug/dag/<controller>?format=dot|mermaid|json -> rendered DAGs of the controller
+func NewHandler(s ctrlstore.Store) http.Handler {
+	return &handler{ctrlStore: s}
+}
+
+type handler struct {
+	ctrlStore ctrlstore.Store
+}
+
+func (r *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	controllerName := strings.Trim(strings.TrimPrefix(req.URL.Path, HandlerPath), "/")
+	if controllerName == "" {
+		controllers := r.ctrlStore.List()
+		sort.Strings(controllers)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(controllers); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !r.ctrlStore.Exists(controllerName) {
+		http.Error(w, fmt.Sprintf("controller %s not found", controllerName), http.StatusNotFound)
+		return
+	}
+	ceCtx := r.ctrlStore.GetExecutionContext(controllerName)
+	if ceCtx == nil {
+		http.Error(w, fmt.Sprintf("controller %s has no execution context", controllerName), http.StatusNotFound)
+		return
+	}
+	format := Format(req.URL.Query().Get("format"))
+	b, err := ExportExecutionContext(ceCtx).Render(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch format {
+	case FormatDOT:
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+	case FormatMermaid:
+		w.Header().Set("Content-Type", "text/plain")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Write(b)
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dagexport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatDOT     Format = "dot"
+	FormatMermaid Format = "mermaid"
+	FormatJSON    Format = "json"
+)
+
+// Render renders the document in the requested format
+func (r *Document) Render(format Format) ([]byte, error) {
+	switch format {
+	case FormatDOT:
+		return r.DOT(), nil
+	case FormatMermaid:
+		return r.Mermaid(), nil
+	case FormatJSON, "":
+		return r.JSON()
+	default:
+		return nil, fmt.Errorf("unsupported format: %s, supported formats: %s, %s, %s", format, FormatDOT, FormatMermaid, FormatJSON)
+	}
+}
+
+func (r *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// DOT renders the document as a graphviz digraph, every DAG and nested block
+// DAG is rendered as a cluster
+func (r *Document) DOT() []byte {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "digraph %s {\n", dotQuote(r.Controller))
+	fmt.Fprintf(b, "  rankdir=LR;\n")
+	fmt.Fprintf(b, "  node [shape=box, fontname=\"Helvetica\"];\n")
+	for i, d := range r.DAGs {
+		writeDOTCluster(b, fmt.Sprintf("c%d", i), d, 1)
+	}
+	fmt.Fprintf(b, "}\n")
+	return b.Bytes()
+}
+
+func writeDOTCluster(b *bytes.Buffer, id string, d *DAG, indent int) {
+	ind := strings.Repeat("  ", indent)
+	fmt.Fprintf(b, "%ssubgraph %s {\n", ind, dotQuote("cluster_"+id))
+	fmt.Fprintf(b, "%s  label=%s;\n", ind, dotQuote(d.Name))
+	for _, v := range d.Vertices {
+		fmt.Fprintf(b, "%s  %s [label=%s];\n", ind, dotQuote(nodeID(id, v.Name)), dotQuote(strings.Join(vertexLabel(v), "\n")))
+	}
+	for _, v := range d.Vertices {
+		for _, down := range v.DownVertices {
+			fmt.Fprintf(b, "%s  %s -> %s;\n", ind, dotQuote(nodeID(id, v.Name)), dotQuote(nodeID(id, down)))
+		}
+	}
+	for _, v := range d.Vertices {
+		if v.Block == nil {
+			continue
+		}
+		blockID := nodeID(id, v.Name)
+		writeDOTCluster(b, blockID, v.Block, indent+1)
+		if v.Block.Root != "" {
+			fmt.Fprintf(b, "%s  %s -> %s [style=dashed];\n", ind, dotQuote(nodeID(id, v.Name)), dotQuote(nodeID(blockID, v.Block.Root)))
+		}
+	}
+	fmt.Fprintf(b, "%s}\n", ind)
+}
+
+// Mermaid renders the document as a mermaid flowchart, every DAG and nested
+// block DAG is rendered as a subgraph
+func (r *Document) Mermaid() []byte {
+	b := &bytes.Buffer{}
+	m := &mermaid{b: b, ids: map[string]string{}}
+	fmt.Fprintf(b, "flowchart LR\n")
+	for i, d := range r.DAGs {
+		m.writeSubgraph(fmt.Sprintf("c%d", i), d, 1)
+	}
+	return b.Bytes()
+}
+
+type mermaid struct {
+	b   *bytes.Buffer
+	ids map[string]string
+}
+
+// id returns a mermaid safe identifier for the node
+func (r *mermaid) id(s string) string {
+	if id, ok := r.ids[s]; ok {
+		return id
+	}
+	id := fmt.Sprintf("n%d", len(r.ids))
+	r.ids[s] = id
+	return id
+}
+
+func (r *mermaid) writeSubgraph(id string, d *DAG, indent int) {
+	ind := strings.Repeat("  ", indent)
+	fmt.Fprintf(r.b, "%ssubgraph %s [%s]\n", ind, r.id("cluster_"+id), mermaidQuote(d.Name))
+	for _, v := range d.Vertices {
+		fmt.Fprintf(r.b, "%s  %s[%s]\n", ind, r.id(nodeID(id, v.Name)), mermaidQuote(strings.Join(vertexLabel(v), "<br/>")))
+	}
+	for _, v := range d.Vertices {
+		for _, down := range v.DownVertices {
+			fmt.Fprintf(r.b, "%s  %s --> %s\n", ind, r.id(nodeID(id, v.Name)), r.id(nodeID(id, down)))
+		}
+	}
+	for _, v := range d.Vertices {
+		if v.Block == nil {
+			continue
+		}
+		blockID := nodeID(id, v.Name)
+		r.writeSubgraph(blockID, v.Block, indent+1)
+		if v.Block.Root != "" {
+			fmt.Fprintf(r.b, "%s  %s -.-> %s\n", ind, r.id(nodeID(id, v.Name)), r.id(nodeID(blockID, v.Block.Root)))
+		}
+	}
+	fmt.Fprintf(r.b, "%send\n", ind)
+}
+
+func vertexLabel(v *Vertex) []string {
+	label := []string{v.Name}
+	if v.Type != "" {
+		label = append(label, fmt.Sprintf("type: %s", v.Type))
+	} else {
+		label = append(label, fmt.Sprintf("kind: %s", v.Kind))
+	}
+	if v.Image != "" {
+		label = append(label, fmt.Sprintf("image: %s", v.Image))
+	}
+	if v.Range != "" {
+		label = append(label, fmt.Sprintf("range: %s", v.Range))
+	}
+	if v.Condition != "" {
+		label = append(label, fmt.Sprintf("condition: %s", v.Condition))
+	}
+	if len(v.References) > 0 {
+		label = append(label, fmt.Sprintf("refs: %s", strings.Join(v.References, ", ")))
+	}
+	for _, o := range v.Outputs {
+		if o.GVK == "" {
+			continue
+		}
+		flags := []string{}
+		if o.Internal {
+			flags = append(flags, "internal")
+		}
+		if o.Conditioned {
+			flags = append(flags, "conditioned")
+		}
+		if len(flags) > 0 {
+			label = append(label, fmt.Sprintf("out %s: %s (%s)", o.VarName, o.GVK, strings.Join(flags, ",")))
+		} else {
+			label = append(label, fmt.Sprintf("out %s: %s", o.VarName, o.GVK))
+		}
+	}
+	return label
+}
+
+func nodeID(prefix, name string) string {
+	return prefix + "/" + name
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -100,6 +100,7 @@ func (r *execHandler) FunctionRun(ctx context.Context, vertexName string, vertex
 	}
 	//i.Print(vertexName)
 
+	ctx, stats := result.NewContextWithExecStats(ctx)
 	o, err := r.cfg.FnMap.Run(ctx, vc, i)
 	if err != nil {
 		if !errors.Is(err, ErrConditionFalse) {
@@ -122,6 +123,7 @@ func (r *execHandler) FunctionRun(ctx context.Context, vertexName string, vertex
 		Output:     o,
 		Success:    success,
 		Reason:     reason,
+		CacheHits:  stats.CacheHits(),
 	})
 	return success
 }
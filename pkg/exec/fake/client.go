@@ -0,0 +1,153 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides read-only clients and fn clients used to run the
+// pipelines offline, e.g. in the test harness and the replay player. Every
+// method is implemented so an unexpected call returns an error instead of
+// panicking.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var ErrReadOnly = errors.New("client is read-only")
+
+// Reader looks up the objects served by the client, a nil object without an
+// error is reported as not found
+type Reader interface {
+	Get(gvk schema.GroupVersionKind, key client.ObjectKey) (map[string]any, error)
+	List(gvk schema.GroupVersionKind, opts *client.ListOptions) ([]map[string]any, error)
+}
+
+// NewClient returns a read-only client serving the objects of the reader,
+// the writes return ErrReadOnly
+func NewClient(r Reader) client.Client {
+	return &readOnlyClient{
+		r:      r,
+		scheme: runtime.NewScheme(),
+		mapper: meta.NewDefaultRESTMapper(nil),
+	}
+}
+
+type readOnlyClient struct {
+	r      Reader
+	scheme *runtime.Scheme
+	mapper meta.RESTMapper
+}
+
+func (r *readOnlyClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	o, err := r.r.Get(gvk, key)
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		return apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}, key.Name)
+	}
+	b, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, obj)
+}
+
+func (r *readOnlyClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	gvk := list.GetObjectKind().GroupVersionKind()
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	lo := &client.ListOptions{}
+	lo.ApplyOptions(opts)
+	items, err := r.r.List(gvk, lo)
+	if err != nil {
+		return err
+	}
+	if items == nil {
+		items = []map[string]any{}
+	}
+	b, err := json.Marshal(map[string]any{
+		"apiVersion": gvk.GroupVersion().String(),
+		"kind":       gvk.Kind + "List",
+		"items":      items,
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, list)
+}
+
+func (r *readOnlyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return ErrReadOnly
+}
+
+func (r *readOnlyClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return ErrReadOnly
+}
+
+func (r *readOnlyClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return ErrReadOnly
+}
+
+func (r *readOnlyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return ErrReadOnly
+}
+
+func (r *readOnlyClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return ErrReadOnly
+}
+
+func (r *readOnlyClient) Status() client.SubResourceWriter {
+	return &readOnlySubResourceClient{}
+}
+
+func (r *readOnlyClient) SubResource(subResource string) client.SubResourceClient {
+	return &readOnlySubResourceClient{}
+}
+
+func (r *readOnlyClient) Scheme() *runtime.Scheme { return r.scheme }
+
+func (r *readOnlyClient) RESTMapper() meta.RESTMapper { return r.mapper }
+
+type readOnlySubResourceClient struct{}
+
+func (r *readOnlySubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	return ErrReadOnly
+}
+
+func (r *readOnlySubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return ErrReadOnly
+}
+
+func (r *readOnlySubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return ErrReadOnly
+}
+
+func (r *readOnlySubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return ErrReadOnly
+}
+
+var (
+	_ client.Client            = &readOnlyClient{}
+	_ client.SubResourceClient = &readOnlySubResourceClient{}
+)
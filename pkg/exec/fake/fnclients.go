@@ -0,0 +1,102 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/fnrunner/fnproto/pkg/executor/execclient"
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnproto/pkg/service/svcclient"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type ExecuteFunc func(ctx context.Context, in *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error)
+
+type ServiceFunc func(ctx context.Context, in *servicepb.FunctionServiceRequest) (*servicepb.FunctionServiceResponse, error)
+
+// FnFuncs implement the fn clients, a nil func returns Unimplemented
+type FnFuncs struct {
+	Execute        ExecuteFunc
+	ApplyResource  ServiceFunc
+	DeleteResource ServiceFunc
+}
+
+// NewFnClients returns fn clients calling the funcs
+func NewFnClients(f FnFuncs) *clients.Clients {
+	return &clients.Clients{
+		Execclient: &execClient{f: f},
+		Svcclient:  &svcClient{f: f},
+	}
+}
+
+// EchoResource is a service func returning the resource it is given
+func EchoResource(ctx context.Context, in *servicepb.FunctionServiceRequest) (*servicepb.FunctionServiceResponse, error) {
+	return &servicepb.FunctionServiceResponse{Resource: string(in.GetResource())}, nil
+}
+
+type execClient struct {
+	f FnFuncs
+}
+
+func (r *execClient) GetConfig() execclient.Config { return execclient.Config{} }
+
+func (r *execClient) Get() executorpb.FunctionExecutorClient { return r }
+
+func (r *execClient) Close() error { return nil }
+
+func (r *execClient) ExecuteFunction(ctx context.Context, in *executorpb.ExecuteFunctionRequest, opts ...grpc.CallOption) (*executorpb.ExecuteFunctionResponse, error) {
+	if r.f.Execute == nil {
+		return nil, status.Errorf(codes.Unimplemented, "execute not implemented for image %s", in.GetImage())
+	}
+	return r.f.Execute(ctx, in)
+}
+
+type svcClient struct {
+	f FnFuncs
+}
+
+func (r *svcClient) Get() servicepb.FunctionServiceClient { return r }
+
+func (r *svcClient) Close() error { return nil }
+
+func (r *svcClient) ApplyResource(ctx context.Context, in *servicepb.FunctionServiceRequest, opts ...grpc.CallOption) (*servicepb.FunctionServiceResponse, error) {
+	if r.f.ApplyResource == nil {
+		return nil, status.Errorf(codes.Unimplemented, "apply resource not implemented for image %s", in.GetImage())
+	}
+	return r.f.ApplyResource(ctx, in)
+}
+
+func (r *svcClient) DeleteResource(ctx context.Context, in *servicepb.FunctionServiceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	if r.f.DeleteResource == nil {
+		return nil, status.Errorf(codes.Unimplemented, "delete resource not implemented for image %s", in.GetImage())
+	}
+	if _, err := r.f.DeleteResource(ctx, in); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+var (
+	_ execclient.Client = &execClient{}
+	_ svcclient.Client  = &svcClient{}
+)
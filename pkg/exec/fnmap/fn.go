@@ -23,7 +23,9 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -38,6 +40,8 @@ type Function interface {
 	WithRootVertexName(name string)
 	WithFnClients(*clients.Clients)
 	WithControllerName(name string)
+	WithServices(services service.Services)
+	WithOperation(op ccsyntax.Operation)
 	Run(ctx context.Context, vertexContext *rtdag.VertexContext, i input.Input) (output.Output, error)
 }
 
@@ -90,3 +94,15 @@ func WithControllerName(name string) FunctionOption {
 		r.WithControllerName(name)
 	}
 }
+
+func WithServices(services service.Services) FunctionOption {
+	return func(r Function) {
+		r.WithServices(services)
+	}
+}
+
+func WithOperation(op ccsyntax.Operation) FunctionOption {
+	return func(r Function) {
+		r.WithOperation(op)
+	}
+}
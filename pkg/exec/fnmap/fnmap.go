@@ -25,8 +25,10 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
 	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -46,6 +48,8 @@ type Config struct {
 	Output         output.Output
 	Result         result.Result
 	FnClients      *clients.Clients
+	Services       service.Services
+	Operation      ccsyntax.Operation
 }
 
 func New(c *Config) FuncMap {
@@ -92,6 +96,8 @@ func (r *fnMap) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i i
 		fn.WithRootVertexName(r.cfg.RootVertexName)
 		fn.WithFnClients(r.cfg.FnClients)
 		fn.WithControllerName(r.cfg.ControllerName)
+		fn.WithServices(r.cfg.Services)
+		fn.WithOperation(r.cfg.Operation)
 	}
 	// run the function
 	return fn.Run(ctx, vertexContext, i)
@@ -34,7 +34,9 @@ type initOutputFn func(numItems int)
 type recordOutputFn func(any)
 type getFinalResultFn func() (output.Output, error)
 type filterInputFn func(input.Input) input.Input
-type runFn func(context.Context, input.Input) (any, error)
+// runFn runs an instance of the function, rangeIndex is the index of the
+// item when it runs in a range and nil otherwise
+type runFn func(ctx context.Context, i input.Input, rangeIndex *int) (any, error)
 
 type fnExecConfig struct {
 	executeRange  bool
@@ -116,7 +118,8 @@ func (r *fnExecConfig) exec(ctx context.Context, fnconfig ctrlcfgv1alpha1.Functi
 				if r.executeRange {
 					//extraInput := fec.prepareInputFn(fnconfig)
 					fi := r.filterInputFn(i)
-					x, err := r.runFn(ctx, fi)
+					idx := n
+					x, err := r.runFn(ctx, fi, &idx)
 					if err != nil {
 						return nil, err
 					}
@@ -136,7 +139,7 @@ func (r *fnExecConfig) exec(ctx context.Context, fnconfig ctrlcfgv1alpha1.Functi
 		}
 		//extraInput := fec.prepareInputFn(fnconfig)
 		fi := r.filterInputFn(i)
-		x, err := r.runFn(ctx, fi)
+		x, err := r.runFn(ctx, fi, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package functions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/input"
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+func TestExecRangeIndex(t *testing.T) {
+	var got []*int
+	fec := &fnExecConfig{
+		executeRange:  true,
+		executeSingle: true,
+		filterInputFn: func(i input.Input) input.Input { return i },
+		runFn: func(ctx context.Context, i input.Input, rangeIndex *int) (any, error) {
+			got = append(got, rangeIndex)
+			return nil, nil
+		},
+		initOutputFn:     func(numItems int) {},
+		recordOutputFn:   func(any) {},
+		getFinalResultFn: func() (output.Output, error) { return output.New(), nil },
+		l:                logr.Discard(),
+	}
+	fnconfig := ctrlcfgv1alpha1.Function{
+		Block: ctrlcfgv1alpha1.Block{
+			Range: &ctrlcfgv1alpha1.RangeValue{Value: "$items[]"},
+		},
+	}
+	i := input.New()
+	i.AddEntry("items", []any{"a", "b", "c"})
+	if _, err := fec.exec(context.Background(), fnconfig, i); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 3 range runs and a single run, got %d runs", len(got))
+	}
+	for n, idx := range got[:3] {
+		if idx == nil || *idx != n {
+			t.Errorf("expected range index %d, got %v", n, idx)
+		}
+	}
+	// the single run after the range has no range index
+	if got[3] != nil {
+		t.Errorf("expected no range index for the single run, got %d", *got[3])
+	}
+}
@@ -28,6 +28,8 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/fnrunner/fnutils/pkg/executor"
 	"github.com/go-logr/logr"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -102,6 +104,10 @@ func (r *block) WithControllerName(name string) {
 	r.controllerName = name
 }
 
+func (r *block) WithServices(services service.Services) {}
+
+func (r *block) WithOperation(op ccsyntax.Operation) {}
+
 func (r *block) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i input.Input) (output.Output, error) {
 	r.l.Info("run", "vertexName", vertexContext.VertexName, "input", i.Get())
 	// Here we prepare the input we get from the runtime
@@ -131,7 +137,7 @@ func (r *block) getFinalResult() (output.Output, error) {
 
 func (r *block) filterInput(i input.Input) input.Input { return i }
 
-func (r *block) run(ctx context.Context, i input.Input) (any, error) {
+func (r *block) run(ctx context.Context, i input.Input, _ *int) (any, error) {
 	// check if the dag is initialized
 	if r.d == nil {
 		err := fmt.Errorf("expecting an initialized dag, got: %T", r.d)
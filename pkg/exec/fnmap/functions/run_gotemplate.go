@@ -31,6 +31,8 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/go-logr/logr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -96,6 +98,10 @@ func (r *gt) WithControllerName(name string) {
 	r.controllerName = name
 }
 
+func (r *gt) WithServices(services service.Services) {}
+
+func (r *gt) WithOperation(op ccsyntax.Operation) {}
+
 func (r *gt) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i input.Input) (output.Output, error) {
 	r.l.Info("run", "vertexName", vertexContext.VertexName, "input", i.Get(), "resource", vertexContext.Function.Input.Resource.Raw)
 
@@ -142,7 +148,7 @@ func (r *gt) getFinalResult() (output.Output, error) {
 
 func (r *gt) filterInput(i input.Input) input.Input { return i }
 
-func (r *gt) run(ctx context.Context, i input.Input) (any, error) {
+func (r *gt) run(ctx context.Context, i input.Input, _ *int) (any, error) {
 	if r.template == "" {
 		err := errors.New("missing template")
 		r.l.Error(err, "cannot run gotemplate without a template")
@@ -23,15 +23,24 @@ import (
 	"sync"
 
 	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
 	"github.com/fnrunner/fnruntime/pkg/exec/fnmap"
 	"github.com/fnrunner/fnruntime/pkg/exec/input"
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/execstream"
 	"github.com/fnrunner/fnsdk/go/fn"
 	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -68,7 +77,10 @@ type image struct {
 	namespace      string
 	rootVertexName string
 	controllerName string
+	services       service.Services
+	operation      ccsyntax.Operation
 	// runtime config
+	vertexName   string
 	fnconfig     ctrlcfgv1alpha1.Function
 	outputs      output.Output
 	gvkToVarName map[string]string
@@ -113,6 +125,14 @@ func (r *image) WithControllerName(name string) {
 	r.controllerName = name
 }
 
+func (r *image) WithServices(services service.Services) {
+	r.services = services
+}
+
+func (r *image) WithOperation(op ccsyntax.Operation) {
+	r.operation = op
+}
+
 func (r *image) initOutput(numItems int) {
 	r.output = output.New()
 	r.numItems = numItems
@@ -124,6 +144,7 @@ func (r *image) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i i
 	// Here we prepare the input we get from the runtime
 	// e.g. DAG, outputs/outputInfo (internal/GVK/etc), fnConfig parameters, etc etc
 	r.fnconfig = vertexContext.Function
+	r.vertexName = vertexContext.VertexName
 	r.outputs = vertexContext.Outputs
 	r.gvkToVarName = vertexContext.GVKToVarName
 
@@ -133,7 +154,7 @@ func (r *image) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i i
 
 // run is an instance run of the function, if this is executed in a block
 // this is executed multiple time, once per block
-func (r *image) run(ctx context.Context, i input.Input) (any, error) {
+func (r *image) run(ctx context.Context, i input.Input, rangeIndex *int) (any, error) {
 	/*
 		runner, err := fnruntime.NewRunner(ctx, r.fnconfig,
 			fnruntime.RunnerOptions{
@@ -145,7 +166,7 @@ func (r *image) run(ctx context.Context, i input.Input) (any, error) {
 			return nil, err
 		}
 	*/
-	rCtx, err := buildResourceContext(i)
+	rCtx, err := r.buildResourceContext(i, rangeIndex)
 	if err != nil {
 		r.l.Error(err, "cannot build resource context")
 		return nil, err
@@ -154,20 +175,40 @@ func (r *image) run(ctx context.Context, i input.Input) (any, error) {
 	r.l.Info("client", "exec client config", r.clients.Execclient.GetConfig())
 	//fmt.Printf("exec client: %#v\n", r.clients.Execclient.GetConfig())
 
-	resp, err := r.clients.Execclient.Get().ExecuteFunction(ctx, &executorpb.ExecuteFunctionRequest{
-		ResourceContext: rCtx,
-		Image:           r.fnconfig.Image,
-		Controller:      r.controllerName,
-	})
-	if err != nil {
-		r.l.Error(err, "cannot execute function")
-		return nil, err
+	var header metadata.MD
+	var rctx *fn.ResourceContext
+	// large resource contexts are streamed in chunks, the unary call is used
+	// when the proxy does not support streaming
+	if r.clients.Execstream != nil && execstream.Size(rCtx) > execstream.DefaultChunkSize {
+		rctx, _, err = execstream.Execute(ctx, r.clients.Execstream, r.fnconfig.Image, r.controllerName, rCtx, execstream.DefaultChunkSize, grpc.Header(&header))
+		if err != nil && status.Code(err) != codes.Unimplemented {
+			r.l.Error(err, "cannot execute function")
+			return nil, err
+		}
 	}
-
-	rctx := &fn.ResourceContext{}
-	if err := json.Unmarshal(resp.ResourceContext, rctx); err != nil {
-		r.l.Error(err, "cannot unmarshal function exec response")
-		return nil, err
+	if rctx == nil {
+		b, err := json.Marshal(rCtx)
+		if err != nil {
+			r.l.Error(err, "cannot marshal resource context")
+			return nil, err
+		}
+		resp, err := r.clients.Execclient.Get().ExecuteFunction(ctx, &executorpb.ExecuteFunctionRequest{
+			ResourceContext: b,
+			Image:           r.fnconfig.Image,
+			Controller:      r.controllerName,
+		}, grpc.Header(&header))
+		if err != nil {
+			r.l.Error(err, "cannot execute function")
+			return nil, err
+		}
+		rctx = &fn.ResourceContext{}
+		if err := json.Unmarshal(resp.ResourceContext, rctx); err != nil {
+			r.l.Error(err, "cannot unmarshal function exec response")
+			return nil, err
+		}
+	}
+	if v := header.Get(fnrunv1alpha1.CacheHeaderKey); len(v) > 0 && v[0] == fnrunv1alpha1.CacheHit {
+		result.ExecStatsFromContext(ctx).AddCacheHit()
 	}
 
 	/*
@@ -177,7 +218,110 @@ func (r *image) run(ctx context.Context, i input.Input) (any, error) {
 			return nil, err
 		}
 	*/
-	return rctx, nil
+
+	// conditioned resources are resolved by the service owning the gvk
+	// before they get recorded and consumed by the downstream vertices
+	out, err := r.resolveConditionedResources(ctx, rctx)
+	if err != nil {
+		r.l.Error(err, "cannot resolve conditioned resources")
+		return nil, err
+	}
+	return out, nil
+}
+
+// imageOutput holds the resources of the function per gvk, every resource
+// is decoded once and shared by the resolution of the conditioned resources
+// and the recorded output
+type imageOutput map[string][]map[string]any
+
+// resolveConditionedResources decodes the resources of the function and
+// invokes the service function owning the gvk of every conditioned resource.
+// In the apply pipeline the resource is replaced with the resolved resource
+// returned by the service, in the delete pipeline the service is informed the
+// resource is no longer needed.
+func (r *image) resolveConditionedResources(ctx context.Context, rctx *fn.ResourceContext) (imageOutput, error) {
+	out := make(imageOutput, len(rctx.Resources))
+	for gvkString, krmslice := range rctx.Resources {
+		objs := make([]map[string]any, 0, len(krmslice))
+		for _, krm := range krmslice {
+			x := map[string]any{}
+			if err := json.Unmarshal(krm.Raw, &x); err != nil {
+				return nil, err
+			}
+			u := &unstructured.Unstructured{Object: x}
+			if r.isConditioned(gvkString, u) {
+				resolved, err := r.resolveConditionedResource(ctx, u, krm.Raw)
+				if err != nil {
+					return nil, err
+				}
+				x = resolved
+			}
+			objs = append(objs, x)
+		}
+		out[gvkString] = objs
+	}
+	return out, nil
+}
+
+// resolveConditionedResource invokes the service function owning the gvk of
+// the conditioned resource and returns the resolved resource
+func (r *image) resolveConditionedResource(ctx context.Context, u *unstructured.Unstructured, raw []byte) (map[string]any, error) {
+	gvk := u.GroupVersionKind()
+	if r.services == nil {
+		return nil, fmt.Errorf("no service registered for conditioned resource gvk: %s", gvk.String())
+	}
+	svcImage, ok := r.services.GetImage(gvk)
+	if !ok {
+		return nil, fmt.Errorf("no service registered for conditioned resource gvk: %s", gvk.String())
+	}
+	if r.clients == nil || r.clients.Svcclient == nil {
+		return nil, fmt.Errorf("no service client for conditioned resource gvk: %s", gvk.String())
+	}
+	r.l.Info("conditioned resource", "gvk", gvk.String(), "name", u.GetName(), "service", svcImage, "operation", r.operation)
+
+	req := &servicepb.FunctionServiceRequest{
+		Resource:   raw,
+		Image:      svcImage,
+		Controller: r.controllerName,
+	}
+	switch r.operation {
+	case ccsyntax.OperationDelete:
+		if _, err := r.clients.Svcclient.Get().DeleteResource(ctx, req); err != nil {
+			return nil, fmt.Errorf("cannot delete conditioned resource %s %s, err: %s", gvk.String(), u.GetName(), err.Error())
+		}
+		return u.Object, nil
+	default:
+		resp, err := r.clients.Svcclient.Get().ApplyResource(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot apply conditioned resource %s %s, err: %s", gvk.String(), u.GetName(), err.Error())
+		}
+		if resp.GetResource() == "" {
+			return nil, fmt.Errorf("cannot apply conditioned resource %s %s, err: empty service response", gvk.String(), u.GetName())
+		}
+		// the resource is replaced with the resolved conditioned resource
+		x := map[string]any{}
+		if err := json.Unmarshal([]byte(resp.GetResource()), &x); err != nil {
+			return nil, fmt.Errorf("cannot decode resolved conditioned resource %s %s, err: %s", gvk.String(), u.GetName(), err.Error())
+		}
+		return x, nil
+	}
+}
+
+// isConditioned returns true if the resource is labeled as conditioned by the
+// function or if the output variable it belongs to is declared conditioned
+func (r *image) isConditioned(gvkString string, u *unstructured.Unstructured) bool {
+	if _, ok := u.GetLabels()[fn.ConditionedResourceKey]; ok {
+		return true
+	}
+	varName, ok := r.gvkToVarName[gvkString]
+	if !ok || r.outputs == nil {
+		return false
+	}
+	oi, ok := r.outputs.GetValue(varName).(*output.OutputInfo)
+	if !ok {
+		return false
+	}
+	return oi.Conditioned
 }
 
 // recordOutput is executed per instance, if this is executed ina  block
@@ -185,14 +329,14 @@ func (r *image) run(ctx context.Context, i input.Input) (any, error) {
 func (r *image) recordOutput(o any) {
 	r.m.Lock()
 	defer r.m.Unlock()
-	rctx, ok := o.(*fn.ResourceContext)
+	out, ok := o.(imageOutput)
 	if !ok {
-		err := fmt.Errorf("expected type *rctxv1.ResourceContext, got: %T", o)
+		err := fmt.Errorf("expected type imageOutput, got: %T", o)
 		r.l.Error(err, "cannot record output")
 		r.errs = append(r.errs, err.Error())
 		return
 	}
-	for gvkString, krmslice := range rctx.Resources {
+	for gvkString, objs := range out {
 		r.l.Info("recordOutput", "gvkString", gvkString)
 		varName, ok := r.gvkToVarName[gvkString]
 		if !ok {
@@ -202,43 +346,8 @@ func (r *image) recordOutput(o any) {
 			continue
 		}
 
-		krmOutput := make([]any, 0, len(krmslice))
-		for _, krm := range krmslice {
-			u := unstructured.Unstructured{}
-			if err := json.Unmarshal(krm.Raw, &u); err != nil {
-				r.l.Error(err, "cannot unmarshal data")
-				r.errs = append(r.errs, err.Error())
-				break
-			}
-
-			/*
-				if _, ok := u.GetLabels()[fn.ConditionedResourceKey]; ok {
-					// invoke the service to get the condition resolved
-					// lookup service client
-					r.l.Info("conditioned resource", "gvkString", gvkString, "gvk", meta.GetGVKFromObject(&u))
-
-					// involke the service
-					resp, err := r.clients.Svcclient.Get().ApplyResource(context.Background(), &servicepb.FunctionServiceRequest{
-						Resource: krm.Raw,
-						Image:    r.fnconfig.Image,
-					})
-					if err != nil {
-						r.l.Error(err, "cannot apply service")
-						r.errs = append(r.errs, err.Error())
-						break
-					}
-					// replace the krm.Raw with the resolved conditional resource
-					krm.Raw = []byte(resp.GetResource())
-				}
-			*/
-
-			x := map[string]any{}
-			if err := json.Unmarshal(krm.Raw, &x); err != nil {
-				r.l.Error(err, "cannot unmarshal data")
-				r.errs = append(r.errs, err.Error())
-				break
-			}
-
+		krmOutput := make([]any, 0, len(objs))
+		for _, x := range objs {
 			krmOutput = append(krmOutput, x)
 		}
 
@@ -257,9 +366,10 @@ func (r *image) recordOutput(o any) {
 			break
 		}
 		r.output.AddEntry(varName, &output.OutputInfo{
-			Internal: oi.Internal,
-			GVK:      oi.GVK,
-			Data:     krmOutput,
+			Internal:    oi.Internal,
+			Conditioned: oi.Conditioned,
+			GVK:         oi.GVK,
+			Data:        krmOutput,
 		})
 	}
 }
@@ -269,103 +379,139 @@ func (r *image) getFinalResult() (output.Output, error) {
 		return nil, fmt.Errorf("errors executing image: %v", r.errs)
 	}
 
-	/* TODO add channel
-	for gvk, v := range r.output.GetConditionedOutput() {
-		// inform the
-	}
-	*/
 	//r.output.Print()
 
 	return r.output, nil
 }
 
 // for the image we filter the input
-// we convert to resourceContext and this might fail if we provide
-// unneccessary variables
+// we only provide the declared variables and the root to the function
 func (r *image) filterInput(i input.Input) input.Input {
 	newInput := input.New()
 	for varName, v := range i.Get() {
-		for ivarName := range r.fnconfig.Vars {
-			switch {
-			case varName == ivarName:
-				newInput.AddEntry(varName, v)
-			case varName == r.rootVertexName:
-				newInput.AddEntry(varName, v)
-			}
+		if varName == r.rootVertexName {
+			newInput.AddEntry(varName, v)
+			continue
+		}
+		if _, ok := r.fnconfig.Vars[varName]; ok {
+			newInput.AddEntry(varName, v)
 		}
 	}
 	return newInput
 }
 
-func buildResourceContext(i input.Input) ([]byte, error) {
-	resources, err := buildResourceContextResources(i)
+// buildResourceContext builds the resourceContext send to the function
+// KRM objects in the input are provided as resources, the other variables,
+// the function input and the controller context are provided in the
+// functionConfig. The range index is only set when the function runs in a
+// range.
+func (r *image) buildResourceContext(i input.Input, rangeIndex *int) (*fn.ResourceContext, error) {
+	resources, vars, err := buildResourceContextResources(i)
 	if err != nil {
 		return nil, err
 	}
 
-	rCtx := &fn.ResourceContext{
-		Resources: resources.Resources,
+	fnCtx := &fnrunv1alpha1.FunctionContext{
+		Controller: r.controllerName,
+		ForResource: fnrunv1alpha1.ResourceReference{
+			Name:      r.name,
+			Namespace: r.namespace,
+		},
+		VertexName: r.vertexName,
+		RangeIndex: rangeIndex,
+	}
+
+	functionConfig := map[string]runtime.RawExtension{}
+	if err := addFunctionConfig(functionConfig, fnrunv1alpha1.FunctionConfigContextKey, fnCtx); err != nil {
+		return nil, err
+	}
+	if len(vars) > 0 {
+		if err := addFunctionConfig(functionConfig, fnrunv1alpha1.FunctionConfigVarsKey, vars); err != nil {
+			return nil, err
+		}
+	}
+	if r.fnconfig.Input != nil {
+		if err := addFunctionConfig(functionConfig, fnrunv1alpha1.FunctionConfigInputKey, r.fnconfig.Input); err != nil {
+			return nil, err
+		}
 	}
 
-	return json.Marshal(rCtx)
+	return &fn.ResourceContext{
+		FunctionConfig: functionConfig,
+		Resources:      resources.Resources,
+	}, nil
+}
+
+func addFunctionConfig(functionConfig map[string]runtime.RawExtension, key string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cannot marshal functionConfig %s, err: %s", key, err.Error())
+	}
+	functionConfig[key] = runtime.RawExtension{Raw: b}
+	return nil
 }
 
-func buildResourceContextResources(i input.Input) (*fn.Resources, error) {
+// buildResourceContextResources splits the input in KRM resources and
+// the other variables
+func buildResourceContextResources(i input.Input) (*fn.Resources, map[string]any, error) {
 	resources := &fn.Resources{
 		Resources: map[string][]runtime.RawExtension{},
 	}
+	vars := map[string]any{}
 	//i.Print("runImage")
-	for _, v := range i.Get() {
-		switch x := v.(type) {
-		case map[string]any:
-			o, err := getObject(x)
-			if err != nil {
-				return nil, err
-			}
-			if err := resources.AddResource(o, &fn.ResourceParameters{}); err != nil {
-				return nil, err
+	for varName, v := range i.Get() {
+		if !isKRM(v) {
+			vars[varName] = v
+			continue
+		}
+		if err := addResources(resources, v); err != nil {
+			return nil, nil, err
+		}
+	}
+	return resources, vars, nil
+}
+
+// isKRM returns true if the value is a KRM object or a non empty list
+// (of lists) of KRM objects
+func isKRM(v any) bool {
+	switch x := v.(type) {
+	case map[string]any:
+		apiVersion, _ := x["apiVersion"].(string)
+		kind, _ := x["kind"].(string)
+		return apiVersion != "" && kind != ""
+	case []any:
+		if len(x) == 0 {
+			return false
+		}
+		for _, v := range x {
+			if !isKRM(v) {
+				return false
 			}
-		case []any:
-			l := len(x)
-			if l > 0 {
-				for _, v := range x {
-					switch x := v.(type) {
-					case map[string]any:
-						o, err := getObject(x)
-						if err != nil {
-							return nil, err
-						}
-						if err := resources.AddResource(o, &fn.ResourceParameters{}); err != nil {
-							return nil, err
-						}
-					case []any:
-						l := len(x)
-						if l > 0 {
-							for _, v := range x {
-								switch x := v.(type) {
-								case map[string]any:
-									o, err := getObject(x)
-									if err != nil {
-										return nil, err
-									}
-									if err := resources.AddResource(o, &fn.ResourceParameters{}); err != nil {
-										return nil, err
-									}
-								default:
-									return nil, fmt.Errorf("unexpected object in []any[]any: got %T", v)
-								}
-							}
-						}
-					default:
-						return nil, fmt.Errorf("unexpected object in []any: got %T", v)
-					}
-				}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func addResources(resources *fn.Resources, v any) error {
+	switch x := v.(type) {
+	case map[string]any:
+		o, err := getObject(x)
+		if err != nil {
+			return err
+		}
+		return resources.AddResource(o, &fn.ResourceParameters{})
+	case []any:
+		for _, v := range x {
+			if err := addResources(resources, v); err != nil {
+				return err
 			}
-		default:
-			return nil, fmt.Errorf("unexpected input object: got %T", v)
 		}
+		return nil
+	default:
+		return fmt.Errorf("unexpected input object: got %T", v)
 	}
-	return resources, nil
 }
 
 func getObject(x map[string]any) (fn.Object, error) {
@@ -0,0 +1,92 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package functions
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnruntime/pkg/exec/fake"
+	"github.com/fnrunner/fnruntime/pkg/exec/input"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/execstream"
+	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unimplementedStream is the streaming client of a proxy without the
+// streaming service
+type unimplementedStream struct {
+	calls int
+}
+
+func (r *unimplementedStream) ExecuteFunctionStream(ctx context.Context, opts ...grpc.CallOption) (execstream.ClientStream, error) {
+	r.calls++
+	return nil, status.Error(codes.Unimplemented, "unknown service")
+}
+
+func TestRunStreamFallback(t *testing.T) {
+	unary := 0
+	fnc := fake.NewFnClients(fake.FnFuncs{
+		Execute: func(ctx context.Context, in *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
+			unary++
+			return &executorpb.ExecuteFunctionResponse{ResourceContext: in.GetResourceContext()}, nil
+		},
+	})
+	stream := &unimplementedStream{}
+	fnc.Execstream = stream
+
+	r := &image{
+		controllerName: "controller",
+		fnconfig:       ctrlcfgv1alpha1.Function{Executor: ctrlcfgv1alpha1.Executor{Image: "image"}},
+		gvkToVarName:   map[string]string{},
+		clients:        fnc,
+		l:              logr.Discard(),
+	}
+	i := input.New()
+	// the resource context is larger than the chunk size and streamed
+	i.AddEntry("cm", map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "a"},
+		"data":       map[string]any{"a": strings.Repeat("x", execstream.DefaultChunkSize)},
+	})
+
+	o, err := r.run(context.Background(), i, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.calls != 1 || unary != 1 {
+		t.Errorf("expected a streamed call falling back to a unary call, got %d streamed and %d unary calls", stream.calls, unary)
+	}
+	out, ok := o.(imageOutput)
+	if !ok {
+		t.Fatalf("expected the image output, got %T", o)
+	}
+	for _, objs := range out {
+		if len(objs) != 1 || objs[0]["kind"] != "ConfigMap" {
+			t.Errorf("expected the configmap in the output, got %v", objs)
+		}
+	}
+	if len(out) != 1 {
+		t.Errorf("expected a single gvk in the output, got %d", len(out))
+	}
+}
@@ -26,6 +26,8 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/go-logr/logr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -90,6 +92,10 @@ func (r *jq) WithControllerName(name string) {
 	r.controllerName = name
 }
 
+func (r *jq) WithServices(services service.Services) {}
+
+func (r *jq) WithOperation(op ccsyntax.Operation) {}
+
 func (r *jq) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i input.Input) (output.Output, error) {
 	r.l.Info("run", "vertexName", vertexContext.VertexName, "input", i.Get(), "expression", vertexContext.Function.Input.Expression)
 
@@ -129,6 +135,6 @@ func (r *jq) getFinalResult() (output.Output, error) {
 
 func (r *jq) filterInput(i input.Input) input.Input { return i }
 
-func (r *jq) run(ctx context.Context, i input.Input) (any, error) {
+func (r *jq) run(ctx context.Context, i input.Input, _ *int) (any, error) {
 	return runJQ(r.expression, i)
 }
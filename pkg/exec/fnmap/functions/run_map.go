@@ -28,6 +28,8 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/go-logr/logr"
 	"github.com/itchyny/gojq"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -106,6 +108,10 @@ func (r *kv) WithControllerName(name string) {
 	r.controllerName = name
 }
 
+func (r *kv) WithServices(services service.Services) {}
+
+func (r *kv) WithOperation(op ccsyntax.Operation) {}
+
 func (r *kv) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i input.Input) (output.Output, error) {
 	r.l.Info("run", "vertexName", vertexContext.VertexName, "input", i.Get(), "key", vertexContext.Function.Input.Key, "value", vertexContext.Function.Input.Value)
 
@@ -155,7 +161,7 @@ func (r *kv) getFinalResult() (output.Output, error) {
 
 func (r *kv) filterInput(i input.Input) input.Input { return i }
 
-func (r *kv) run(ctx context.Context, i input.Input) (any, error) {
+func (r *kv) run(ctx context.Context, i input.Input, _ *int) (any, error) {
 	kv := &mapInput{
 		key:   r.key,
 		value: r.value,
@@ -26,6 +26,8 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/fnrunner/fnutils/pkg/meta"
 	"github.com/go-logr/logr"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -99,6 +101,10 @@ func (r *query) WithControllerName(name string) {
 	r.controllerName = name
 }
 
+func (r *query) WithServices(services service.Services) {}
+
+func (r *query) WithOperation(op ccsyntax.Operation) {}
+
 func (r *query) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i input.Input) (output.Output, error) {
 	r.l.Info("run", "vertexName", vertexContext.VertexName, "input", i.Get(), "resource", vertexContext.Function.Input.Resource)
 	// Here we prepare the input we get from the runtime
@@ -138,7 +144,7 @@ func (r *query) getFinalResult() (output.Output, error) {
 
 func (r *query) filterInput(i input.Input) input.Input { return i }
 
-func (r *query) run(ctx context.Context, i input.Input) (any, error) {
+func (r *query) run(ctx context.Context, i input.Input, _ *int) (any, error) {
 	gvk, err := meta.GetGVKFromRuntimeRawExtension(r.resource)
 	if err != nil {
 		r.l.Error(err, "cannot get GVK")
@@ -28,6 +28,8 @@ import (
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
 	"github.com/fnrunner/fnruntime/pkg/exec/result"
 	"github.com/fnrunner/fnruntime/pkg/exec/rtdag"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	"github.com/go-logr/logr"
 	"github.com/itchyny/gojq"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -94,6 +96,10 @@ func (r *slice) WithControllerName(name string) {
 	r.controllerName = name
 }
 
+func (r *slice) WithServices(services service.Services) {}
+
+func (r *slice) WithOperation(op ccsyntax.Operation) {}
+
 func (r *slice) Run(ctx context.Context, vertexContext *rtdag.VertexContext, i input.Input) (output.Output, error) {
 	r.l.Info("run", "vertexName", vertexContext.VertexName, "input", i.Get(), "expression", r.value)
 	// Here we prepare the input we get from the runtime
@@ -135,7 +141,7 @@ func (r *slice) getFinalResult() (output.Output, error) {
 
 func (r *slice) filterInput(i input.Input) input.Input { return i }
 
-func (r *slice) run(ctx context.Context, i input.Input) (any, error) {
+func (r *slice) run(ctx context.Context, i input.Input, _ *int) (any, error) {
 	if r.value == "" {
 		err := errors.New("missing input value")
 		r.l.Error(err, "wrong input value")
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/fnrunner/fnutils/pkg/kv"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -135,3 +136,16 @@ func (r *output) GetConditionedOutput() map[string]any {
 	}
 	return co
 }
+
+// ToUnstructured converts an object of the final output to unstructured
+func ToUnstructured(o any) (*unstructured.Unstructured, error) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(b, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Enforcer checks the final output against the output policy of a controller
+type Enforcer interface {
+	// Enforce returns a ViolationError listing the objects violating the
+	// policy. The for-resource is not checked since only its status is
+	// updated, the other objects of its GVK are checked like any object.
+	// The namespaced objects are expected to have a namespace, an object
+	// without namespace is cluster scoped and is not checked against the
+	// namespaces of the policy.
+	Enforce(forGVK schema.GroupVersionKind, forResource types.NamespacedName, objs []any) error
+}
+
+// IsForResource returns true if the object is the for-resource
+func IsForResource(u *unstructured.Unstructured, forGVK schema.GroupVersionKind, forResource types.NamespacedName) bool {
+	return u.GroupVersionKind() == forGVK &&
+		u.GetName() == forResource.Name &&
+		u.GetNamespace() == forResource.Namespace
+}
+
+func New(p *fnrunv1alpha1.OutputPolicy) Enforcer {
+	return &enforcer{p: p}
+}
+
+type enforcer struct {
+	p *fnrunv1alpha1.OutputPolicy
+}
+
+// ViolationError lists the policy violations of the output
+type ViolationError []*Violation
+
+type Violation struct {
+	GVK       string `json:"gvk"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+func (r ViolationError) Error() string {
+	s := make([]string, 0, len(r))
+	for _, v := range r {
+		if v.Name == "" {
+			s = append(s, fmt.Sprintf("%s: %s", v.GVK, v.Reason))
+			continue
+		}
+		key := v.Name
+		if v.Namespace != "" {
+			key = v.Namespace + "/" + v.Name
+		}
+		s = append(s, fmt.Sprintf("%s %s: %s", v.GVK, key, v.Reason))
+	}
+	return fmt.Sprintf("output policy violated: %s", strings.Join(s, "; "))
+}
+
+func (r *enforcer) Enforce(forGVK schema.GroupVersionKind, forResource types.NamespacedName, objs []any) error {
+	if r.p == nil {
+		return nil
+	}
+	verr := ViolationError{}
+	total := 0
+	counts := map[int]int{}
+	for _, o := range objs {
+		u, err := output.ToUnstructured(o)
+		if err != nil {
+			return err
+		}
+		if IsForResource(u, forGVK, forResource) {
+			continue
+		}
+		gvk := u.GroupVersionKind()
+		total++
+
+		idx, ok := r.resourcePolicy(gvk)
+		if !ok {
+			verr = append(verr, &Violation{
+				GVK:       gvk.String(),
+				Namespace: u.GetNamespace(),
+				Name:      u.GetName(),
+				Reason:    "gvk not allowed",
+			})
+			continue
+		}
+		if idx >= 0 {
+			counts[idx]++
+		}
+		if !r.namespaceAllowed(u.GetNamespace(), forResource.Namespace) {
+			verr = append(verr, &Violation{
+				GVK:       gvk.String(),
+				Namespace: u.GetNamespace(),
+				Name:      u.GetName(),
+				Reason:    fmt.Sprintf("namespace %q not allowed", u.GetNamespace()),
+			})
+		}
+	}
+	for idx, rp := range r.p.Resources {
+		if n := counts[idx]; rp.MaxObjects != nil && n > *rp.MaxObjects {
+			verr = append(verr, &Violation{
+				GVK:    fmt.Sprintf("%s, Kind=%s", rp.APIVersion, rp.Kind),
+				Reason: fmt.Sprintf("%d objects exceed the max of %d", n, *rp.MaxObjects),
+			})
+		}
+	}
+	if r.p.MaxObjects != nil && total > *r.p.MaxObjects {
+		verr = append(verr, &Violation{
+			GVK:    "*",
+			Reason: fmt.Sprintf("%d objects exceed the max of %d", total, *r.p.MaxObjects),
+		})
+	}
+	if len(verr) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// resourcePolicy returns the index of the resource policy allowing the gvk,
+// -1 when every gvk is allowed
+func (r *enforcer) resourcePolicy(gvk schema.GroupVersionKind) (int, bool) {
+	if len(r.p.Resources) == 0 {
+		return -1, true
+	}
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	for i, rp := range r.p.Resources {
+		if rp.APIVersion == apiVersion && (rp.Kind == kind || rp.Kind == "*") {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// namespaceAllowed returns true if the namespace of the object is allowed, the
+// cluster scoped objects are allowed by the resource policies only
+func (r *enforcer) namespaceAllowed(namespace, forNamespace string) bool {
+	if len(r.p.Namespaces) == 0 || namespace == "" {
+		return true
+	}
+	for _, ns := range r.p.Namespaces {
+		if ns == fnrunv1alpha1.NamespaceForResource {
+			ns = forNamespace
+		}
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
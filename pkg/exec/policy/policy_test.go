@@ -0,0 +1,205 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"testing"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	forGVK      = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Topology"}
+	forResource = types.NamespacedName{Namespace: "ns1", Name: "topo"}
+)
+
+func obj(apiVersion, kind, namespace, name string) map[string]any {
+	meta := map[string]any{"name": name}
+	if namespace != "" {
+		meta["namespace"] = namespace
+	}
+	return map[string]any{"apiVersion": apiVersion, "kind": kind, "metadata": meta}
+}
+
+func intPtr(i int) *int { return &i }
+
+func TestEnforce(t *testing.T) {
+	cases := map[string]struct {
+		policy *fnrunv1alpha1.OutputPolicy
+		objs   []any
+		// violations are the reasons of the expected violations
+		violations []string
+	}{
+		"NoPolicy": {
+			objs: []any{obj("v1", "Secret", "other", "s")},
+		},
+		"ForResourceSkipped": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Resources:  []fnrunv1alpha1.ResourcePolicy{{APIVersion: "v1", Kind: "ConfigMap"}},
+				Namespaces: []string{"other"},
+				MaxObjects: intPtr(1),
+			},
+			objs: []any{
+				obj("example.com/v1", "Topology", "ns1", "topo"),
+				obj("v1", "ConfigMap", "other", "cm"),
+			},
+		},
+		"ForGVKOtherName": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Resources: []fnrunv1alpha1.ResourcePolicy{{APIVersion: "v1", Kind: "ConfigMap"}},
+			},
+			objs:       []any{obj("example.com/v1", "Topology", "ns1", "other")},
+			violations: []string{"gvk not allowed"},
+		},
+		"ForGVKOtherNamespace": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Namespaces: []string{fnrunv1alpha1.NamespaceForResource},
+			},
+			objs:       []any{obj("example.com/v1", "Topology", "ns2", "topo")},
+			violations: []string{`namespace "ns2" not allowed`},
+		},
+		"GVKNotAllowed": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Resources: []fnrunv1alpha1.ResourcePolicy{{APIVersion: "v1", Kind: "ConfigMap"}},
+			},
+			objs: []any{
+				obj("v1", "ConfigMap", "ns1", "cm"),
+				obj("v1", "Secret", "ns1", "s"),
+			},
+			violations: []string{"gvk not allowed"},
+		},
+		"AnyKind": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Resources: []fnrunv1alpha1.ResourcePolicy{{APIVersion: "v1", Kind: "*"}},
+			},
+			objs: []any{
+				obj("v1", "ConfigMap", "ns1", "cm"),
+				obj("v1", "Secret", "ns1", "s"),
+			},
+		},
+		"NamespaceAllowed": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Namespaces: []string{fnrunv1alpha1.NamespaceForResource, "shared"},
+			},
+			objs: []any{
+				obj("v1", "ConfigMap", "ns1", "cm1"),
+				obj("v1", "ConfigMap", "shared", "cm2"),
+			},
+		},
+		"NamespaceNotAllowed": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Namespaces: []string{fnrunv1alpha1.NamespaceForResource},
+			},
+			objs:       []any{obj("v1", "ConfigMap", "kube-system", "cm")},
+			violations: []string{`namespace "kube-system" not allowed`},
+		},
+		"ClusterScoped": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Resources: []fnrunv1alpha1.ResourcePolicy{
+					{APIVersion: "v1", Kind: "ConfigMap"},
+					{APIVersion: "v1", Kind: "Namespace"},
+				},
+				Namespaces: []string{fnrunv1alpha1.NamespaceForResource},
+			},
+			objs: []any{
+				obj("v1", "ConfigMap", "ns1", "cm"),
+				obj("v1", "Namespace", "", "ns2"),
+			},
+		},
+		"ClusterScopedNotAllowed": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Resources:  []fnrunv1alpha1.ResourcePolicy{{APIVersion: "v1", Kind: "ConfigMap"}},
+				Namespaces: []string{fnrunv1alpha1.NamespaceForResource},
+			},
+			objs:       []any{obj("rbac.authorization.k8s.io/v1", "ClusterRole", "", "admin")},
+			violations: []string{"gvk not allowed"},
+		},
+		"MaxObjectsPerResource": {
+			policy: &fnrunv1alpha1.OutputPolicy{
+				Resources: []fnrunv1alpha1.ResourcePolicy{
+					{APIVersion: "v1", Kind: "ConfigMap", MaxObjects: intPtr(1)},
+					{APIVersion: "v1", Kind: "Secret", MaxObjects: intPtr(2)},
+				},
+			},
+			objs: []any{
+				obj("v1", "ConfigMap", "ns1", "cm1"),
+				obj("v1", "ConfigMap", "ns1", "cm2"),
+				obj("v1", "Secret", "ns1", "s1"),
+				obj("v1", "Secret", "ns1", "s2"),
+			},
+			violations: []string{"2 objects exceed the max of 1"},
+		},
+		"MaxObjects": {
+			policy: &fnrunv1alpha1.OutputPolicy{MaxObjects: intPtr(1)},
+			objs: []any{
+				obj("example.com/v1", "Topology", "ns1", "topo"),
+				obj("v1", "ConfigMap", "ns1", "cm1"),
+				obj("v1", "ConfigMap", "ns1", "cm2"),
+			},
+			violations: []string{"2 objects exceed the max of 1"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := New(tc.policy).Enforce(forGVK, forResource, tc.objs)
+			if len(tc.violations) == 0 {
+				if err != nil {
+					t.Fatalf("expected no violation, got: %v", err)
+				}
+				return
+			}
+			verr, ok := err.(ViolationError)
+			if !ok {
+				t.Fatalf("expected a ViolationError, got: %v", err)
+			}
+			if len(verr) != len(tc.violations) {
+				t.Fatalf("expected %d violations, got: %v", len(tc.violations), verr)
+			}
+			for i, v := range verr {
+				if v.Reason != tc.violations[i] {
+					t.Errorf("expected violation %q, got %q", tc.violations[i], v.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestIsForResource(t *testing.T) {
+	cases := map[string]struct {
+		obj      map[string]any
+		expected bool
+	}{
+		"ForResource":    {obj: obj("example.com/v1", "Topology", "ns1", "topo"), expected: true},
+		"OtherName":      {obj: obj("example.com/v1", "Topology", "ns1", "other")},
+		"OtherNamespace": {obj: obj("example.com/v1", "Topology", "ns2", "topo")},
+		"OtherGVK":       {obj: obj("v1", "ConfigMap", "ns1", "topo")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			u, err := output.ToUnstructured(tc.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := IsForResource(u, forGVK, forResource); got != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
+)
+
+const BundleVersion = "v1"
+
+type CallKind string
+
+const (
+	CallKindExecute        CallKind = "execute"
+	CallKindApplyResource  CallKind = "applyResource"
+	CallKindDeleteResource CallKind = "deleteResource"
+)
+
+// Bundle is a self-contained record of everything external a run consumed
+type Bundle struct {
+	Version    string    `json:"version"`
+	RunID      string    `json:"runID,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	Controller string    `json:"controller"`
+	// Operation is the operation of the DAG that was run, apply or delete
+	Operation        ccsyntax.Operation                    `json:"operation"`
+	ControllerConfig *ctrlcfgv1alpha1.ControllerConfigSpec `json:"controllerConfig"`
+	ForResource      map[string]any                        `json:"forResource"`
+	Queries          []*Query                              `json:"queries,omitempty"`
+	Calls            []*Call                               `json:"calls,omitempty"`
+}
+
+// Query is the result of a read of the cluster state, a get when the name is
+// set, a list otherwise
+type Query struct {
+	// GVK in Kind.Version.Group format
+	GVK           string `json:"gvk"`
+	Namespace     string `json:"namespace,omitempty"`
+	Name          string `json:"name,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Items are the objects returned by the query
+	Items    []map[string]any `json:"items"`
+	NotFound bool             `json:"notFound,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// Call is a function request and the response received for it
+type Call struct {
+	Kind     CallKind `json:"kind"`
+	Image    string   `json:"image"`
+	Request  []byte   `json:"request"`
+	Response []byte   `json:"response,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func (r *Bundle) WriteFile(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+func ReadFile(path string) (*Bundle, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(b, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// Prune removes the oldest bundles in the dir until at most max bundles are
+// left
+func Prune(dir string, max int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	type bundleFile struct {
+		path    string
+		modTime time.Time
+	}
+	files := []bundleFile{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, bundleFile{path: filepath.Join(dir, e.Name()), modTime: info.ModTime()})
+	}
+	if len(files) <= max {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, f := range files[:len(files)-max] {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
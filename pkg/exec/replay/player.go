@@ -0,0 +1,175 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnruntime/pkg/exec/fake"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnutils/pkg/meta"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Player serves the recorded reads and function calls of a bundle
+type Player interface {
+	// Client returns a read-only client serving the recorded queries
+	Client() client.Client
+	// FnClients returns fn clients serving the recorded responses
+	FnClients() *clients.Clients
+	// Diverged returns the function calls whose request differs from the
+	// recorded request, the recorded response is returned for them
+	Diverged() []string
+}
+
+func NewPlayer(b *Bundle) Player {
+	return &player{
+		queries:  b.Queries,
+		calls:    b.Calls,
+		consumed: make([]bool, len(b.Calls)),
+		diverged: []string{},
+	}
+}
+
+type player struct {
+	queries []*Query
+
+	m        sync.Mutex
+	calls    []*Call
+	consumed []bool
+	diverged []string
+}
+
+func (r *player) Client() client.Client {
+	return fake.NewClient(&playerReader{p: r})
+}
+
+func (r *player) FnClients() *clients.Clients {
+	return fake.NewFnClients(fake.FnFuncs{
+		Execute:        r.execute,
+		ApplyResource:  r.applyResource,
+		DeleteResource: r.deleteResource,
+	})
+}
+
+func (r *player) Diverged() []string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.diverged
+}
+
+// call returns the recorded call matching the request, calls are matched
+// exactly first, otherwise the next unconsumed call for the image is used
+func (r *player) call(kind CallKind, image string, req []byte) (*Call, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	for idx, c := range r.calls {
+		if !r.consumed[idx] && c.Kind == kind && c.Image == image && bytes.Equal(c.Request, req) {
+			r.consumed[idx] = true
+			return c, nil
+		}
+	}
+	for idx, c := range r.calls {
+		if !r.consumed[idx] && c.Kind == kind && c.Image == image {
+			r.consumed[idx] = true
+			r.diverged = append(r.diverged, fmt.Sprintf("%s %s", kind, image))
+			return c, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no recorded %s call for image %s", kind, image)
+}
+
+func (r *player) query(gvk schema.GroupVersionKind, namespace, name, selector string) *Query {
+	for _, q := range r.queries {
+		if q.GVK == meta.GVKToString(&gvk) && q.Namespace == namespace && q.Name == name && q.LabelSelector == selector {
+			return q
+		}
+	}
+	return nil
+}
+
+type playerReader struct {
+	p *player
+}
+
+func (r *playerReader) Get(gvk schema.GroupVersionKind, key client.ObjectKey) (map[string]any, error) {
+	q := r.p.query(gvk, key.Namespace, key.Name, "")
+	switch {
+	case q == nil || q.NotFound || len(q.Items) == 0:
+		return nil, nil
+	case q.Error != "":
+		return nil, errors.New(q.Error)
+	}
+	return q.Items[0], nil
+}
+
+func (r *playerReader) List(gvk schema.GroupVersionKind, opts *client.ListOptions) ([]map[string]any, error) {
+	selector := ""
+	if opts.LabelSelector != nil {
+		selector = opts.LabelSelector.String()
+	}
+	q := r.p.query(gvk, opts.Namespace, "", selector)
+	if q == nil {
+		return nil, nil
+	}
+	if q.Error != "" {
+		return nil, errors.New(q.Error)
+	}
+	return q.Items, nil
+}
+
+func (r *player) execute(ctx context.Context, in *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
+	c, err := r.call(CallKindExecute, in.GetImage(), in.GetResourceContext())
+	if err != nil {
+		return nil, err
+	}
+	if c.Error != "" {
+		return nil, errors.New(c.Error)
+	}
+	return &executorpb.ExecuteFunctionResponse{ResourceContext: c.Response}, nil
+}
+
+func (r *player) applyResource(ctx context.Context, in *servicepb.FunctionServiceRequest) (*servicepb.FunctionServiceResponse, error) {
+	c, err := r.call(CallKindApplyResource, in.GetImage(), in.GetResource())
+	if err != nil {
+		return nil, err
+	}
+	if c.Error != "" {
+		return nil, errors.New(c.Error)
+	}
+	return &servicepb.FunctionServiceResponse{Resource: string(c.Response)}, nil
+}
+
+func (r *player) deleteResource(ctx context.Context, in *servicepb.FunctionServiceRequest) (*servicepb.FunctionServiceResponse, error) {
+	c, err := r.call(CallKindDeleteResource, in.GetImage(), in.GetResource())
+	if err != nil {
+		return nil, err
+	}
+	if c.Error != "" {
+		return nil, errors.New(c.Error)
+	}
+	return &servicepb.FunctionServiceResponse{}, nil
+}
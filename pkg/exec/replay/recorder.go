@@ -0,0 +1,258 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/fnrunner/fnproto/pkg/executor/execclient"
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnproto/pkg/service/svcclient"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
+	"github.com/fnrunner/fnutils/pkg/meta"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Recorder records the reads of the cluster state and the function calls of
+// a run in a bundle
+type Recorder interface {
+	// Client returns a client recording the gets and lists
+	Client(c client.Client) client.Client
+	// FnClients returns fn clients recording the requests and responses
+	FnClients(c *clients.Clients) *clients.Clients
+	// Bundle returns the recorded bundle
+	Bundle() *Bundle
+}
+
+type RecorderConfig struct {
+	RunID            string
+	Controller       string
+	Operation        ccsyntax.Operation
+	ControllerConfig *ctrlcfgv1alpha1.ControllerConfigSpec
+	ForResource      map[string]any
+}
+
+func NewRecorder(c *RecorderConfig) Recorder {
+	return &recorder{
+		b: &Bundle{
+			Version:          BundleVersion,
+			RunID:            c.RunID,
+			Timestamp:        time.Now(),
+			Controller:       c.Controller,
+			Operation:        c.Operation,
+			ControllerConfig: c.ControllerConfig,
+			ForResource:      runtime.DeepCopyJSON(c.ForResource),
+			Queries:          []*Query{},
+			Calls:            []*Call{},
+		},
+	}
+}
+
+type recorder struct {
+	m sync.Mutex
+	b *Bundle
+}
+
+func (r *recorder) Bundle() *Bundle {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.b
+}
+
+func (r *recorder) addQuery(q *Query) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.b.Queries = append(r.b.Queries, q)
+}
+
+func (r *recorder) addCall(c *Call) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.b.Calls = append(r.b.Calls, c)
+}
+
+func (r *recorder) Client(c client.Client) client.Client {
+	return &recordingClient{Client: c, r: r}
+}
+
+func (r *recorder) FnClients(c *clients.Clients) *clients.Clients {
+	// the executions are recorded unary, large resource contexts are not
+	// streamed while recording
+	return &clients.Clients{
+		Execclient: &recordingExecClient{Client: c.Execclient, r: r},
+		Svcclient:  &recordingSvcClient{Client: c.Svcclient, r: r},
+	}
+}
+
+type recordingClient struct {
+	client.Client
+	r *recorder
+}
+
+func (r *recordingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	err := r.Client.Get(ctx, key, obj, opts...)
+	q := &Query{
+		GVK:       meta.GVKToString(&gvk),
+		Namespace: key.Namespace,
+		Name:      key.Name,
+		Items:     []map[string]any{},
+	}
+	switch {
+	case apierrors.IsNotFound(err):
+		q.NotFound = true
+	case err != nil:
+		q.Error = err.Error()
+	default:
+		item, err := toMap(obj)
+		if err != nil {
+			return err
+		}
+		q.Items = append(q.Items, item)
+	}
+	r.r.addQuery(q)
+	return err
+}
+
+func (r *recordingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	gvk := list.GetObjectKind().GroupVersionKind()
+	err := r.Client.List(ctx, list, opts...)
+	lo := &client.ListOptions{}
+	lo.ApplyOptions(opts)
+	q := &Query{
+		GVK:       meta.GVKToString(&gvk),
+		Namespace: lo.Namespace,
+		Items:     []map[string]any{},
+	}
+	if lo.LabelSelector != nil {
+		q.LabelSelector = lo.LabelSelector.String()
+	}
+	if err != nil {
+		q.Error = err.Error()
+		r.r.addQuery(q)
+		return err
+	}
+	l, err := toMap(list)
+	if err != nil {
+		return err
+	}
+	if items, ok := l["items"].([]any); ok {
+		for _, item := range items {
+			if item, ok := item.(map[string]any); ok {
+				q.Items = append(q.Items, item)
+			}
+		}
+	}
+	r.r.addQuery(q)
+	return nil
+}
+
+func toMap(o any) (map[string]any, error) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type recordingExecClient struct {
+	execclient.Client
+	r *recorder
+}
+
+func (r *recordingExecClient) Get() executorpb.FunctionExecutorClient {
+	return &recordingExecutor{FunctionExecutorClient: r.Client.Get(), r: r.r}
+}
+
+type recordingExecutor struct {
+	executorpb.FunctionExecutorClient
+	r *recorder
+}
+
+func (r *recordingExecutor) ExecuteFunction(ctx context.Context, in *executorpb.ExecuteFunctionRequest, opts ...grpc.CallOption) (*executorpb.ExecuteFunctionResponse, error) {
+	resp, err := r.FunctionExecutorClient.ExecuteFunction(ctx, in, opts...)
+	c := &Call{
+		Kind:    CallKindExecute,
+		Image:   in.GetImage(),
+		Request: in.GetResourceContext(),
+	}
+	if err != nil {
+		c.Error = err.Error()
+	} else {
+		c.Response = resp.GetResourceContext()
+	}
+	r.r.addCall(c)
+	return resp, err
+}
+
+type recordingSvcClient struct {
+	svcclient.Client
+	r *recorder
+}
+
+func (r *recordingSvcClient) Get() servicepb.FunctionServiceClient {
+	return &recordingService{FunctionServiceClient: r.Client.Get(), r: r.r}
+}
+
+type recordingService struct {
+	servicepb.FunctionServiceClient
+	r *recorder
+}
+
+func (r *recordingService) ApplyResource(ctx context.Context, in *servicepb.FunctionServiceRequest, opts ...grpc.CallOption) (*servicepb.FunctionServiceResponse, error) {
+	resp, err := r.FunctionServiceClient.ApplyResource(ctx, in, opts...)
+	c := &Call{
+		Kind:    CallKindApplyResource,
+		Image:   in.GetImage(),
+		Request: in.GetResource(),
+	}
+	if err != nil {
+		c.Error = err.Error()
+	} else {
+		c.Response = []byte(resp.GetResource())
+	}
+	r.r.addCall(c)
+	return resp, err
+}
+
+func (r *recordingService) DeleteResource(ctx context.Context, in *servicepb.FunctionServiceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	resp, err := r.FunctionServiceClient.DeleteResource(ctx, in, opts...)
+	c := &Call{
+		Kind:    CallKindDeleteResource,
+		Image:   in.GetImage(),
+		Request: in.GetResource(),
+	}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	r.r.addCall(c)
+	return resp, err
+}
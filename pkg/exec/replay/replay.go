@@ -0,0 +1,92 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/builder"
+	"github.com/fnrunner/fnruntime/pkg/exec/ctrlcfg"
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"github.com/fnrunner/fnruntime/pkg/exec/result"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type Result struct {
+	Output output.Output
+	Result result.Result
+	// Diverged are the function calls whose request differs from the recording
+	Diverged []string
+}
+
+// Run re-runs the DAG of the bundle offline against the recorded cluster
+// state and function responses. The controller config of the bundle is
+// used unless a spec is provided, which allows bisecting config changes.
+func Run(ctx context.Context, b *Bundle, spec *ctrlcfgv1alpha1.ControllerConfigSpec) (*Result, error) {
+	if spec == nil {
+		spec = b.ControllerConfig
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("bundle %s without controller config", b.RunID)
+	}
+	cc, err := ctrlcfg.Parse(b.Controller, spec)
+	if err != nil {
+		return nil, err
+	}
+	services, err := service.NewFromControllerConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	op := b.Operation
+	if op == "" {
+		op = ccsyntax.OperationApply
+	}
+	gvk := cc.CeCtx.GetForGVK()
+	dagCtx := cc.CeCtx.GetDAGCtx(ccsyntax.FOWFor, gvk, op)
+	if dagCtx == nil || dagCtx.DAG == nil {
+		return nil, fmt.Errorf("controller %s has no %s dag", b.Controller, op)
+	}
+
+	cr := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(b.ForResource)}
+	p := NewPlayer(b)
+	res := &Result{
+		Output: output.New(),
+		Result: result.New(),
+	}
+	e := builder.New(&builder.Config{
+		Name:           cr.GetName(),
+		Namespace:      cr.GetNamespace(),
+		ControllerName: b.Controller,
+		Data:           cr.UnstructuredContent(),
+		Client:         p.Client(),
+		GVK:            gvk,
+		DAG:            dagCtx.DAG,
+		Output:         res.Output,
+		Result:         res.Result,
+		FnClients:      p.FnClients(),
+		Services:       services,
+		Operation:      op,
+	})
+	e.Run(ctx)
+	res.Diverged = p.Diverged()
+	return res, nil
+}
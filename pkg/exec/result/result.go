@@ -28,6 +28,9 @@ import (
 type Result interface {
 	slice.Slice
 	Print()
+	// Err returns the error of the first failed vertex, nil when the run
+	// succeeded
+	Err() error
 }
 
 type ExecType string
@@ -48,6 +51,8 @@ type ResultInfo struct {
 	Success     bool
 	Reason      string
 	BlockResult Result
+	// CacheHits is the amount of executions served from the memoization cache
+	CacheHits int
 }
 
 func New() Result {
@@ -72,6 +77,17 @@ func (r *result) Length() int {
 	return r.r.Length()
 }
 
+func (r *result) Err() error {
+	for _, v := range r.r.Get() {
+		ri, ok := v.(*ResultInfo)
+		if !ok || ri.Success {
+			continue
+		}
+		return fmt.Errorf("vertex %s of %s failed: %s", ri.VertexName, ri.ExecName, ri.Reason)
+	}
+	return nil
+}
+
 func (r *result) Print() {
 	totalSuccess := true
 	var totalDuration time.Duration
@@ -0,0 +1,59 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package result
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type execStatsKey struct{}
+
+// ExecStats collects statistics of the executions of a vertex, a vertex in a
+// range is executed multiple times
+type ExecStats struct {
+	cacheHits atomic.Int32
+}
+
+// NewContextWithExecStats returns a context in which the functions report
+// the statistics of their executions
+func NewContextWithExecStats(ctx context.Context) (context.Context, *ExecStats) {
+	s := &ExecStats{}
+	return context.WithValue(ctx, execStatsKey{}, s), s
+}
+
+// ExecStatsFromContext returns the exec stats of the context, nil if the
+// context has none
+func ExecStatsFromContext(ctx context.Context) *ExecStats {
+	s, _ := ctx.Value(execStatsKey{}).(*ExecStats)
+	return s
+}
+
+// AddCacheHit records an execution served from the memoization cache
+func (r *ExecStats) AddCacheHit() {
+	if r == nil {
+		return
+	}
+	r.cacheHits.Add(1)
+}
+
+func (r *ExecStats) CacheHits() int {
+	if r == nil {
+		return 0
+	}
+	return int(r.cacheHits.Load())
+}
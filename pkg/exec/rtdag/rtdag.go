@@ -18,7 +18,6 @@ package rtdag
 
 import (
 	"fmt"
-	"os"
 	"sync"
 
 	"github.com/fnrunner/fnruntime/pkg/exec/output"
@@ -32,6 +31,7 @@ type RuntimeDAG interface {
 	GetRootVertex() string
 	GetDependencyMap(from string)
 	PrintVertices()
+	Validate() Problems
 }
 
 func New() RuntimeDAG {
@@ -150,7 +150,7 @@ func (r *runtimeDAG) getDependencyMap(from string, indent int) {
 		found := r.checkVertex(upVertex)
 		if !found {
 			fmt.Printf("upVertex %s no found in vertices\n", upVertex)
-			os.Exit(1)
+			continue
 		}
 		fmt.Printf("-> %s\n", upVertex)
 	}
@@ -158,8 +158,8 @@ func (r *runtimeDAG) getDependencyMap(from string, indent int) {
 	for _, downVertex := range r.GetDownVertexes(from) {
 		found := r.checkVertex(downVertex)
 		if !found {
-			fmt.Printf("upVertex %s no found in vertices\n", downVertex)
-			os.Exit(1)
+			fmt.Printf("downVertex %s no found in vertices\n", downVertex)
+			continue
 		}
 		r.getDependencyMap(downVertex, indent)
 	}
@@ -0,0 +1,280 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rtdag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ProblemKind string
+
+const (
+	ProblemKindMissingRoot         ProblemKind = "missingRoot"
+	ProblemKindMissingVertex       ProblemKind = "missingVertex"
+	ProblemKindWrongContext        ProblemKind = "wrongContext"
+	ProblemKindUnreachableVertex   ProblemKind = "unreachableVertex"
+	ProblemKindUndeclaredReference ProblemKind = "undeclaredReference"
+	ProblemKindCycle               ProblemKind = "cycle"
+)
+
+// Problem is a structural problem found in a runtime DAG
+type Problem struct {
+	Kind ProblemKind `json:"kind"`
+	// Block is the path of block vertices to the DAG the problem was found in,
+	// empty for the top level DAG
+	Block      []string `json:"block,omitempty"`
+	VertexName string   `json:"vertexName,omitempty"`
+	Message    string   `json:"message"`
+}
+
+func (r Problem) String() string {
+	if len(r.Block) > 0 {
+		return fmt.Sprintf("%s: block %s vertex %s: %s", r.Kind, strings.Join(r.Block, "/"), r.VertexName, r.Message)
+	}
+	return fmt.Sprintf("%s: vertex %s: %s", r.Kind, r.VertexName, r.Message)
+}
+
+type Problems []Problem
+
+func (r Problems) Error() string {
+	s := make([]string, 0, len(r))
+	for _, p := range r {
+		s = append(s, p.String())
+	}
+	return strings.Join(s, "; ")
+}
+
+// local variables provided by the runtime to a range/block, they are not
+// declared as vertices in the DAG
+var runtimeVariables = map[string]struct{}{
+	"VALUE": {},
+	"KEY":   {},
+	"INDEX": {},
+}
+
+// Validate checks the DAG and its nested block DAGs for missing vertices,
+// vertices not reachable from the root, references to undeclared variables
+// and cycles, including cycles introduced by block nesting
+func (r *runtimeDAG) Validate() Problems {
+	return r.validate(nil, nil)
+}
+
+// validate validates the dag; scope contains the variables declared in the
+// enclosing DAGs of a block DAG
+func (r *runtimeDAG) validate(path []string, scope map[string]struct{}) Problems {
+	problems := Problems{}
+	record := func(kind ProblemKind, vertexName, format string, a ...any) {
+		problems = append(problems, Problem{
+			Kind:       kind,
+			Block:      path,
+			VertexName: vertexName,
+			Message:    fmt.Sprintf(format, a...),
+		})
+	}
+
+	vertices := r.GetVertices()
+	vertexNames := make([]string, 0, len(vertices))
+	for vertexName := range vertices {
+		vertexNames = append(vertexNames, vertexName)
+	}
+	sort.Strings(vertexNames)
+
+	// variables declared in this DAG: vertex names and their output variables
+	// block outputs are recorded in the global output, so the variables of
+	// the nested block DAGs are declared as well
+	declared := map[string]struct{}{}
+	for k := range scope {
+		declared[k] = struct{}{}
+	}
+	addDeclaredVariables(r, declared)
+
+	// missing vertices
+	for _, vertexName := range vertexNames {
+		for _, upVertex := range r.GetUpVertexes(vertexName) {
+			if !r.VertexExists(upVertex) {
+				record(ProblemKindMissingVertex, vertexName, "upVertex %s not found in vertices", upVertex)
+			}
+		}
+		for _, downVertex := range r.GetDownVertexes(vertexName) {
+			if !r.VertexExists(downVertex) {
+				record(ProblemKindMissingVertex, vertexName, "downVertex %s not found in vertices", downVertex)
+			}
+		}
+	}
+
+	// reachability from the root
+	rootVertexName := r.GetRootVertex()
+	if rootVertexName == "" {
+		record(ProblemKindMissingRoot, "", "no root vertex found")
+	} else {
+		reachable := map[string]struct{}{}
+		r.walkDown(rootVertexName, reachable)
+		for _, vertexName := range vertexNames {
+			if _, ok := reachable[vertexName]; !ok {
+				record(ProblemKindUnreachableVertex, vertexName, "vertex not reachable from root %s", rootVertexName)
+			}
+		}
+	}
+
+	// cycles within the DAG
+	for _, cycle := range r.findCycles(vertexNames) {
+		record(ProblemKindCycle, cycle[0], "cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	for _, vertexName := range vertexNames {
+		vc, ok := vertices[vertexName].(*VertexContext)
+		if !ok {
+			record(ProblemKindWrongContext, vertexName, "unexpected vertex context, got: %T", vertices[vertexName])
+			continue
+		}
+		// references to undeclared variables
+		for _, ref := range vc.References {
+			if _, ok := runtimeVariables[ref]; ok {
+				continue
+			}
+			if _, ok := declared[ref]; !ok {
+				record(ProblemKindUndeclaredReference, vertexName, "reference to undeclared variable %s", ref)
+			}
+		}
+		if vc.BlockDAG == nil {
+			continue
+		}
+		blockPath := append(append([]string{}, path...), vertexName)
+		// a block referencing a vertex of the enclosing DAG which depends on
+		// the block itself results in a cycle
+		downstream := map[string]struct{}{}
+		r.walkDown(vertexName, downstream)
+		// the root vertex of the block DAG carries the name of the block vertex
+		delete(downstream, vertexName)
+		for _, ref := range getBlockReferences(vc.BlockDAG) {
+			if _, ok := downstream[ref]; ok && r.VertexExists(ref) {
+				record(ProblemKindCycle, vertexName, "block references %s which depends on the block", ref)
+			}
+		}
+		if bd, ok := vc.BlockDAG.(*runtimeDAG); ok {
+			problems = append(problems, bd.validate(blockPath, declared)...)
+		}
+	}
+	return problems
+}
+
+// walkDown records all the vertices reachable from the vertex, including the vertex
+func (r *runtimeDAG) walkDown(from string, visited map[string]struct{}) {
+	if _, ok := visited[from]; ok {
+		return
+	}
+	visited[from] = struct{}{}
+	for _, downVertex := range r.GetDownVertexes(from) {
+		if r.VertexExists(downVertex) {
+			r.walkDown(downVertex, visited)
+		}
+	}
+}
+
+// findCycles returns the cycles found in the DAG using a depth first search
+func (r *runtimeDAG) findCycles(vertexNames []string) [][]string {
+	const (
+		white = iota
+		grey
+		black
+	)
+	color := map[string]int{}
+	cycles := [][]string{}
+	stack := []string{}
+
+	var visit func(vertexName string)
+	visit = func(vertexName string) {
+		color[vertexName] = grey
+		stack = append(stack, vertexName)
+		downVertices := append([]string{}, r.GetDownVertexes(vertexName)...)
+		sort.Strings(downVertices)
+		for _, downVertex := range downVertices {
+			if !r.VertexExists(downVertex) {
+				continue
+			}
+			switch color[downVertex] {
+			case white:
+				visit(downVertex)
+			case grey:
+				for i, s := range stack {
+					if s == downVertex {
+						cycle := append(append([]string{}, stack[i:]...), downVertex)
+						cycles = append(cycles, cycle)
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		color[vertexName] = black
+	}
+	for _, vertexName := range vertexNames {
+		if color[vertexName] == white {
+			visit(vertexName)
+		}
+	}
+	return cycles
+}
+
+// getBlockReferences returns the sorted unique references of the vertices in
+// the block DAG, including the nested block DAGs, so the validation errors
+// are stable across runs
+func getBlockReferences(d RuntimeDAG) []string {
+	seen := map[string]struct{}{}
+	addBlockReferences(d, seen)
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func addBlockReferences(d RuntimeDAG, seen map[string]struct{}) {
+	for _, v := range d.GetVertices() {
+		vc, ok := v.(*VertexContext)
+		if !ok {
+			continue
+		}
+		for _, ref := range vc.References {
+			seen[ref] = struct{}{}
+		}
+		if vc.BlockDAG != nil {
+			addBlockReferences(vc.BlockDAG, seen)
+		}
+	}
+}
+
+func addDeclaredVariables(d RuntimeDAG, declared map[string]struct{}) {
+	for vertexName, v := range d.GetVertices() {
+		declared[vertexName] = struct{}{}
+		vc, ok := v.(*VertexContext)
+		if !ok {
+			continue
+		}
+		if vc.Outputs != nil {
+			for varName := range vc.Outputs.Get() {
+				declared[varName] = struct{}{}
+			}
+		}
+		if vc.BlockDAG != nil {
+			addDeclaredVariables(vc.BlockDAG, declared)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rtdag
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+type testVertex struct {
+	name string
+	root bool
+	refs []string
+	// block is the block DAG of the vertex, its root vertex carries the name
+	// of the vertex
+	block *testDAG
+	// value replaces the vertex context
+	value any
+}
+
+type testDAG struct {
+	vertices []testVertex
+	// edges are the from, to pairs of the edges
+	edges [][2]string
+}
+
+func buildDAG(t *testing.T, td *testDAG) RuntimeDAG {
+	t.Helper()
+	d := New()
+	for _, v := range td.vertices {
+		if v.value != nil {
+			if err := d.AddVertex(v.name, v.value); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		vc := &VertexContext{VertexName: v.name, Kind: FunctionVertexKind}
+		if v.root {
+			vc.Kind = RootVertexKind
+		}
+		for _, ref := range v.refs {
+			vc.AddReference(ref)
+		}
+		if v.block != nil {
+			vc.BlockDAG = buildDAG(t, v.block)
+		}
+		if err := d.AddVertex(v.name, vc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range td.edges {
+		d.Connect(e[0], e[1])
+	}
+	return d
+}
+
+// problemKeys returns the sorted kind, block and vertex of the problems
+func problemKeys(problems Problems) []string {
+	keys := make([]string, 0, len(problems))
+	for _, p := range problems {
+		keys = append(keys, strings.Join([]string{string(p.Kind), strings.Join(p.Block, "/"), p.VertexName}, " "))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		dag *testDAG
+		// problems are the kind, block path and vertex of the expected
+		// problems
+		problems []string
+	}{
+		"Valid": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "a", refs: []string{"root"}},
+					{name: "b", refs: []string{"a", "root"}},
+				},
+				edges: [][2]string{{"root", "a"}, {"a", "b"}},
+			},
+		},
+		"MissingRoot": {
+			dag: &testDAG{
+				vertices: []testVertex{{name: "a"}},
+			},
+			problems: []string{"missingRoot  "},
+		},
+		"MissingVertex": {
+			dag: &testDAG{
+				vertices: []testVertex{{name: "root", root: true}},
+				edges:    [][2]string{{"root", "x"}},
+			},
+			problems: []string{"missingVertex  root"},
+		},
+		"UnreachableVertex": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "a"},
+				},
+			},
+			problems: []string{"unreachableVertex  a"},
+		},
+		"WrongContext": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "a", value: "not a vertex context"},
+				},
+				edges: [][2]string{{"root", "a"}},
+			},
+			problems: []string{"wrongContext  a"},
+		},
+		"DanglingReference": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "a", refs: []string{"root", "missing"}},
+				},
+				edges: [][2]string{{"root", "a"}},
+			},
+			problems: []string{"undeclaredReference  a"},
+		},
+		"RuntimeVariables": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "a", refs: []string{"VALUE", "KEY", "INDEX"}},
+				},
+				edges: [][2]string{{"root", "a"}},
+			},
+		},
+		"Cycle": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "a"},
+					{name: "b"},
+				},
+				edges: [][2]string{{"root", "a"}, {"a", "b"}, {"b", "a"}},
+			},
+			problems: []string{"cycle  a"},
+		},
+		"Block": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "a"},
+					{name: "blk", block: &testDAG{
+						vertices: []testVertex{
+							{name: "blk", root: true},
+							// the vertices of the enclosing DAG are in scope
+							{name: "inner", refs: []string{"a", "root", "VALUE"}},
+						},
+						edges: [][2]string{{"blk", "inner"}},
+					}},
+					// the variables of the block are declared in the enclosing DAG
+					{name: "c", refs: []string{"inner"}},
+				},
+				edges: [][2]string{{"root", "a"}, {"a", "blk"}, {"blk", "c"}},
+			},
+		},
+		"BlockDanglingReference": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "blk", block: &testDAG{
+						vertices: []testVertex{
+							{name: "blk", root: true},
+							{name: "inner", refs: []string{"missing"}},
+						},
+						edges: [][2]string{{"blk", "inner"}},
+					}},
+				},
+				edges: [][2]string{{"root", "blk"}},
+			},
+			problems: []string{"undeclaredReference blk inner"},
+		},
+		"BlockStructure": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "blk", block: &testDAG{
+						vertices: []testVertex{
+							{name: "blk", root: true},
+							{name: "inner1"},
+							{name: "inner2"},
+						},
+						edges: [][2]string{{"blk", "inner1"}, {"inner1", "x"}},
+					}},
+				},
+				edges: [][2]string{{"root", "blk"}},
+			},
+			problems: []string{"missingVertex blk inner1", "unreachableVertex blk inner2"},
+		},
+		"BlockCycle": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "blk", block: &testDAG{
+						vertices: []testVertex{
+							{name: "blk", root: true},
+							// c depends on the block
+							{name: "inner", refs: []string{"c"}},
+						},
+						edges: [][2]string{{"blk", "inner"}},
+					}},
+					{name: "c"},
+				},
+				edges: [][2]string{{"root", "blk"}, {"blk", "c"}},
+			},
+			problems: []string{"cycle  blk"},
+		},
+		"NestedBlock": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "a"},
+					{name: "blk1", block: &testDAG{
+						vertices: []testVertex{
+							{name: "blk1", root: true},
+							{name: "b"},
+							{name: "blk2", block: &testDAG{
+								vertices: []testVertex{
+									{name: "blk2", root: true},
+									// the vertices of every enclosing DAG are in scope
+									{name: "inner", refs: []string{"a", "b", "missing"}},
+								},
+								edges: [][2]string{{"blk2", "inner"}},
+							}},
+						},
+						edges: [][2]string{{"blk1", "b"}, {"b", "blk2"}},
+					}},
+				},
+				edges: [][2]string{{"root", "a"}, {"a", "blk1"}},
+			},
+			problems: []string{"undeclaredReference blk1/blk2 inner"},
+		},
+		"NestedBlockCycle": {
+			dag: &testDAG{
+				vertices: []testVertex{
+					{name: "root", root: true},
+					{name: "blk1", block: &testDAG{
+						vertices: []testVertex{
+							{name: "blk1", root: true},
+							{name: "blk2", block: &testDAG{
+								vertices: []testVertex{
+									{name: "blk2", root: true},
+									{name: "inner", refs: []string{"c"}},
+								},
+								edges: [][2]string{{"blk2", "inner"}},
+							}},
+						},
+						edges: [][2]string{{"blk1", "blk2"}},
+					}},
+					{name: "c"},
+				},
+				edges: [][2]string{{"root", "blk1"}, {"blk1", "c"}},
+			},
+			// the reference of the nested block is a reference of the block
+			problems: []string{"cycle  blk1"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := problemKeys(buildDAG(t, tc.dag).Validate())
+			expected := append([]string{}, tc.problems...)
+			sort.Strings(expected)
+			if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestProblemsError(t *testing.T) {
+	problems := Problems{
+		{Kind: ProblemKindCycle, VertexName: "a", Message: "cycle: a -> b -> a"},
+		{Kind: ProblemKindUndeclaredReference, Block: []string{"blk1", "blk2"}, VertexName: "inner", Message: "reference to undeclared variable x"},
+	}
+	expected := "cycle: vertex a: cycle: a -> b -> a; undeclaredReference: block blk1/blk2 vertex inner: reference to undeclared variable x"
+	if got := problems.Error(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+const (
+	defaultTTL      = 10 * time.Minute
+	jsonContentType = "application/json"
+)
+
+type DiscoveryConfig struct {
+	Client openapi.Client
+	// TTL is the time after which the schemas are fetched again, so changes
+	// to the CRDs get picked up
+	TTL time.Duration
+}
+
+// NewDiscoveryResolver returns a resolver fetching the openapi v3 documents
+// from the API server, the schemas are cached per GVK
+func NewDiscoveryResolver(c *DiscoveryConfig) Resolver {
+	r := &discoveryResolver{
+		client:  c.Client,
+		ttl:     c.TTL,
+		schemas: map[schema.GroupVersionKind]*cachedSchema{},
+	}
+	if r.ttl <= 0 {
+		r.ttl = defaultTTL
+	}
+	return r
+}
+
+type discoveryResolver struct {
+	client openapi.Client
+	ttl    time.Duration
+
+	// the document of a group version is fetched once for concurrent
+	// lookups, the lookups of other group versions are not blocked
+	g       singleflight.Group
+	m       sync.Mutex
+	schemas map[schema.GroupVersionKind]*cachedSchema
+}
+
+type cachedSchema struct {
+	schema  *spec.Schema
+	fetched time.Time
+}
+
+func (r *discoveryResolver) SchemaFor(gvk schema.GroupVersionKind) (*spec.Schema, error) {
+	if c, ok := r.getCached(gvk); ok {
+		return c.schema, nil
+	}
+	if _, err, _ := r.g.Do(gvk.GroupVersion().String(), func() (any, error) {
+		return nil, r.fetch(gvk)
+	}); err != nil {
+		return nil, err
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if c, ok := r.schemas[gvk]; ok {
+		return c.schema, nil
+	}
+	return nil, nil
+}
+
+func (r *discoveryResolver) getCached(gvk schema.GroupVersionKind) (*cachedSchema, bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	c, ok := r.schemas[gvk]
+	if !ok || time.Since(c.fetched) >= r.ttl {
+		return nil, false
+	}
+	return c, true
+}
+
+// fetch caches the schemas of every kind in the group version document of
+// the gvk, the gvk is cached without schema if it is not found
+func (r *discoveryResolver) fetch(gvk schema.GroupVersionKind) error {
+	paths, err := r.client.Paths()
+	if err != nil {
+		return err
+	}
+	schemas := map[schema.GroupVersionKind]*cachedSchema{}
+	now := time.Now()
+	if gv, ok := paths[groupVersionPath(gvk.GroupVersion())]; ok {
+		b, err := gv.Schema(jsonContentType)
+		if err != nil {
+			return err
+		}
+		d, err := parseDocument(b)
+		if err != nil {
+			return fmt.Errorf("cannot parse openapi document of %s: %s", gvk.GroupVersion(), err.Error())
+		}
+		for x := range d.gvks {
+			if x.GroupVersion() == gvk.GroupVersion() {
+				schemas[x] = &cachedSchema{schema: d.schemaFor(x), fetched: now}
+			}
+		}
+	}
+	if _, ok := schemas[gvk]; !ok {
+		schemas[gvk] = &cachedSchema{fetched: now}
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	for x, c := range schemas {
+		r.schemas[x] = c
+	}
+	return nil
+}
+
+func groupVersionPath(gv schema.GroupVersion) string {
+	if gv.Group == "" {
+		return fmt.Sprintf("api/%s", gv.Version)
+	}
+	return fmt.Sprintf("apis/%s/%s", gv.Group, gv.Version)
+}
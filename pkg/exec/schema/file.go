@@ -0,0 +1,80 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/yaml"
+)
+
+// NewFileResolver returns a resolver serving the schemas of the openapi v3
+// documents (json or yaml) in the dir, e.g. the output of
+// kubectl get --raw /openapi/v3/apis/<group>/<version>
+func NewFileResolver(dir string) (Resolver, error) {
+	files := []string{}
+	for _, ext := range []string{"*.json", "*.yaml", "*.yml"} {
+		f, err := filepath.Glob(filepath.Join(dir, ext))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f...)
+	}
+	sort.Strings(files)
+
+	r := &fileResolver{docs: map[schema.GroupVersionKind]*document{}}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		// yaml is a superset of json
+		b, err = yaml.YAMLToJSON(b)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %s", f, err.Error())
+		}
+		d, err := parseDocument(b)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %s", f, err.Error())
+		}
+		for gvk := range d.gvks {
+			r.docs[gvk] = d
+		}
+	}
+	return r, nil
+}
+
+type fileResolver struct {
+	m    sync.Mutex
+	docs map[schema.GroupVersionKind]*document
+}
+
+func (r *fileResolver) SchemaFor(gvk schema.GroupVersionKind) (*spec.Schema, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	d, ok := r.docs[gvk]
+	if !ok {
+		return nil, nil
+	}
+	return d.schemaFor(gvk), nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+const (
+	componentsSchemasPrefix = "#/components/schemas/"
+	extensionGVK            = "x-kubernetes-group-version-kind"
+	extensionIntOrString    = "x-kubernetes-int-or-string"
+)
+
+// Resolver returns the openapi v3 schema of a GVK
+type Resolver interface {
+	// SchemaFor returns the schema of the gvk with all references inlined,
+	// nil if the gvk has no schema
+	SchemaFor(gvk schema.GroupVersionKind) (*spec.Schema, error)
+}
+
+// document indexes the schemas of an openapi v3 document per gvk
+type document struct {
+	schemas  map[string]*spec.Schema
+	gvks     map[schema.GroupVersionKind]string
+	expanded map[string]*spec.Schema
+}
+
+func parseDocument(b []byte) (*document, error) {
+	o := &spec3.OpenAPI{}
+	if err := json.Unmarshal(b, o); err != nil {
+		return nil, err
+	}
+	d := &document{
+		schemas:  map[string]*spec.Schema{},
+		gvks:     map[schema.GroupVersionKind]string{},
+		expanded: map[string]*spec.Schema{},
+	}
+	if o.Components == nil {
+		return d, nil
+	}
+	d.schemas = o.Components.Schemas
+	for name, s := range d.schemas {
+		gvks := []schema.GroupVersionKind{}
+		if err := s.Extensions.GetObject(extensionGVK, &gvks); err != nil {
+			continue
+		}
+		for _, gvk := range gvks {
+			d.gvks[gvk] = name
+		}
+	}
+	return d, nil
+}
+
+func (r *document) schemaFor(gvk schema.GroupVersionKind) *spec.Schema {
+	name, ok := r.gvks[gvk]
+	if !ok {
+		return nil
+	}
+	return r.expand(&spec.Schema{SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef(componentsSchemasPrefix + name)}}, map[string]bool{})
+}
+
+// expand returns a copy of the schema with the references inlined since the
+// validator does not resolve them. Recursive references are replaced by an
+// empty schema which accepts any value.
+func (r *document) expand(s *spec.Schema, visiting map[string]bool) *spec.Schema {
+	if s == nil {
+		return nil
+	}
+	if ref := s.Ref.String(); ref != "" {
+		name := strings.TrimPrefix(ref, componentsSchemasPrefix)
+		if x, ok := r.expanded[name]; ok {
+			return x
+		}
+		target, ok := r.schemas[name]
+		if !ok || visiting[name] {
+			return &spec.Schema{}
+		}
+		visiting[name] = true
+		x := r.expand(target, visiting)
+		delete(visiting, name)
+		r.expanded[name] = x
+		return x
+	}
+
+	c := *s
+	if isIntOrString, _ := s.Extensions.GetBool(extensionIntOrString); isIntOrString {
+		c.Type = nil
+		c.Format = ""
+	}
+	if s.Items != nil {
+		c.Items = &spec.SchemaOrArray{Schema: r.expand(s.Items.Schema, visiting)}
+		for _, x := range s.Items.Schemas {
+			x := x
+			c.Items.Schemas = append(c.Items.Schemas, *r.expand(&x, visiting))
+		}
+	}
+	c.AllOf = r.expandSlice(s.AllOf, visiting)
+	c.OneOf = r.expandSlice(s.OneOf, visiting)
+	c.AnyOf = r.expandSlice(s.AnyOf, visiting)
+	c.Not = r.expand(s.Not, visiting)
+	c.Properties = r.expandMap(s.Properties, visiting)
+	c.PatternProperties = r.expandMap(s.PatternProperties, visiting)
+	if s.AdditionalProperties != nil {
+		c.AdditionalProperties = &spec.SchemaOrBool{
+			Allows: s.AdditionalProperties.Allows,
+			Schema: r.expand(s.AdditionalProperties.Schema, visiting),
+		}
+	}
+	return &c
+}
+
+func (r *document) expandSlice(schemas []spec.Schema, visiting map[string]bool) []spec.Schema {
+	if schemas == nil {
+		return nil
+	}
+	x := make([]spec.Schema, 0, len(schemas))
+	for _, s := range schemas {
+		s := s
+		x = append(x, *r.expand(&s, visiting))
+	}
+	return x
+}
+
+func (r *document) expandMap(schemas map[string]spec.Schema, visiting map[string]bool) map[string]spec.Schema {
+	if schemas == nil {
+		return nil
+	}
+	x := make(map[string]spec.Schema, len(schemas))
+	for k, s := range schemas {
+		s := s
+		x[k] = *r.expand(&s, visiting)
+	}
+	return x
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"github.com/go-logr/logr"
+	openapierrors "k8s.io/kube-openapi/pkg/validation/errors"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Validator validates objects against their openapi v3 schema
+type Validator interface {
+	// Validate validates the objects, it returns a ValidationError listing
+	// the invalid fields per object. Objects without a schema are not
+	// validated.
+	Validate(objs []any) error
+}
+
+func NewValidator(r Resolver) Validator {
+	return &validator{
+		resolver: r,
+		l:        ctrl.Log.WithName("schema validator"),
+	}
+}
+
+type validator struct {
+	resolver Resolver
+	l        logr.Logger
+}
+
+// ValidationError lists the invalid objects
+type ValidationError []*ObjectError
+
+type ObjectError struct {
+	GVK       string       `json:"gvk"`
+	Namespace string       `json:"namespace,omitempty"`
+	Name      string       `json:"name"`
+	Fields    []FieldError `json:"fields"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (r ValidationError) Error() string {
+	s := make([]string, 0, len(r))
+	for _, oe := range r {
+		fields := make([]string, 0, len(oe.Fields))
+		for _, fe := range oe.Fields {
+			fields = append(fields, fmt.Sprintf("%s: %s", fe.Field, fe.Message))
+		}
+		s = append(s, fmt.Sprintf("%s %s: [%s]", oe.GVK, oe.key(), strings.Join(fields, ", ")))
+	}
+	return fmt.Sprintf("invalid output: %s", strings.Join(s, "; "))
+}
+
+func (r *ObjectError) key() string {
+	if r.Namespace == "" {
+		return r.Name
+	}
+	return r.Namespace + "/" + r.Name
+}
+
+func (r *validator) Validate(objs []any) error {
+	verr := ValidationError{}
+	for _, o := range objs {
+		u, err := output.ToUnstructured(o)
+		if err != nil {
+			return err
+		}
+		gvk := u.GroupVersionKind()
+		s, err := r.resolver.SchemaFor(gvk)
+		if err != nil {
+			return fmt.Errorf("cannot get schema of %s: %s", gvk, err.Error())
+		}
+		if s == nil {
+			r.l.Info("no schema, skip validation", "gvk", gvk)
+			continue
+		}
+		res := validate.NewSchemaValidator(s, nil, "", strfmt.Default).Validate(pruneNulls(u.Object))
+		if res.IsValid() {
+			continue
+		}
+		oe := &ObjectError{
+			GVK:       gvk.String(),
+			Namespace: u.GetNamespace(),
+			Name:      u.GetName(),
+			Fields:    make([]FieldError, 0, len(res.Errors)),
+		}
+		for _, e := range res.Errors {
+			fe := FieldError{Message: e.Error()}
+			if ve, ok := e.(*openapierrors.Validation); ok {
+				fe.Field = ve.Name
+			}
+			oe.Fields = append(oe.Fields, fe)
+		}
+		sort.Slice(oe.Fields, func(i, j int) bool {
+			return oe.Fields[i].Field < oe.Fields[j].Field
+		})
+		verr = append(verr, oe)
+	}
+	if len(verr) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// pruneNulls drops the null values the API server ignores, e.g. the
+// creationTimestamp of a marshaled object
+func pruneNulls(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(x))
+		for k, v := range x {
+			if v == nil {
+				continue
+			}
+			m[k] = pruneNulls(v)
+		}
+		return m
+	case []any:
+		s := make([]any, 0, len(x))
+		for _, v := range x {
+			s = append(s, pruneNulls(v))
+		}
+		return s
+	default:
+		return v
+	}
+}
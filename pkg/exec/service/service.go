@@ -16,67 +16,78 @@ limitations under the License.
 
 package service
 
-/*
 import (
+	"fmt"
 	"sync"
 
 	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
+	"github.com/fnrunner/fnutils/pkg/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// Services maps the gvk of a conditioned resource to the image of the
+// service function that owns/resolves it
 type Services interface {
-	AddEntry(k schema.GroupVersionKind, v ServiceCtx)
-	Add(Services)
-	Get() map[schema.GroupVersionKind]ServiceCtx
-	GetValue(schema.GroupVersionKind) ServiceCtx
+	AddEntry(k schema.GroupVersionKind, image string)
+	Get() map[schema.GroupVersionKind]string
+	GetImage(k schema.GroupVersionKind) (string, bool)
 	Length() int
 }
 
 func New() Services {
 	return &service{
-		d: map[schema.GroupVersionKind]ServiceCtx{},
+		d: map[schema.GroupVersionKind]string{},
 	}
 }
 
-type service struct {
-	m sync.RWMutex
-	d map[schema.GroupVersionKind]ServiceCtx
-}
-
-type ServiceCtx struct {
-	Port int
-	Fn   ctrlcfgv1alpha1.Function
-	//Client fnservicepb.ServiceFunctionClient
+// NewFromControllerConfig builds the services based on the output gvks
+// of the services declared in the controller config
+func NewFromControllerConfig(ctrlcfg *ctrlcfgv1alpha1.ControllerConfigSpec) (Services, error) {
+	s := New()
+	for svcName, svc := range ctrlcfg.GetServices() {
+		if svc == nil {
+			continue
+		}
+		for _, o := range svc.Output {
+			gvk, err := meta.GetGVKFromRuntimeRawExtension(o.Resource)
+			if err != nil {
+				return nil, fmt.Errorf("service %s, err: %s", svcName, err.Error())
+			}
+			if image, ok := s.GetImage(*gvk); ok && image != svc.Image {
+				return nil, fmt.Errorf("service %s, duplicate gvk service entry: %s", svcName, gvk.String())
+			}
+			s.AddEntry(*gvk, svc.Image)
+		}
+	}
+	return s, nil
 }
 
-func (r *service) AddEntry(k schema.GroupVersionKind, v ServiceCtx) {
-	r.m.Lock()
-	defer r.m.Unlock()
-	r.d[k] = v
+type service struct {
+	m sync.RWMutex
+	d map[schema.GroupVersionKind]string
 }
 
-func (r *service) Add(o Services) {
+func (r *service) AddEntry(k schema.GroupVersionKind, image string) {
 	r.m.Lock()
 	defer r.m.Unlock()
-	for k, v := range o.Get() {
-		r.d[k] = v
-	}
+	r.d[k] = image
 }
 
-func (r *service) Get() map[schema.GroupVersionKind]ServiceCtx {
+func (r *service) Get() map[schema.GroupVersionKind]string {
 	r.m.RLock()
 	defer r.m.RUnlock()
-	d := make(map[schema.GroupVersionKind]ServiceCtx, len(r.d))
+	d := make(map[schema.GroupVersionKind]string, len(r.d))
 	for k, v := range r.d {
 		d[k] = v
 	}
 	return d
 }
 
-func (r *service) GetValue(k schema.GroupVersionKind) ServiceCtx {
+func (r *service) GetImage(k schema.GroupVersionKind) (string, bool) {
 	r.m.RLock()
 	defer r.m.RUnlock()
-	return r.d[k]
+	image, ok := r.d[k]
+	return image, ok
 }
 
 func (r *service) Length() int {
@@ -84,4 +95,3 @@ func (r *service) Length() int {
 	defer r.m.RUnlock()
 	return len(r.d)
 }
-*/
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnruntime/pkg/exec/fake"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewFixtureClient returns a read-only client serving the fixture objects
+func NewFixtureClient(objs []*unstructured.Unstructured) client.Client {
+	return fake.NewClient(&fixtures{objs: objs})
+}
+
+type fixtures struct {
+	objs []*unstructured.Unstructured
+}
+
+func (r *fixtures) Get(gvk schema.GroupVersionKind, key client.ObjectKey) (map[string]any, error) {
+	for _, o := range r.objs {
+		if o.GroupVersionKind() == gvk && o.GetNamespace() == key.Namespace && o.GetName() == key.Name {
+			return o.Object, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fixtures) List(gvk schema.GroupVersionKind, opts *client.ListOptions) ([]map[string]any, error) {
+	items := []map[string]any{}
+	for _, o := range r.objs {
+		if o.GroupVersionKind() != gvk {
+			continue
+		}
+		if opts.Namespace != "" && o.GetNamespace() != opts.Namespace {
+			continue
+		}
+		if opts.LabelSelector != nil && !opts.LabelSelector.Matches(labels.Set(o.GetLabels())) {
+			continue
+		}
+		items = append(items, o.Object)
+	}
+	return items, nil
+}
+
+// NewCannedFnClients returns fn clients returning the canned responses of
+// the images. Service functions return the resource they are given.
+func NewCannedFnClients(responses map[string]*Response) *clients.Clients {
+	return fake.NewFnClients(fake.FnFuncs{
+		Execute: func(ctx context.Context, in *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
+			resp, ok := responses[in.GetImage()]
+			if !ok {
+				return nil, status.Errorf(codes.NotFound, "no canned response for image %s", in.GetImage())
+			}
+			if resp.Error != "" {
+				return nil, errors.New(resp.Error)
+			}
+			b, err := json.Marshal(resp.ResourceContext)
+			if err != nil {
+				return nil, err
+			}
+			return &executorpb.ExecuteFunctionResponse{ResourceContext: b}, nil
+		},
+		ApplyResource:  fake.EchoResource,
+		DeleteResource: fake.EchoResource,
+	})
+}
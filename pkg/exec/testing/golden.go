@@ -0,0 +1,151 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"github.com/fnrunner/fnruntime/pkg/exec/policy"
+	"github.com/fnrunner/fnruntime/pkg/exec/result"
+	"github.com/fnrunner/fnruntime/pkg/exec/schema"
+	"github.com/fnrunner/fnruntime/pkg/exec/trace"
+	"sigs.k8s.io/yaml"
+)
+
+// Golden is the content of a golden file, the outputs and vertices are
+// sorted and the timings are left out so the file is stable across runs
+type Golden struct {
+	Success  bool      `json:"success"`
+	Outputs  []any     `json:"outputs"`
+	Vertices []*Vertex `json:"vertices"`
+	// PolicyViolations lists the outputs which violate the output policy of
+	// the controller configmap
+	PolicyViolations policy.ViolationError `json:"policyViolations,omitempty"`
+	// ValidationErrors lists the outputs which are invalid against the schemas
+	ValidationErrors schema.ValidationError `json:"validationErrors,omitempty"`
+}
+
+type Vertex struct {
+	VertexName string `json:"vertexName"`
+	Type       string `json:"type"`
+	ExecName   string `json:"execName,omitempty"`
+	Success    bool   `json:"success"`
+	Reason     string `json:"reason,omitempty"`
+	Output     any    `json:"output,omitempty"`
+}
+
+func newGolden(o output.Output, res result.Result) (*Golden, error) {
+	t := &trace.Trace{}
+	t.SetResult(res, nil)
+
+	g := &Golden{
+		Success:  t.Status == trace.StatusSuccess,
+		Outputs:  []any{},
+		Vertices: make([]*Vertex, 0, len(t.Vertices)),
+	}
+	outputs := map[string]any{}
+	for _, v := range o.GetFinalOutput() {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		outputs[string(b)] = v
+	}
+	keys := make([]string, 0, len(outputs))
+	for k := range outputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g.Outputs = append(g.Outputs, outputs[k])
+	}
+
+	for _, vt := range t.Vertices {
+		g.Vertices = append(g.Vertices, &Vertex{
+			VertexName: vt.VertexName,
+			Type:       vt.Type,
+			ExecName:   vt.ExecName,
+			Success:    vt.Success,
+			Reason:     vt.Reason,
+			Output:     vt.Output,
+		})
+	}
+	// vertices run concurrently so the order of the results is not stable
+	sort.SliceStable(g.Vertices, func(i, j int) bool {
+		return g.Vertices[i].key() < g.Vertices[j].key()
+	})
+	return g, nil
+}
+
+func (r *Vertex) key() string {
+	b, _ := json.Marshal(r.Output)
+	return strings.Join([]string{r.VertexName, r.ExecName, r.Type, string(b)}, "/")
+}
+
+func (r *Golden) Marshal() ([]byte, error) {
+	return yaml.Marshal(r)
+}
+
+// diff returns the lines which differ prefixed with - for the wanted lines
+// and + for the lines that were got, or an empty string if the content is
+// identical
+func diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	wl := strings.Split(want, "\n")
+	gl := strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of wl[i:] and gl[j:]
+	lcs := make([][]int, len(wl)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(gl)+1)
+	}
+	for i := len(wl) - 1; i >= 0; i-- {
+		for j := len(gl) - 1; j >= 0; j-- {
+			switch {
+			case wl[i] == gl[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(wl) || j < len(gl) {
+		switch {
+		case i < len(wl) && j < len(gl) && wl[i] == gl[j]:
+			i++
+			j++
+		case j == len(gl) || (i < len(wl) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&sb, "\n%4d - %s", i+1, wl[i])
+			i++
+		default:
+			fmt.Fprintf(&sb, "\n%4d + %s", j+1, gl[j])
+			j++
+		}
+	}
+	return sb.String()
+}
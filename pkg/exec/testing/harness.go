@@ -0,0 +1,226 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testing runs the pipelines of a controller config against test
+// cases and compares the results to golden files. A test case directory
+// holds the for-resource, the fixture objects served to the queries and
+// the canned function responses keyed by image.
+//
+//	func TestPipelines(t *testing.T) {
+//		h, err := fnrtesting.New(&fnrtesting.Config{ControllerConfig: "config.yaml"})
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		h.Test(t, "testdata")
+//	}
+//
+// The golden files are updated with go test -args -fnrun.update-golden
+package testing
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	gotesting "testing"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/builder"
+	"github.com/fnrunner/fnruntime/pkg/exec/ctrlcfg"
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"github.com/fnrunner/fnruntime/pkg/exec/policy"
+	"github.com/fnrunner/fnruntime/pkg/exec/result"
+	"github.com/fnrunner/fnruntime/pkg/exec/schema"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var updateGolden = flag.Bool("fnrun.update-golden", false, "update the golden files of the fnrun test cases")
+
+type Config struct {
+	// ControllerConfig is the path of a ControllerConfig or of a ConfigMap
+	// holding a controller config
+	ControllerConfig string
+	// Update writes the golden files instead of comparing them, it is also
+	// enabled by the fnrun.update-golden flag
+	Update bool
+	// FnClients overrides the canned responses of the test cases
+	FnClients *clients.Clients
+	// SchemaDir holds openapi v3 documents the final output of the apply
+	// dag is validated against, the errors are part of the golden file
+	SchemaDir string
+}
+
+type Harness interface {
+	// Run runs the apply and delete DAGs for the test case
+	Run(ctx context.Context, tc *TestCase) (map[ccsyntax.Operation]*Golden, error)
+	// TestCase runs the test case in the dir and compares the results to
+	// the golden files
+	TestCase(t *gotesting.T, dir string)
+	// Test runs every test case in the sub directories of dir as a subtest
+	Test(t *gotesting.T, dir string)
+}
+
+func New(c *Config) (Harness, error) {
+	cc, err := ctrlcfg.ReadFile(c.ControllerConfig)
+	if err != nil {
+		return nil, err
+	}
+	services, err := service.NewFromControllerConfig(cc.Spec)
+	if err != nil {
+		return nil, err
+	}
+	var validator schema.Validator
+	if c.SchemaDir != "" {
+		sr, err := schema.NewFileResolver(c.SchemaDir)
+		if err != nil {
+			return nil, err
+		}
+		validator = schema.NewValidator(sr)
+	}
+	return &harness{
+		cc:        cc,
+		services:  services,
+		update:    c.Update,
+		fnClients: c.FnClients,
+		validator: validator,
+	}, nil
+}
+
+type harness struct {
+	cc        *ctrlcfg.ControllerConfig
+	services  service.Services
+	update    bool
+	fnClients *clients.Clients
+	validator schema.Validator
+}
+
+func (r *harness) Run(ctx context.Context, tc *TestCase) (map[ccsyntax.Operation]*Golden, error) {
+	gvk := r.cc.CeCtx.GetForGVK()
+	if tc.ForResource.GroupVersionKind() != *gvk {
+		return nil, fmt.Errorf("test case %s: for-resource gvk %s, expected %s", tc.Name, tc.ForResource.GroupVersionKind(), gvk)
+	}
+	fnc := r.fnClients
+	if fnc == nil {
+		fnc = NewCannedFnClients(tc.Responses)
+	}
+
+	goldens := map[ccsyntax.Operation]*Golden{}
+	for _, op := range []ccsyntax.Operation{ccsyntax.OperationApply, ccsyntax.OperationDelete} {
+		dagCtx := r.cc.CeCtx.GetDAGCtx(ccsyntax.FOWFor, gvk, op)
+		if dagCtx == nil || dagCtx.DAG == nil {
+			continue
+		}
+		o := output.New()
+		res := result.New()
+		e := builder.New(&builder.Config{
+			Name:           tc.ForResource.GetName(),
+			Namespace:      tc.ForResource.GetNamespace(),
+			ControllerName: r.cc.Name,
+			Data:           runtime.DeepCopyJSON(tc.ForResource.Object),
+			Client:         NewFixtureClient(tc.Fixtures),
+			GVK:            gvk,
+			DAG:            dagCtx.DAG,
+			Output:         o,
+			Result:         res,
+			FnClients:      fnc,
+			Services:       r.services,
+			Operation:      op,
+		})
+		e.Run(ctx)
+		g, err := newGolden(o, res)
+		if err != nil {
+			return nil, err
+		}
+		if r.cc.Policy != nil && op == ccsyntax.OperationApply {
+			if err := policy.New(r.cc.Policy).Enforce(*gvk, types.NamespacedName{Namespace: tc.ForResource.GetNamespace(), Name: tc.ForResource.GetName()}, o.GetFinalOutput()); err != nil {
+				verr, ok := err.(policy.ViolationError)
+				if !ok {
+					return nil, err
+				}
+				g.Success = false
+				g.PolicyViolations = verr
+			}
+		}
+		if r.validator != nil && op == ccsyntax.OperationApply {
+			if err := r.validator.Validate(o.GetFinalOutput()); err != nil {
+				verr, ok := err.(schema.ValidationError)
+				if !ok {
+					return nil, err
+				}
+				g.Success = false
+				g.ValidationErrors = verr
+			}
+		}
+		goldens[op] = g
+	}
+	return goldens, nil
+}
+
+func (r *harness) TestCase(t *gotesting.T, dir string) {
+	t.Helper()
+	tc, err := LoadTestCase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goldens, err := r.Run(context.Background(), tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for op, g := range goldens {
+		got, err := g.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, GoldenDir, fmt.Sprintf("%s.yaml", op))
+		if r.update || *updateGolden {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("cannot read golden file, run with -fnrun.update-golden to create it: %s", err.Error())
+		}
+		if d := diff(string(want), string(got)); d != "" {
+			t.Errorf("%s dag of %s differs from golden file %s: %s", op, tc.Name, path, d)
+		}
+	}
+}
+
+func (r *harness) Test(t *gotesting.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		tcDir := filepath.Join(dir, e.Name())
+		if !e.IsDir() || !IsTestCase(tcDir) {
+			continue
+		}
+		t.Run(e.Name(), func(t *gotesting.T) {
+			r.TestCase(t, tcDir)
+		})
+	}
+}
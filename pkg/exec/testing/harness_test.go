@@ -0,0 +1,45 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"strings"
+	gotesting "testing"
+)
+
+func TestHarness(t *gotesting.T) {
+	h, err := New(&Config{ControllerConfig: "testdata/config.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Test(t, "testdata/cases")
+}
+
+func TestDiff(t *gotesting.T) {
+	if d := diff("a\nb\nc", "a\nb\nc"); d != "" {
+		t.Errorf("expected no diff, got: %s", d)
+	}
+	d := diff("a\nb\nc\nd", "a\nx\nc\ny")
+	for _, l := range []string{"2 - b", "2 + x", "4 - d", "4 + y"} {
+		if !strings.Contains(d, l) {
+			t.Errorf("expected %q in diff, got: %s", l, d)
+		}
+	}
+	if strings.Contains(d, " a") || strings.Contains(d, " c") {
+		t.Errorf("expected only the differing lines, got: %s", d)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fnrunner/fnsdk/go/fn"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// layout of a test case directory
+const (
+	// ForResourceFile holds the for-resource the DAGs are run for
+	ForResourceFile = "for.yaml"
+	// FixturesDir holds the objects served to the queries, a file can hold
+	// multiple yaml documents
+	FixturesDir = "fixtures"
+	// ResponsesFile holds the canned function responses keyed by image
+	ResponsesFile = "responses.yaml"
+	// GoldenDir holds the golden files, one per operation <operation>.yaml
+	GoldenDir = "golden"
+)
+
+// Response is the canned response of a function image, the resource context
+// is returned for every execution of the image unless an error is set
+type Response struct {
+	*fn.ResourceContext `json:",inline"`
+	Error               string `json:"error,omitempty"`
+}
+
+// TestCase is a for-resource with the state of the world it is run against
+type TestCase struct {
+	Name        string
+	Dir         string
+	ForResource *unstructured.Unstructured
+	Fixtures    []*unstructured.Unstructured
+	Responses   map[string]*Response
+}
+
+// LoadTestCase loads a test case from a directory
+func LoadTestCase(dir string) (*TestCase, error) {
+	tc := &TestCase{
+		Name:      filepath.Base(dir),
+		Dir:       dir,
+		Fixtures:  []*unstructured.Unstructured{},
+		Responses: map[string]*Response{},
+	}
+
+	objs, err := readObjects(filepath.Join(dir, ForResourceFile))
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) != 1 {
+		return nil, fmt.Errorf("test case %s: expecting 1 for-resource, got %d", tc.Name, len(objs))
+	}
+	tc.ForResource = objs[0]
+
+	files, err := filepath.Glob(filepath.Join(dir, FixturesDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		objs, err := readObjects(f)
+		if err != nil {
+			return nil, err
+		}
+		tc.Fixtures = append(tc.Fixtures, objs...)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, ResponsesFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(b, &tc.Responses); err != nil {
+			return nil, fmt.Errorf("test case %s: cannot unmarshal responses: %s", tc.Name, err.Error())
+		}
+	}
+	return tc, nil
+}
+
+// IsTestCase returns true if the directory holds a for-resource
+func IsTestCase(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ForResourceFile))
+	return err == nil
+}
+
+func readObjects(path string) ([]*unstructured.Unstructured, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	objs := []*unstructured.Unstructured{}
+	d := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), 4096)
+	for {
+		u := &unstructured.Unstructured{}
+		if err := d.Decode(&u.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, fmt.Errorf("file %s: %s", path, err.Error())
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		objs = append(objs, u)
+	}
+}
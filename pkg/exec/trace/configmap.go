@@ -0,0 +1,213 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fnrunner/fnutils/pkg/meta"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	traceLabelKey     = "fnrun.io/traces"
+	traceConfigMapKey = "traces.json"
+	// configmaps are limited to 1MiB, we keep some margin for the metadata
+	maxConfigMapDataSize = 900 * 1024
+	defaultTimeout       = 5 * time.Second
+	defaultFlushInterval = 30 * time.Second
+)
+
+// ConfigMapStore is a store persisting the traces in configmaps
+type ConfigMapStore interface {
+	Store
+	// Load loads the persisted traces in the in-memory store
+	Load(ctx context.Context) error
+	// Start persists the traces of the controllers with new traces every
+	// flush interval until the ctx is done, then it persists them once more
+	Start(ctx context.Context) error
+}
+
+type ConfigMapConfig struct {
+	Client    kubernetes.Interface
+	Namespace string
+	// Store is the in-memory store the configmap store persists
+	Store Store
+	// FlushInterval is the interval the new traces are persisted in, default
+	// 30s
+	FlushInterval time.Duration
+}
+
+// NewConfigMapStore returns a store which persists the traces of every
+// controller in a configmap <controller>-traces next to the in-memory store.
+// The oldest traces are dropped from the configmap if they don't fit. The
+// traces are persisted in batches, a controller with new traces is persisted
+// once per flush interval.
+func NewConfigMapStore(c *ConfigMapConfig) ConfigMapStore {
+	flushInterval := c.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	return &cmStore{
+		Store:         c.Store,
+		client:        c.Client,
+		namespace:     c.Namespace,
+		flushInterval: flushInterval,
+		dirty:         map[string]struct{}{},
+		l:             ctrl.Log.WithName("trace store"),
+	}
+}
+
+type cmStore struct {
+	Store
+	client        kubernetes.Interface
+	namespace     string
+	flushInterval time.Duration
+
+	m sync.Mutex
+	// dirty holds the controllers with traces which are not persisted yet
+	dirty map[string]struct{}
+	l     logr.Logger
+}
+
+func (r *cmStore) Add(t *Trace) {
+	r.Store.Add(t)
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.dirty[t.Controller] = struct{}{}
+}
+
+func (r *cmStore) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.flush()
+		case <-ctx.Done():
+			r.flush()
+			return nil
+		}
+	}
+}
+
+// flush persists the controllers with new traces
+func (r *cmStore) flush() {
+	r.m.Lock()
+	dirty := r.dirty
+	r.dirty = map[string]struct{}{}
+	r.m.Unlock()
+	for controller := range dirty {
+		r.persist(controller)
+	}
+}
+
+func (r *cmStore) Load(ctx context.Context) error {
+	cms, err := r.client.CoreV1().ConfigMaps(r.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: traceLabelKey,
+	})
+	if err != nil {
+		return err
+	}
+	for _, cm := range cms.Items {
+		traces := []*Trace{}
+		if err := json.Unmarshal([]byte(cm.Data[traceConfigMapKey]), &traces); err != nil {
+			r.l.Error(err, "cannot unmarshal traces", "configmap", cm.GetName())
+			continue
+		}
+		// traces are persisted newest first
+		for i := len(traces) - 1; i >= 0; i-- {
+			r.Store.Add(traces[i])
+		}
+	}
+	return nil
+}
+
+// persist persists the traces of the controller, it is only called by the
+// flush so the configmap of a controller is not updated concurrently
+func (r *cmStore) persist(controller string) {
+	b, err := marshalTraces(r.Store.List(&Filter{Controller: controller}), maxConfigMapDataSize)
+	if err != nil {
+		r.l.Error(err, "cannot marshal traces", "controller", controller)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	name := fmt.Sprintf("%s-traces", controller)
+	cm, err := r.client.CoreV1().ConfigMaps(r.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if meta.IgnoreNotFound(err) != nil {
+			r.l.Error(err, "cannot get trace configmap", "name", name)
+			return
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: r.namespace,
+				Labels:    map[string]string{traceLabelKey: controller},
+			},
+			Data: map[string]string{traceConfigMapKey: string(b)},
+		}
+		if _, err := r.client.CoreV1().ConfigMaps(r.namespace).Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			r.l.Error(err, "cannot create trace configmap", "name", name)
+		}
+		return
+	}
+	cm.Data = map[string]string{traceConfigMapKey: string(b)}
+	if _, err := r.client.CoreV1().ConfigMaps(r.namespace).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		r.l.Error(err, "cannot update trace configmap", "name", name)
+	}
+}
+
+// marshalTraces marshals the traces, newest first, in a json list up to the
+// max size. Every trace is marshaled once, the oldest traces which don't fit
+// are dropped.
+func marshalTraces(traces []*Trace, maxSize int) ([]byte, error) {
+	buf := bytes.NewBufferString("[")
+	for _, t := range traces {
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		// the separator and the closing bracket
+		sep := buf.Len() > 1
+		n := buf.Len() + len(b) + 1
+		if sep {
+			n++
+		}
+		if n > maxSize {
+			break
+		}
+		if sep {
+			buf.WriteByte(',')
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
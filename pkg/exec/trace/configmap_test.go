@@ -0,0 +1,71 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestMarshalTraces(t *testing.T) {
+	traces := []*Trace{}
+	for i := 0; i < 10; i++ {
+		traces = append(traces, &Trace{RunID: fmt.Sprint(i), Controller: "c", Name: "n"})
+	}
+	all, err := json.Marshal(traces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, err := json.Marshal(traces[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		maxSize int
+		want    int
+	}{
+		"all fit":    {maxSize: len(all), want: 10},
+		"oldest cut": {maxSize: len(all) - 1, want: 9},
+		"newest":     {maxSize: len(one) + 2, want: 1},
+		"none fit":   {maxSize: len(one), want: 0},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b, err := marshalTraces(traces, tc.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b) > tc.maxSize && tc.want > 0 {
+				t.Errorf("expected at most %d bytes, got %d", tc.maxSize, len(b))
+			}
+			got := []*Trace{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("expected a json list, got %s: %v", b, err)
+			}
+			if len(got) != tc.want {
+				t.Fatalf("expected %d traces, got %d", tc.want, len(got))
+			}
+			for i, tr := range got {
+				if tr.RunID != traces[i].RunID {
+					t.Errorf("expected the newest traces first, got %s at %d", tr.RunID, i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const HandlerPath = "/debug/traces/"
+
+// NewHandler returns a http handler serving the traces of the store
+//
+// GET /debug/traces/?controller=&namespace=&name=&status=success|failed&limit= -> list of traces
+// GET /debug/traces/<runID>                                                    -> trace of the run
+func NewHandler(s Store) http.Handler {
+	return &handler{store: s}
+}
+
+type handler struct {
+	store Store
+}
+
+func (r *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if runID := strings.Trim(strings.TrimPrefix(req.URL.Path, HandlerPath), "/"); runID != "" {
+		t := r.store.Get(runID)
+		if t == nil {
+			http.Error(w, fmt.Sprintf("run %s not found", runID), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, t)
+		return
+	}
+
+	q := req.URL.Query()
+	f := &Filter{
+		Controller: q.Get("controller"),
+		Namespace:  q.Get("namespace"),
+		Name:       q.Get("name"),
+		Status:     Status(q.Get("status")),
+	}
+	switch f.Status {
+	case "", StatusSuccess, StatusFailed:
+	default:
+		http.Error(w, fmt.Sprintf("unsupported status: %s", f.Status), http.StatusBadRequest)
+		return
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid limit: %s", limit), http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+	writeJSON(w, r.store.List(f))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
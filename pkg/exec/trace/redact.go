@@ -0,0 +1,87 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+// DefaultSensitiveKeys are the keys whose values are redacted by default,
+// matching is case insensitive and on a substring of the key
+var DefaultSensitiveKeys = []string{"password", "token", "secret", "privatekey", "credential"}
+
+type Redactor interface {
+	// Redact returns the value with the sensitive fields redacted, the value
+	// is a generic json value (map[string]any, []any or a scalar)
+	Redact(v any) any
+}
+
+// NewRedactor returns a redactor which redacts the data and stringData of
+// Secrets and the values of the keys containing one of the sensitive keys
+func NewRedactor(sensitiveKeys []string) Redactor {
+	keys := make([]string, 0, len(sensitiveKeys))
+	for _, k := range sensitiveKeys {
+		keys = append(keys, strings.ToLower(k))
+	}
+	return &redactor{keys: keys}
+}
+
+type redactor struct {
+	keys []string
+}
+
+func (r *redactor) Redact(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		if kind, _ := x["kind"].(string); kind == "Secret" {
+			for _, field := range []string{"data", "stringData"} {
+				if d, ok := x[field].(map[string]any); ok {
+					for k := range d {
+						d[k] = redacted
+					}
+				}
+			}
+		}
+		for k, v := range x {
+			if r.isSensitive(k) {
+				x[k] = redacted
+				continue
+			}
+			x[k] = r.Redact(v)
+		}
+		return x
+	case []any:
+		for i, v := range x {
+			x[i] = r.Redact(v)
+		}
+		return x
+	default:
+		return v
+	}
+}
+
+func (r *redactor) isSensitive(k string) bool {
+	k = strings.ToLower(k)
+	for _, sk := range r.keys {
+		if strings.Contains(k, sk) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	defaultMaxPerResource = 10
+	defaultMaxResources   = 1000
+)
+
+type Store interface {
+	// Add adds a trace, the oldest trace of the resource is evicted if
+	// the resource has more than the max traces per resource
+	Add(t *Trace)
+	// Get returns the trace with the runID or nil if not found
+	Get(runID string) *Trace
+	// List returns the traces matching the filter, newest first
+	List(f *Filter) []*Trace
+	// GetRedactor returns the redactor used for the snapshots in the traces
+	GetRedactor() Redactor
+}
+
+type Config struct {
+	// MaxPerResource is the max amount of traces kept per for-resource
+	MaxPerResource int
+	// MaxResources is the max amount of for-resources for which traces are
+	// kept, the resource with the oldest last trace is evicted
+	MaxResources int
+	// Redactor redacts sensitive fields in the input/output snapshots,
+	// defaults to a redactor with the DefaultSensitiveKeys
+	Redactor Redactor
+}
+
+// Filter filters the traces, empty fields match all traces
+type Filter struct {
+	Controller string
+	Namespace  string
+	Name       string
+	Status     Status
+	Limit      int
+}
+
+func New(c *Config) Store {
+	r := &store{
+		maxPerResource: defaultMaxPerResource,
+		maxResources:   defaultMaxResources,
+		redactor:       NewRedactor(DefaultSensitiveKeys),
+		d:              map[string][]*Trace{},
+	}
+	if c != nil {
+		if c.MaxPerResource > 0 {
+			r.maxPerResource = c.MaxPerResource
+		}
+		if c.MaxResources > 0 {
+			r.maxResources = c.MaxResources
+		}
+		if c.Redactor != nil {
+			r.redactor = c.Redactor
+		}
+	}
+	return r
+}
+
+type store struct {
+	maxPerResource int
+	maxResources   int
+	redactor       Redactor
+
+	m sync.RWMutex
+	// key is controller/namespace/name, traces are ordered oldest first
+	d map[string][]*Trace
+}
+
+func (r *store) GetRedactor() Redactor {
+	return r.redactor
+}
+
+func (r *store) Add(t *Trace) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	k := t.Key()
+	traces := append(r.d[k], t)
+	if len(traces) > r.maxPerResource {
+		traces = traces[len(traces)-r.maxPerResource:]
+	}
+	r.d[k] = traces
+
+	if len(r.d) > r.maxResources {
+		r.evict()
+	}
+}
+
+// evict deletes the resource with the oldest last trace
+func (r *store) evict() {
+	oldestKey := ""
+	for k, traces := range r.d {
+		if oldestKey == "" || traces[len(traces)-1].EndTime.Before(r.d[oldestKey][len(r.d[oldestKey])-1].EndTime) {
+			oldestKey = k
+		}
+	}
+	delete(r.d, oldestKey)
+}
+
+func (r *store) Get(runID string) *Trace {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	for _, traces := range r.d {
+		for _, t := range traces {
+			if t.RunID == runID {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+func (r *store) List(f *Filter) []*Trace {
+	if f == nil {
+		f = &Filter{}
+	}
+	r.m.RLock()
+	traces := []*Trace{}
+	for _, ts := range r.d {
+		for _, t := range ts {
+			if f.match(t) {
+				traces = append(traces, t)
+			}
+		}
+	}
+	r.m.RUnlock()
+
+	sort.Slice(traces, func(i, j int) bool {
+		return traces[i].StartTime.After(traces[j].StartTime)
+	})
+	if f.Limit > 0 && len(traces) > f.Limit {
+		traces = traces[:f.Limit]
+	}
+	return traces
+}
+
+func (r *Filter) match(t *Trace) bool {
+	if r.Controller != "" && r.Controller != t.Controller {
+		return false
+	}
+	if r.Namespace != "" && r.Namespace != t.Namespace {
+		return false
+	}
+	if r.Name != "" && r.Name != t.Name {
+		return false
+	}
+	if r.Status != "" && r.Status != t.Status {
+		return false
+	}
+	return true
+}
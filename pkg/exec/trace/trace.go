@@ -0,0 +1,156 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/fnrunner/fnruntime/pkg/exec/output"
+	"github.com/fnrunner/fnruntime/pkg/exec/result"
+)
+
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailed  Status = "failed"
+)
+
+// Trace is the record of an execution of a DAG for a for-resource
+type Trace struct {
+	RunID      string         `json:"runID"`
+	Controller string         `json:"controller"`
+	Namespace  string         `json:"namespace,omitempty"`
+	Name       string         `json:"name"`
+	DAG        string         `json:"dag"`
+	StartTime  time.Time      `json:"startTime"`
+	EndTime    time.Time      `json:"endTime"`
+	Status     Status         `json:"status"`
+	Reason     string         `json:"reason,omitempty"`
+	Vertices   []*VertexTrace `json:"vertices"`
+}
+
+// VertexTrace is the record of the execution of a vertex
+type VertexTrace struct {
+	Type       string    `json:"type"`
+	ExecName   string    `json:"execName"`
+	VertexName string    `json:"vertexName"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	Success    bool      `json:"success"`
+	Reason     string    `json:"reason,omitempty"`
+	CacheHits  int       `json:"cacheHits,omitempty"`
+	Input      any       `json:"input,omitempty"`
+	Output     any       `json:"output,omitempty"`
+}
+
+type outputSnapshot struct {
+	Internal    bool   `json:"internal,omitempty"`
+	Conditioned bool   `json:"conditioned,omitempty"`
+	GVK         string `json:"gvk,omitempty"`
+	Data        any    `json:"data,omitempty"`
+}
+
+// Key returns the key of the for-resource the trace belongs to
+func (r *Trace) Key() string {
+	return key(r.Controller, r.Namespace, r.Name)
+}
+
+func key(controller, namespace, name string) string {
+	return controller + "/" + namespace + "/" + name
+}
+
+// SetResult sets the vertex traces and the status based on the results of
+// the execution. Input and output snapshots are redacted with the redactor.
+func (r *Trace) SetResult(res result.Result, redactor Redactor) {
+	r.Vertices = []*VertexTrace{}
+	r.Status = StatusSuccess
+	if res == nil {
+		return
+	}
+	for _, v := range res.Get() {
+		ri, ok := v.(*result.ResultInfo)
+		if !ok {
+			continue
+		}
+		if ri.Type == result.ExecRootType && ri.VertexName == "total" {
+			if !ri.Success {
+				r.Status = StatusFailed
+			}
+			continue
+		}
+		if !ri.Success {
+			r.Status = StatusFailed
+		}
+		vt := &VertexTrace{
+			Type:       string(ri.Type),
+			ExecName:   ri.ExecName,
+			VertexName: ri.VertexName,
+			StartTime:  ri.StartTime,
+			EndTime:    ri.EndTime,
+			Success:    ri.Success,
+			Reason:     ri.Reason,
+			CacheHits:  ri.CacheHits,
+		}
+		if ri.Input != nil {
+			vt.Input = snapshot(ri.Input.Get(), redactor)
+		}
+		if ri.Output != nil {
+			o := map[string]*outputSnapshot{}
+			for varName, v := range ri.Output.Get() {
+				oi, ok := v.(*output.OutputInfo)
+				if !ok {
+					continue
+				}
+				snap := &outputSnapshot{
+					Internal:    oi.Internal,
+					Conditioned: oi.Conditioned,
+					Data:        snapshot(oi.Data, redactor),
+				}
+				if oi.GVK != nil {
+					snap.GVK = oi.GVK.String()
+				}
+				o[varName] = snap
+			}
+			vt.Output = o
+		}
+		r.Vertices = append(r.Vertices, vt)
+		if ri.BlockResult != nil {
+			bt := &Trace{}
+			bt.SetResult(ri.BlockResult, redactor)
+			r.Vertices = append(r.Vertices, bt.Vertices...)
+		}
+	}
+}
+
+// snapshot returns a deep copy of the value which no longer shares state
+// with the runtime, redacted by the redactor
+func snapshot(v any, redactor Redactor) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	var x any
+	if err := json.Unmarshal(b, &x); err != nil {
+		return err.Error()
+	}
+	if redactor != nil {
+		return redactor.Redact(x)
+	}
+	return x
+}
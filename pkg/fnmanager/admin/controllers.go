@@ -0,0 +1,123 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"sort"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
+)
+
+// Controller is the state of a controller of the manager
+type Controller struct {
+	Name      string     `json:"name"`
+	ConfigMap *ConfigMap `json:"configMap,omitempty"`
+	// Running and Error are the state of the fnexec controller
+	Running bool    `json:"running"`
+	Error   string  `json:"error,omitempty"`
+	Images  []Image `json:"images"`
+}
+
+// ConfigMap is the configmap the controller runs with, configmaps have no
+// generation so the resource version identifies the observed content
+type ConfigMap struct {
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// Image is the state of an image of a controller, it is ready when the
+// client of at least one pod is connected
+type Image struct {
+	Name   string                  `json:"name"`
+	Kind   fnrunv1alpha1.ImageKind `json:"kind"`
+	Digest string                  `json:"digest,omitempty"`
+	Ready  bool                    `json:"ready"`
+	Pods   []Pod                   `json:"pods"`
+}
+
+// Pod is a ready pod of an image, it is not ready when its client is
+// ejected after failures
+type Pod struct {
+	Name        string `json:"name"`
+	IP          string `json:"ip"`
+	Ready       bool   `json:"ready"`
+	Outstanding int64  `json:"outstanding"`
+	Requests    uint64 `json:"requests"`
+	Failures    uint64 `json:"failures"`
+}
+
+// ListControllers returns the state of the controllers of the store sorted by
+// name
+func ListControllers(s ctrlstore.Store) []Controller {
+	names := s.List()
+	sort.Strings(names)
+	controllers := make([]Controller, 0, len(names))
+	for _, name := range names {
+		controllers = append(controllers, GetController(s, name))
+	}
+	return controllers
+}
+
+// GetController returns the state of a controller of the store
+func GetController(s ctrlstore.Store, name string) Controller {
+	c := Controller{
+		Name:   name,
+		Images: []Image{},
+	}
+	if cm := s.GetConfigMap(name); cm != nil {
+		c.ConfigMap = &ConfigMap{
+			Namespace:       cm.GetNamespace(),
+			Name:            cm.GetName(),
+			ResourceVersion: cm.GetResourceVersion(),
+		}
+	}
+	if fne := s.GetExecController(name); fne != nil {
+		c.Running = fne.IsRunning()
+		if err := fne.Error(); err != nil {
+			c.Error = err.Error()
+		}
+	}
+	imageStore := s.GetImageStore(name)
+	if imageStore == nil {
+		return c
+	}
+	for _, image := range imageStore.List() {
+		img := Image{
+			Name:   image.Name,
+			Kind:   image.Kind,
+			Digest: imageStore.GetDigest(image),
+			Pods:   []Pod{},
+		}
+		for _, st := range imageStore.GetEndpointStats(image) {
+			img.Pods = append(img.Pods, Pod{
+				Name:        st.PodName,
+				IP:          st.Address,
+				Ready:       !st.Ejected,
+				Outstanding: st.Outstanding,
+				Requests:    st.Requests,
+				Failures:    st.Failures,
+			})
+			img.Ready = img.Ready || !st.Ejected
+		}
+		sort.Slice(img.Pods, func(i, j int) bool { return img.Pods[i].Name < img.Pods[j].Name })
+		c.Images = append(c.Images, img)
+	}
+	sort.Slice(c.Images, func(i, j int) bool { return c.Images[i].Name < c.Images[j].Name })
+	return c
+}
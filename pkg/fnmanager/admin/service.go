@@ -0,0 +1,124 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// the admin service of the proxy, the request carries the name of a
+// controller or is empty for all controllers, the response carries the json
+// encoded list of controllers
+const (
+	ServiceName = "fnrun.admin.Admin"
+	MethodName  = "GetControllers"
+	FullMethod  = "/" + ServiceName + "/" + MethodName
+)
+
+type Server interface {
+	GetControllers(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.BytesValue, error)
+}
+
+type Client interface {
+	// GetControllers returns the state of the controller, or of all the
+	// controllers sorted by name when the controller is empty
+	GetControllers(ctx context.Context, controller string, opts ...grpc.CallOption) ([]Controller, error)
+}
+
+// RegisterServer registers the admin service serving the state of the
+// controllers of the store
+func RegisterServer(s grpc.ServiceRegistrar, store ctrlstore.Store) {
+	s.RegisterService(&serviceDesc, &server{store: store})
+}
+
+func NewClient(cc grpc.ClientConnInterface) Client {
+	return &client{cc: cc}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: MethodName,
+			Handler:    getControllersHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func getControllersHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Server).GetControllers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FullMethod,
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(Server).GetControllers(ctx, req.(*wrapperspb.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+type server struct {
+	store ctrlstore.Store
+}
+
+func (r *server) GetControllers(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.BytesValue, error) {
+	controllers := []Controller{}
+	if name := in.GetValue(); name != "" {
+		if !r.store.Exists(name) {
+			return nil, status.Errorf(codes.NotFound, "controller %s not found", name)
+		}
+		controllers = append(controllers, GetController(r.store, name))
+	} else {
+		controllers = ListControllers(r.store)
+	}
+	b, err := json.Marshal(controllers)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return wrapperspb.Bytes(b), nil
+}
+
+type client struct {
+	cc grpc.ClientConnInterface
+}
+
+func (r *client) GetControllers(ctx context.Context, controller string, opts ...grpc.CallOption) ([]Controller, error) {
+	out := new(wrapperspb.BytesValue)
+	if err := r.cc.Invoke(ctx, FullMethod, wrapperspb.String(controller), out, opts...); err != nil {
+		return nil, err
+	}
+	controllers := []Controller{}
+	if err := json.Unmarshal(out.GetValue(), &controllers); err != nil {
+		return nil, err
+	}
+	return controllers, nil
+}
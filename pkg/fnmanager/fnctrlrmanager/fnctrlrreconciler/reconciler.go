@@ -22,9 +22,18 @@ import (
 	"time"
 
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/internal/ctrlr/event"
+	"github.com/fnrunner/fnruntime/pkg/certs"
 	"github.com/fnrunner/fnruntime/pkg/ctrlr/controllers/reconciler"
 	"github.com/fnrunner/fnruntime/pkg/ctrlr/fnexeccontroller"
+	"github.com/fnrunner/fnruntime/pkg/exec/ctrlcfg"
+	"github.com/fnrunner/fnruntime/pkg/exec/policy"
+	"github.com/fnrunner/fnruntime/pkg/exec/schema"
+	"github.com/fnrunner/fnruntime/pkg/exec/service"
+	"github.com/fnrunner/fnruntime/pkg/exec/trace"
 	"github.com/fnrunner/fnruntime/pkg/fnmanager/fnreconciler"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnruntime/pkg/imgmanager/backend"
 	"github.com/fnrunner/fnruntime/pkg/imgmanager/imgmanager"
 	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
 	ctrlcfgv1alpha1 "github.com/fnrunner/fnsyntax/apis/controllerconfig/v1alpha1"
@@ -36,8 +45,9 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/event"
+	ctrlevent "sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/yaml"
 )
@@ -47,6 +57,9 @@ const (
 	cmLabelKey          = "fnrun.io/configmap"
 	finalizer           = "fnrun.io/finalizer"
 	defaultTimeout      = time.Second * 5
+	statusAnnotationKey = "fnrun.io/status"
+	// event reasons
+	reasonInvalidConfig event.Reason = "InvalidControllerConfig"
 )
 
 type Config struct {
@@ -54,30 +67,68 @@ type Config struct {
 	Client          *kubernetes.Clientset
 	Mgr             manager.Manager
 	ControllerStore ctrlstore.Store
+	Recorder        event.Recorder
+	TraceStore      trace.Store
+	// RecordDir enables recording a replay bundle of every run in the dir
+	RecordDir string
+	// Validator validates the final output before it is applied
+	Validator schema.Validator
+	// Authority issues the certificates of the function pods when mTLS is
+	// enabled
+	Authority certs.Authority
+	// FnClients are the clients to the fn proxy shared by the reconcilers and
+	// the watch eventhandlers
+	FnClients *clients.Clients
+	// Backends selects the backend of the images, all images run in pods
+	// when nil
+	Backends *backend.Selection
+	// MaxMsgSize is the max size of a message to and from the function
+	// pods, the grpc defaults apply when 0
+	MaxMsgSize int
 }
 
 func New(cfg *Config) fnreconciler.Reconciler {
 	l := ctrl.Log.WithName("fn reconciler")
+	var recorder event.Recorder = event.NewNopRecorder()
+	if cfg.Recorder != nil {
+		recorder = cfg.Recorder
+	}
 	return &rec{
-		client:    cfg.Client,
-		ctrlStore: cfg.ControllerStore,
-		mgr:       cfg.Mgr,
-		key:       defaultConfigMapKey,
-		ge:        make(chan event.GenericEvent),
-		l:         l,
+		client:     cfg.Client,
+		ctrlStore:  cfg.ControllerStore,
+		mgr:        cfg.Mgr,
+		key:        defaultConfigMapKey,
+		ge:         make(chan ctrlevent.GenericEvent),
+		record:     recorder,
+		traces:     cfg.TraceStore,
+		recordDir:  cfg.RecordDir,
+		validator:  cfg.Validator,
+		authority:  cfg.Authority,
+		fnClients:  cfg.FnClients,
+		backends:   cfg.Backends,
+		maxMsgSize: cfg.MaxMsgSize,
+		l:          l,
 	}
 }
 
 type rec struct {
-	client    *kubernetes.Clientset
-	ctrlStore ctrlstore.Store
-	mgr       manager.Manager
-	fne       fnexeccontroller.Controller
-	fni       imgmanager.Manager
-	key       string
-	ge        chan event.GenericEvent
-	cm        *corev1.ConfigMap // keeps track of the last known good configmap which whom we operate
-	l         logr.Logger
+	client     *kubernetes.Clientset
+	ctrlStore  ctrlstore.Store
+	mgr        manager.Manager
+	fne        fnexeccontroller.Controller
+	fni        imgmanager.Manager
+	key        string
+	ge         chan ctrlevent.GenericEvent
+	record     event.Recorder
+	traces     trace.Store
+	recordDir  string
+	validator  schema.Validator
+	authority  certs.Authority
+	fnClients  *clients.Clients
+	backends   *backend.Selection
+	maxMsgSize int
+	cm         *corev1.ConfigMap // keeps track of the last known good configmap which whom we operate
+	l          logr.Logger
 }
 
 func (r *rec) Reconcile(ctx context.Context, key types.NamespacedName) (bool, error) {
@@ -103,6 +154,9 @@ func (r *rec) Reconcile(ctx context.Context, key types.NamespacedName) (bool, er
 		if r.fne != nil {
 			r.fne.Stop(ctx)
 		}
+		if err := r.ctrlStore.SetExecutionContext(key.Name, nil); err != nil {
+			r.l.Error(err, "cannot reset execution context in controller store")
+		}
 		meta.RemoveFinalizer(cm, finalizer)
 		if _, err := r.client.CoreV1().ConfigMaps(key.Namespace).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
 			r.l.Error(err, "cannot update resource")
@@ -121,15 +175,28 @@ func (r *rec) Reconcile(ctx context.Context, key types.NamespacedName) (bool, er
 				r.l.Error(err, "cannot update resource")
 				return false, err
 			}
+			// the annotations, e.g. the memoize exclusions, are read from the
+			// controller store and can change without restarting the controller
+			r.cm = cm
+			if err := r.ctrlStore.SetConfigMap(key.Name, cm); err != nil {
+				r.l.Error(err, "cannot set configmap in controller store")
+			}
 			return false, nil
 		}
 	}
 	// get the ceCtx
-	images, ceCtx, err := r.getExecCtxAndImages(cm)
+	images, ceCtx, services, spec, err := r.getExecCtxAndImages(cm)
 	if err != nil {
 		r.l.Error(err, "cannot run controller with this execution context")
-		// new execution context is nok
-		// do we stop the controllers or do we keep running ??? TBD
+		// new execution context is nok, the configmap is rejected and
+		// the controller keeps running with the last known good configmap
+		r.reject(ctx, cm, err)
+		return false, err
+	}
+	outputPolicy, applyClient, err := r.getOutputPolicy(cm)
+	if err != nil {
+		r.l.Error(err, "cannot run controller with this output policy")
+		r.reject(ctx, cm, err)
 		return false, err
 	}
 	if action == Update {
@@ -143,6 +210,7 @@ func (r *rec) Reconcile(ctx context.Context, key types.NamespacedName) (bool, er
 		}
 	}
 	r.l.Info("images", "imageInfo", images)
+	delete(cm.GetAnnotations(), statusAnnotationKey)
 	// create the fn image manager
 	r.fni, err = imgmanager.New(&imgmanager.Config{
 		ControllerStore: r.ctrlStore,
@@ -151,6 +219,9 @@ func (r *rec) Reconcile(ctx context.Context, key types.NamespacedName) (bool, er
 		Namespace:       key.Namespace,
 		Images:          images,
 		ConfigMap:       cm, // use the latest cm
+		Authority:       r.authority,
+		Backends:        r.backends,
+		MaxMsgSize:      r.maxMsgSize,
 	})
 	if err != nil {
 		r.l.Error(err, "cannot create img manager")
@@ -163,14 +234,26 @@ func (r *rec) Reconcile(ctx context.Context, key types.NamespacedName) (bool, er
 	}
 
 	// create the controller
-	r.fne = fnexeccontroller.New(r.mgr, ceCtx, r.ge)
+	r.fne = fnexeccontroller.New(r.mgr, ceCtx, r.ge,
+		fnexeccontroller.WithFnClients(r.fnClients),
+		fnexeccontroller.WithServices(services),
+	)
 	// start the controller
 	r.l.Info("start fnexec controller...")
 	if err := r.fne.Start(ctx, cm.Name, controller.Options{
 		Reconciler: reconciler.New(&reconciler.Config{
-			Client:       r.mgr.GetClient(),
-			PollInterval: 1 * time.Minute,
-			CeCtx:        ceCtx,
+			Client:           r.mgr.GetClient(),
+			PollInterval:     1 * time.Minute,
+			CeCtx:            ceCtx,
+			Services:         services,
+			TraceStore:       r.traces,
+			ControllerConfig: spec,
+			RecordDir:        r.recordDir,
+			Validator:        r.validator,
+			Policy:           outputPolicy,
+			ApplyClient:      applyClient,
+			APIReader:        r.mgr.GetAPIReader(),
+			FnClients:        r.fnClients,
 		}),
 	}); err != nil {
 		r.l.Error(err, "cannot start fnexec controller")
@@ -182,25 +265,55 @@ func (r *rec) Reconcile(ctx context.Context, key types.NamespacedName) (bool, er
 		return false, err
 	}
 	r.cm = cm
+	if err := r.ctrlStore.SetConfigMap(key.Name, cm); err != nil {
+		r.l.Error(err, "cannot set configmap in controller store")
+	}
+	if err := r.ctrlStore.SetExecutionContext(key.Name, ceCtx); err != nil {
+		r.l.Error(err, "cannot set execution context in controller store")
+	}
+	if err := r.ctrlStore.SetExecController(key.Name, r.fne); err != nil {
+		r.l.Error(err, "cannot set exec controller in controller store")
+	}
 	return false, nil
 }
 
+// reject records the reason the configmap cannot be used in the status
+// annotation of the configmap and as a warning event. The configmap is only
+// updated when the reason changed, the update triggers a reconcile itself.
+func (r *rec) reject(ctx context.Context, cm *corev1.ConfigMap, err error) {
+	status := fmt.Sprintf("rejected: %s", err.Error())
+	annotations := cm.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if v, ok := annotations[statusAnnotationKey]; ok && v == status {
+		return
+	}
+	r.record.Event(cm, event.Warning(reasonInvalidConfig, err))
+
+	annotations[statusAnnotationKey] = status
+	cm.SetAnnotations(annotations)
+	if _, err := r.client.CoreV1().ConfigMaps(cm.GetNamespace()).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		r.l.Error(err, "cannot update resource")
+	}
+}
+
 func (r *rec) getLabelValue(key types.NamespacedName) string {
 	return fmt.Sprintf("%s-%s", key.Namespace, key.Name)
 }
 
-func (r *rec) getExecCtxAndImages(cm *corev1.ConfigMap) ([]*fnrunv1alpha1.Image, ccsyntax.ConfigExecutionContext, error) {
-	ctrlcfg := &ctrlcfgv1alpha1.ControllerConfigSpec{}
-	if err := yaml.Unmarshal([]byte(cm.Data[r.key]), ctrlcfg); err != nil {
+func (r *rec) getExecCtxAndImages(cm *corev1.ConfigMap) ([]*fnrunv1alpha1.Image, ccsyntax.ConfigExecutionContext, service.Services, *ctrlcfgv1alpha1.ControllerConfigSpec, error) {
+	spec := &ctrlcfgv1alpha1.ControllerConfigSpec{}
+	if err := yaml.Unmarshal([]byte(cm.Data[r.key]), spec); err != nil {
 		r.l.Error(err, "cannot unmarshal")
-		return nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	p, result := ccsyntax.NewParser(cm.GetName(), ctrlcfg)
+	p, result := ccsyntax.NewParser(cm.GetName(), spec)
 	if len(result) > 0 {
 		err := fmt.Errorf("failed ccsyntax validation, result %v", result)
 		r.l.Error(err, "syntax validation faile")
-		return nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	r.l.Info("ccsyntax validation succeeded")
 
@@ -210,10 +323,42 @@ func (r *rec) getExecCtxAndImages(cm *corev1.ConfigMap) ([]*fnrunv1alpha1.Image,
 		for _, res := range result {
 			r.l.Error(err, "ccsyntax parsing failed", "result", res)
 		}
-		return nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	r.l.Info("ccsyntax parsing succeeded")
-	return p.GetImages(), ceCtx, nil
+
+	if err := ctrlcfg.Validate(ceCtx); err != nil {
+		r.l.Error(err, "runtime dag validation failed")
+		return nil, nil, nil, nil, err
+	}
+	r.l.Info("runtime dag validation succeeded")
+
+	services, err := service.NewFromControllerConfig(spec)
+	if err != nil {
+		r.l.Error(err, "cannot get services")
+		return nil, nil, nil, nil, err
+	}
+	return p.GetImages(), ceCtx, services, spec, nil
+}
+
+// getOutputPolicy returns the output policy of the configmap and the client
+// impersonating the service account of the policy, if any
+func (r *rec) getOutputPolicy(cm *corev1.ConfigMap) (*fnrunv1alpha1.OutputPolicy, client.Client, error) {
+	p, err := ctrlcfg.UnmarshalPolicy(cm.Data[ctrlcfg.PolicyConfigMapKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	if p == nil || p.ServiceAccount == "" {
+		return p, nil, nil
+	}
+	c, err := policy.NewImpersonatingClient(r.mgr.GetConfig(), client.Options{
+		Scheme: r.mgr.GetScheme(),
+		Mapper: r.mgr.GetRESTMapper(),
+	}, cm.GetNamespace(), p.ServiceAccount)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, c, nil
 }
 
 type Action int
@@ -229,7 +374,11 @@ func (r *rec) checkAction(cm *corev1.ConfigMap) Action {
 		// create
 		return Create
 	}
-	if r.cm.Data[r.key] != cm.Data[r.key] {
+	// the replicas are applied to the deployments of the images when the
+	// controller is restarted
+	if r.cm.Data[r.key] != cm.Data[r.key] ||
+		r.cm.Data[ctrlcfg.PolicyConfigMapKey] != cm.Data[ctrlcfg.PolicyConfigMapKey] ||
+		r.cm.GetAnnotations()[fnrunv1alpha1.ReplicasAnnotationKey] != cm.GetAnnotations()[fnrunv1alpha1.ReplicasAnnotationKey] {
 		return Update
 	}
 	return Ignore
@@ -19,11 +19,21 @@ package fnctrlrmanager
 import (
 	"context"
 
+	"github.com/fnrunner/fnruntime/internal/ctrlr/event"
+	"github.com/fnrunner/fnruntime/pkg/certs"
+	"github.com/fnrunner/fnruntime/pkg/exec/schema"
+	"github.com/fnrunner/fnruntime/pkg/exec/trace"
 	"github.com/fnrunner/fnruntime/pkg/fnmanager/fnctrlrmanager/fnctrlrcontroller"
 	"github.com/fnrunner/fnruntime/pkg/fnmanager/fnctrlrmanager/fnctrlrreconciler"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnruntime/pkg/imgmanager/backend"
 	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -37,28 +47,63 @@ type Config struct {
 	Client          *kubernetes.Clientset
 	Namespace       string
 	Manager         manager.Manager
+	TraceStore      trace.Store
+	// RecordDir enables recording a replay bundle of every run in the dir
+	RecordDir string
+	// Validator validates the final output before it is applied
+	Validator schema.Validator
+	// Authority issues the certificates of the function pods when mTLS is
+	// enabled
+	Authority certs.Authority
+	// FnClients are the clients to the fn proxy shared by all controllers
+	FnClients *clients.Clients
+	// Backends selects the backend of the images
+	Backends *backend.Selection
+	// MaxMsgSize is the max size of a message to and from the function
+	// pods, the grpc defaults apply when 0
+	MaxMsgSize int
 }
 
 func New(cfg *Config) Manager {
 	l := ctrl.Log.WithName("fn ctrlr manager")
 
+	// events are recorded on the controller configmaps
+	eb := record.NewBroadcaster()
+	eb.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: cfg.Client.CoreV1().Events(cfg.Namespace)})
+
 	return &fnctrlmgr{
-		errChan:   make(chan error),
-		ctrlStore: cfg.ControllerStore,
-		client:    cfg.Client,
-		namespace: cfg.Namespace,
-		mgr:       cfg.Manager,
-		l:         l,
+		errChan:    make(chan error),
+		ctrlStore:  cfg.ControllerStore,
+		client:     cfg.Client,
+		namespace:  cfg.Namespace,
+		mgr:        cfg.Manager,
+		traces:     cfg.TraceStore,
+		recordDir:  cfg.RecordDir,
+		validator:  cfg.Validator,
+		authority:  cfg.Authority,
+		fnClients:  cfg.FnClients,
+		backends:   cfg.Backends,
+		maxMsgSize: cfg.MaxMsgSize,
+		record:     event.NewAPIRecorder(eb.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "fnrun-controller"})),
+		l:          l,
 	}
 }
 
 type fnctrlmgr struct {
-	errChan   chan error
-	ctrlStore ctrlstore.Store
-	client    *kubernetes.Clientset
-	namespace string
-	mgr       manager.Manager
-	l         logr.Logger
+	errChan    chan error
+	ctrlStore  ctrlstore.Store
+	client     *kubernetes.Clientset
+	namespace  string
+	mgr        manager.Manager
+	traces     trace.Store
+	recordDir  string
+	validator  schema.Validator
+	authority  certs.Authority
+	fnClients  *clients.Clients
+	backends   *backend.Selection
+	maxMsgSize int
+	record     event.Recorder
+	l          logr.Logger
 }
 
 func (r *fnctrlmgr) Start(ctx context.Context) error {
@@ -74,6 +119,14 @@ func (r *fnctrlmgr) Start(ctx context.Context) error {
 				Mgr:             r.mgr,
 				ControllerStore: r.ctrlStore,
 				Name:            controllerName,
+				Recorder:        r.record,
+				TraceStore:      r.traces,
+				RecordDir:       r.recordDir,
+				Validator:       r.validator,
+				Authority:       r.authority,
+				FnClients:       r.fnClients,
+				Backends:        r.backends,
+				MaxMsgSize:      r.maxMsgSize,
 			}),
 		})
 
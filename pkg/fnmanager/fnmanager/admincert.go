@@ -0,0 +1,91 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fnmanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/fnrunner/fnruntime/pkg/certs"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreapplyv1 "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+const (
+	adminCertCheckInterval = time.Hour
+	adminCertFieldManager  = "fnrun-manager"
+)
+
+// adminCertSecretName is the secret of the client certificate of the admin
+// clients
+func adminCertSecretName(caSecret string) string {
+	return caSecret + "-admin"
+}
+
+// applyAdminCertSecret issues the client certificate of the admin clients,
+// e.g. fnctl status, in a secret when the secret does not exist, the
+// certificate is due for renewal or the CA bundle changed
+func (r *fnmgr) applyAdminCertSecret(ctx context.Context) error {
+	secret, err := r.client.CoreV1().Secrets(r.namespace).Get(ctx, r.adminSecret, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	bundle := r.authority.CABundle()
+	if err == nil &&
+		!r.authority.NeedsRenewal(secret.Data[corev1.TLSCertKey]) &&
+		certs.EqualBundle(secret.Data[certs.CABundleKey], bundle) {
+		return nil
+	}
+	kp, err := r.authority.Issue(adminCommonName, nil, nil, certs.UsageClient)
+	if err != nil {
+		return err
+	}
+	s := coreapplyv1.Secret(r.adminSecret, r.namespace)
+	s.WithType(corev1.SecretTypeTLS)
+	s.WithData(map[string][]byte{
+		corev1.TLSCertKey:       kp.Cert,
+		corev1.TLSPrivateKeyKey: kp.Key,
+		certs.CABundleKey:       bundle,
+	})
+	if _, err := r.client.CoreV1().Secrets(r.namespace).Apply(ctx, s, metav1.ApplyOptions{FieldManager: adminCertFieldManager, Force: true}); err != nil {
+		return err
+	}
+	r.l.Info("admin certificate issued", "secret", r.adminSecret)
+	return nil
+}
+
+// startAdminCert keeps the admin certificate secret up to date with the CA
+// until the ctx is done
+func (r *fnmgr) startAdminCert(ctx context.Context) {
+	if err := r.applyAdminCertSecret(ctx); err != nil {
+		r.l.Error(err, "cannot apply admin certificate secret")
+	}
+	ticker := time.NewTicker(adminCertCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.applyAdminCertSecret(ctx); err != nil {
+				r.l.Error(err, "cannot apply admin certificate secret")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fnmanager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const defaultDebugAddress = "127.0.0.1:8082"
+
+// debugServer serves the debug handlers. They are not authenticated, so the
+// server binds to localhost unless configured otherwise.
+type debugServer struct {
+	addr string
+	mux  *http.ServeMux
+}
+
+func newDebugServer(addr string) *debugServer {
+	if addr == "" {
+		addr = defaultDebugAddress
+	}
+	return &debugServer{
+		addr: addr,
+		mux:  http.NewServeMux(),
+	}
+}
+
+func (r *debugServer) Handle(path string, h http.Handler) {
+	r.mux.Handle(path, h)
+}
+
+// NeedLeaderElection returns false, the debug handlers are served by the
+// standby managers as well
+func (r *debugServer) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *debugServer) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              r.addr,
+		Handler:           r.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -18,15 +18,33 @@ package fnmanager
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/certs"
+	"github.com/fnrunner/fnruntime/pkg/exec/dagexport"
+	"github.com/fnrunner/fnruntime/pkg/exec/schema"
+	"github.com/fnrunner/fnruntime/pkg/exec/trace"
+	"github.com/fnrunner/fnruntime/pkg/fnmanager/admin"
 	"github.com/fnrunner/fnruntime/pkg/fnmanager/fnctrlrmanager"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/execstream"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/fnproxy"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/grpcserver"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/healthhandler"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/memo"
+	"github.com/fnrunner/fnruntime/pkg/imgmanager/backend"
 	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
 	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -34,6 +52,30 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
+const (
+	defaultCASecret = "fnrun-ca"
+	// proxyCommonName is the common name of the client certificate of the
+	// proxy to the function pods
+	proxyCommonName = "fnrun-proxy"
+	// reconcilerCommonName is the common name of the client certificate of
+	// the reconcilers to the proxy
+	reconcilerCommonName = "fnrun-reconciler"
+	// adminCommonName is the common name of the client certificate of the
+	// admin clients, e.g. fnctl status, they only call the admin service. The
+	// manager issues it in the admin certificate secret.
+	adminCommonName      = "fnrun-admin"
+	inMemoryProxyAddress = "inmemory"
+	// proxyServerName is the name of the proxy certificate, the clients in
+	// the manager reach the proxy on the loopback interface or in memory
+	proxyServerName = "localhost"
+)
+
+// DefaultMaxMsgSize is the default max size of a message between the
+// reconcilers, the proxy and the function pods. It bounds the size of a
+// resource context, the proxy executes a streamed resource context with a
+// single message to the function pod.
+const DefaultMaxMsgSize = 64 * 1024 * 1024
+
 type Manager interface {
 	Start(ctx context.Context) error
 }
@@ -43,10 +85,52 @@ type Config struct {
 	UniqueID             string   // need to come at init time
 	ConfigMaps           []string // need to come at init time
 	MetricAddress        string
+	DebugAddress         string // unauthenticated debug handlers, default localhost:8082
 	ProbeAddress         string
 	EnableLeaderElection bool
 	Concurrency          int
 	PollInterval         time.Duration
+	// Trace records the execution traces of the for-resources, it is opt-in
+	// as every execution snapshots the input and output of its vertices
+	Trace bool
+	// TraceMaxPerResource is the max amount of execution traces kept per for-resource
+	TraceMaxPerResource int
+	// TraceConfigMap persists the execution traces in a configmap per
+	// controller, it enables Trace
+	TraceConfigMap bool
+	// RecordDir enables recording a replay bundle of every run in the dir
+	RecordDir string
+	// Memoization enables the memoization of the function executions
+	Memoization *memo.Config
+	// Breaker enables a circuit breaker per function image in the proxy
+	Breaker *breaker.Config
+	// ProxyLimits bounds the function RPCs of the proxy
+	ProxyLimits *fnproxy.Limits
+	// MTLS enables mTLS between the reconcilers, the proxy and the function
+	// pods with certificates issued by a CA stored in the CASecret. The
+	// client certificate of the admin clients is issued in the secret named
+	// after the CASecret with the -admin suffix. The pods of service images
+	// are excluded: they serve grpc themselves without the fnwrapper, get no
+	// certificate and are called in plaintext.
+	MTLS     bool
+	CASecret string
+	// ValidateOutputs validates the final output against the openapi v3
+	// schemas of the API server before it is applied
+	ValidateOutputs bool
+	// BalancePolicy is the policy balancing the function requests over the
+	// replicas of an image
+	BalancePolicy string
+	// InMemoryProxy connects the reconcilers to the proxy in memory instead
+	// of over the loopback network
+	InMemoryProxy bool
+	// MaxMsgSize is the max size of a message between the reconcilers, the
+	// proxy and the function pods, default DefaultMaxMsgSize when 0. Resource
+	// contexts larger than the chunk size are streamed to the proxy, the
+	// function pods are started with the limit to receive them assembled.
+	MaxMsgSize int
+	// Backends selects the backend of the images, all images run in pods
+	// when nil
+	Backends *backend.Selection
 }
 
 func New(cfg *Config) (Manager, error) {
@@ -56,12 +140,29 @@ func New(cfg *Config) (Manager, error) {
 	if err != nil {
 		return nil, err
 	}
+	switch imagestore.BalancePolicy(cfg.BalancePolicy) {
+	case "", imagestore.BalanceRoundRobin, imagestore.BalanceLeastOutstanding:
+	default:
+		return nil, fmt.Errorf("unknown balance policy: %s", cfg.BalancePolicy)
+	}
+	if cfg.MaxMsgSize == 0 {
+		cfg.MaxMsgSize = DefaultMaxMsgSize
+	}
+	if cfg.MaxMsgSize < 2*execstream.DefaultChunkSize {
+		return nil, fmt.Errorf("max message size must be at least %d bytes", 2*execstream.DefaultChunkSize)
+	}
+	if cfg.Breaker != nil && (cfg.Breaker.ErrorRate < 0 || cfg.Breaker.ErrorRate > 1) {
+		return nil, fmt.Errorf("breaker error rate must be between 0 and 1: %v", cfg.Breaker.ErrorRate)
+	}
+	if err := cfg.Backends.Validate(); err != nil {
+		return nil, err
+	}
 	fnmgr.errChan = make(chan error)
 
 	fnmgr.mgr, err = manager.New(ctrl.GetConfigOrDie(), manager.Options{
-		Scheme:    runtime.NewScheme(),
-		Namespace: fnmgr.namespace,
-		//MetricsBindAddress: metricsAddr,
+		Scheme:             runtime.NewScheme(),
+		Namespace:          fnmgr.namespace,
+		MetricsBindAddress: fnmgr.metricsAddr,
 		//Port: 9443,
 		HealthProbeBindAddress: fnmgr.probeAddr,
 		LeaderElection:         fnmgr.leaderElection,
@@ -77,29 +178,166 @@ func New(cfg *Config) (Manager, error) {
 		os.Exit(1)
 	}
 
+	// create the execution trace store, no traces are recorded without it
+	if cfg.Trace || cfg.TraceConfigMap {
+		fnmgr.traceStore = trace.New(&trace.Config{MaxPerResource: cfg.TraceMaxPerResource})
+	}
+	if cfg.TraceConfigMap {
+		cmStore := trace.NewConfigMapStore(&trace.ConfigMapConfig{
+			Client:    fnmgr.client,
+			Namespace: fnmgr.namespace,
+			Store:     fnmgr.traceStore,
+		})
+		if err := cmStore.Load(context.Background()); err != nil {
+			l.Error(err, "cannot load execution traces")
+		}
+		if err := fnmgr.mgr.Add(cmStore); err != nil {
+			l.Error(err, "cannot add execution trace persistence")
+			return nil, err
+		}
+		fnmgr.traceStore = cmStore
+	}
+
+	var validator schema.Validator
+	if cfg.ValidateOutputs {
+		validator = schema.NewValidator(schema.NewDiscoveryResolver(&schema.DiscoveryConfig{
+			Client: fnmgr.client.Discovery().OpenAPIV3(),
+		}))
+	}
+
+	// the CA is loaded before the proxy and the function pods need certificates
+	isOpts := []imagestore.Option{
+		imagestore.WithBalancePolicy(imagestore.BalancePolicy(cfg.BalancePolicy)),
+		imagestore.WithMaxMsgSize(cfg.MaxMsgSize),
+	}
+	var proxyTLS *tls.Config
+	if cfg.MTLS {
+		caSecret := cfg.CASecret
+		if caSecret == "" {
+			caSecret = defaultCASecret
+		}
+		fnmgr.authority = certs.New(&certs.Config{
+			Client:     fnmgr.client,
+			Namespace:  fnmgr.namespace,
+			SecretName: caSecret,
+		})
+		if err := fnmgr.authority.Load(context.Background()); err != nil {
+			l.Error(err, "cannot load CA")
+			return nil, err
+		}
+		fnmgr.adminSecret = adminCertSecretName(caSecret)
+		// only the reconcilers, the proxy itself and the admin clients are
+		// clients of the proxy, not the function pods holding certificates of
+		// the same CA
+		proxyTLS = fnmgr.authority.ServerTLSConfig([]string{proxyServerName}, []net.IP{net.IPv4(127, 0, 0, 1)},
+			[]string{reconcilerCommonName, proxyCommonName, adminCommonName})
+		isOpts = append(isOpts, imagestore.WithTLS(func(serverName string) *tls.Config {
+			return fnmgr.authority.ClientTLSConfig(proxyCommonName, serverName)
+		}))
+	}
+
+	// the health service of the proxy reports the serving status of the images
+	// of every controller and the state of their circuit breakers
+	health := healthhandler.New()
+	var breakers breaker.Breakers
+	if cfg.Breaker != nil {
+		breakers = breaker.New(*cfg.Breaker, breaker.WithStateFn(func(controller, image string, state breaker.State) {
+			health.SetBreakerState(controller, image, state != breaker.StateClosed)
+		}))
+	}
+
 	// create controller store
-	fnmgr.ctrlStore = ctrlstore.New()
+	fnmgr.ctrlStore = ctrlstore.New(
+		ctrlstore.WithImageStoreOptions(isOpts...),
+		ctrlstore.WithImageStatusFn(func(controllerName string, image fnrunv1alpha1.Image, status imagestore.ServingStatus) {
+			health.SetImageStatus(controllerName, image, status)
+			// the breakers of the deleted images and controllers are pruned
+			if breakers != nil && status == imagestore.StatusUnknown {
+				breakers.Delete(controllerName, image.Name)
+			}
+		}),
+	)
 	for _, controllerName := range fnmgr.configMaps {
 		fnmgr.ctrlStore.Create(controllerName)
 	}
+	// the reconcilers and the watch eventhandlers share long-lived clients to
+	// the proxy
+	clientCfg := &clients.Config{
+		Address:    fmt.Sprintf("127.0.0.1:%d", fnrunv1alpha1.FnProxyGRPCServerPort),
+		MaxMsgSize: cfg.MaxMsgSize,
+	}
+	if fnmgr.authority != nil {
+		// the proxy certificate is verified against its name and not against
+		// the dial target, which is not a host name in memory
+		clientCfg.TLSConfig = fnmgr.authority.ClientTLSConfig(reconcilerCommonName, proxyServerName)
+	}
+	var proxyListener net.Listener
+	if cfg.InMemoryProxy {
+		proxyListener, clientCfg.Dialer = clients.NewInMemoryListener()
+		clientCfg.Address = inMemoryProxyAddress
+	}
+	fnmgr.fnClients, err = clients.New(clientCfg)
+	if err != nil {
+		l.Error(err, "cannot create proxy clients")
+		return nil, err
+	}
+
 	// create fn controller manager
 	fnmgr.fncm = fnctrlrmanager.New(&fnctrlrmanager.Config{
 		ControllerStore: fnmgr.ctrlStore,
 		Client:          fnmgr.client,
 		Namespace:       fnmgr.namespace,
 		Manager:         fnmgr.mgr,
+		TraceStore:      fnmgr.traceStore,
+		RecordDir:       cfg.RecordDir,
+		Validator:       validator,
+		Authority:       fnmgr.authority,
+		FnClients:       fnmgr.fnClients,
+		Backends:        cfg.Backends,
+		MaxMsgSize:      cfg.MaxMsgSize,
 	})
 
+	// without mTLS the proxy only serves the reconcilers of this process and
+	// the admin clients forwarded to the loopback interface
+	proxyAuth := grpcserver.LoopbackAuth()
+	if fnmgr.authority != nil {
+		proxyAuth = commonNameAuth()
+	}
 	fnmgr.proxy = fnproxy.New(&fnproxy.Config{
 		ControllerStore: fnmgr.ctrlStore,
-	})
+		Memoization:     cfg.Memoization,
+		Breakers:        breakers,
+		Limits:          cfg.ProxyLimits,
+		TLSConfig:       proxyTLS,
+		Auth:            proxyAuth,
+		Health:          health,
+		Listener:        proxyListener,
+		MaxMsgSize:      cfg.MaxMsgSize,
+	}, fnproxy.WithServices(func(s grpc.ServiceRegistrar) {
+		admin.RegisterServer(s, fnmgr.ctrlStore)
+	}))
+
+	// add debug handlers, served on the debug endpoint and not on the
+	// metrics endpoint which binds to all interfaces
+	ds := newDebugServer(cfg.DebugAddress)
+	ds.Handle(dagexport.HandlerPath, dagexport.NewHandler(fnmgr.ctrlStore))
+	if fnmgr.traceStore != nil {
+		ds.Handle(trace.HandlerPath, trace.NewHandler(fnmgr.traceStore))
+	}
+	if err := fnmgr.mgr.Add(ds); err != nil {
+		l.Error(err, "unable to set up debug server")
+		return nil, err
+	}
 
 	// add health/ready checks
 	if err := fnmgr.mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		l.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := fnmgr.mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	// ready when all images of the loaded controllers are serving
+	if err := fnmgr.mgr.AddReadyzCheck("readyz", func(_ *http.Request) error {
+		return health.Ready()
+	}); err != nil {
 		l.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
@@ -120,12 +358,18 @@ type fnmgr struct {
 	concurrency      int
 	pollInterval     time.Duration
 
-	client    *kubernetes.Clientset
-	ctrlStore ctrlstore.Store
-	mgr       manager.Manager
-	fncm      fnctrlrmanager.Manager
-	proxy     fnproxy.Proxy
-	l         logr.Logger
+	client     *kubernetes.Clientset
+	ctrlStore  ctrlstore.Store
+	traceStore trace.Store
+	mgr        manager.Manager
+	fncm       fnctrlrmanager.Manager
+	proxy      fnproxy.Proxy
+	authority  certs.Authority
+	// adminSecret is the secret of the client certificate of the admin
+	// clients when mTLS is enabled
+	adminSecret string
+	fnClients   *clients.Clients
+	l           logr.Logger
 }
 
 func initDefaults(cfg *Config) (*fnmgr, error) {
@@ -169,6 +413,17 @@ func initDefaults(cfg *Config) (*fnmgr, error) {
 }
 
 func (r *fnmgr) Start(ctx context.Context) error {
+	// the clients share a single connection
+	defer r.fnClients.Execclient.Close()
+	// rotate the CA and the admin certificate
+	if r.authority != nil {
+		go func() {
+			if err := r.authority.Start(ctx); err != nil {
+				r.errChan <- err
+			}
+		}()
+		go r.startAdminCert(ctx)
+	}
 	// start the proxy
 	go func() {
 		if err := r.proxy.Start(ctx); err != nil {
@@ -198,3 +453,16 @@ func (r *fnmgr) Start(ctx context.Context) error {
 		}
 	}
 }
+
+// commonNameAuth authenticates the reconcilers and the proxy for the function
+// services and the admin clients for the admin service only
+func commonNameAuth() grpcserver.AuthFunc {
+	fnAuth := grpcserver.CommonNameAuth(reconcilerCommonName, proxyCommonName)
+	adminAuth := grpcserver.CommonNameAuth(reconcilerCommonName, proxyCommonName, adminCommonName)
+	return func(ctx context.Context, fullMethod string) (context.Context, error) {
+		if strings.HasPrefix(fullMethod, "/"+admin.ServiceName+"/") {
+			return adminAuth(ctx, fullMethod)
+		}
+		return fnAuth(ctx, fullMethod)
+	}
+}
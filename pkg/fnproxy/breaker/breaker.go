@@ -0,0 +1,341 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultConsecutiveFailures = 5
+	defaultMinRequests         = 10
+	defaultWindow              = time.Minute
+	defaultOpenTimeout         = 30 * time.Second
+	defaultHalfOpenRequests    = 1
+)
+
+type State int
+
+const (
+	// StateClosed lets the requests through
+	StateClosed State = iota
+	// StateHalfOpen lets a limited amount of trial requests through, the
+	// breaker closes when they succeed and opens again when one fails
+	StateHalfOpen
+	// StateOpen rejects the requests until the open timeout expired
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "halfOpen"
+	case StateOpen:
+		return "open"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+type Config struct {
+	// ConsecutiveFailures trips the breaker after the amount of consecutive
+	// failed requests, default 5
+	ConsecutiveFailures int
+	// ErrorRate trips the breaker when the rate of failed requests in the
+	// window exceeds it, disabled when 0
+	ErrorRate float64
+	// MinRequests is the min amount of requests in the window before the
+	// error rate applies, default 10
+	MinRequests int
+	// Window is the window the error rate is measured in, default 1m
+	Window time.Duration
+	// OpenTimeout is the time the breaker stays open before trial requests
+	// are let through, default 30s
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the amount of trial requests which need to succeed
+	// to close the breaker, default 1
+	HalfOpenRequests int
+}
+
+func (c *Config) setDefaults() {
+	if c.ConsecutiveFailures <= 0 {
+		c.ConsecutiveFailures = defaultConsecutiveFailures
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultMinRequests
+	}
+	if c.Window <= 0 {
+		c.Window = defaultWindow
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = defaultOpenTimeout
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = defaultHalfOpenRequests
+	}
+}
+
+// OpenError is returned for a request rejected by an open breaker
+type OpenError struct {
+	Controller string
+	Image      string
+	// RetryAfter is the time after which trial requests are let through
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit of image %s of controller %s is open", e.Image, e.Controller)
+}
+
+// StateFn is called on the state transitions of the breaker of an image,
+// with the breakers locked
+type StateFn func(controller, image string, state State)
+
+// Breakers keeps a circuit breaker per image of a controller
+type Breakers interface {
+	// Allow returns an OpenError when the breaker of the image rejects the
+	// request, otherwise the result of the request is reported with done
+	Allow(controller, image string) (done func(err error), err error)
+	State(controller, image string) State
+	// Delete deletes the breaker of an image which is no longer served
+	Delete(controller, image string)
+}
+
+type Option func(*breakers)
+
+func WithStateFn(fn StateFn) Option {
+	return func(r *breakers) {
+		r.stateFn = fn
+	}
+}
+
+func New(cfg Config, opts ...Option) Breakers {
+	cfg.setDefaults()
+	r := &breakers{
+		cfg: cfg,
+		d:   map[key]*breaker{},
+		now: time.Now,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+type key struct {
+	controller string
+	image      string
+}
+
+type breakers struct {
+	m       sync.Mutex
+	cfg     Config
+	d       map[key]*breaker
+	stateFn StateFn
+	now     func() time.Time
+}
+
+type breaker struct {
+	state State
+	// generation changes with the state, results of requests let through in
+	// an earlier state are ignored
+	generation  uint64
+	consecutive int
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	trials      int
+	successes   int
+}
+
+func (r *breakers) Allow(controller, image string) (func(err error), error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	k := key{controller: controller, image: image}
+	b, ok := r.d[k]
+	if !ok {
+		b = &breaker{windowStart: r.now()}
+		r.d[k] = b
+	}
+	now := r.now()
+	switch b.state {
+	case StateOpen:
+		if retryAfter := b.openedAt.Add(r.cfg.OpenTimeout).Sub(now); retryAfter > 0 {
+			rejected.WithLabelValues(controller, image).Inc()
+			return nil, &OpenError{Controller: controller, Image: image, RetryAfter: retryAfter}
+		}
+		r.transition(k, b, StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.trials >= r.cfg.HalfOpenRequests {
+			rejected.WithLabelValues(controller, image).Inc()
+			return nil, &OpenError{Controller: controller, Image: image}
+		}
+		b.trials++
+	}
+	generation := b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { r.done(k, generation, IsFailure(err), isIgnored(err)) })
+	}, nil
+}
+
+func (r *breakers) done(k key, generation uint64, failed, ignored bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	b, ok := r.d[k]
+	if !ok || b.generation != generation {
+		return
+	}
+	if ignored {
+		// the trial is given back without a result of the image
+		if b.state == StateHalfOpen {
+			b.trials--
+		}
+		return
+	}
+	now := r.now()
+	switch b.state {
+	case StateHalfOpen:
+		if failed {
+			b.openedAt = now
+			r.transition(k, b, StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= r.cfg.HalfOpenRequests {
+			b.windowStart = now
+			r.transition(k, b, StateClosed)
+		}
+	case StateClosed:
+		if now.Sub(b.windowStart) > r.cfg.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if !failed {
+			b.consecutive = 0
+			return
+		}
+		b.failures++
+		b.consecutive++
+		if b.consecutive >= r.cfg.ConsecutiveFailures ||
+			(r.cfg.ErrorRate > 0 && b.requests >= r.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= r.cfg.ErrorRate) {
+			b.openedAt = now
+			r.transition(k, b, StateOpen)
+		}
+	}
+}
+
+// transition changes the state of the breaker and resets its counters
+func (r *breakers) transition(k key, b *breaker, state State) {
+	b.state = state
+	b.generation++
+	b.consecutive, b.requests, b.failures = 0, 0, 0
+	b.trials, b.successes = 0, 0
+	stateGauge.WithLabelValues(k.controller, k.image).Set(float64(state))
+	if r.stateFn != nil {
+		r.stateFn(k.controller, k.image, state)
+	}
+}
+
+func (r *breakers) Delete(controller, image string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	k := key{controller: controller, image: image}
+	b, ok := r.d[k]
+	if !ok {
+		return
+	}
+	delete(r.d, k)
+	stateGauge.DeleteLabelValues(controller, image)
+	rejected.DeleteLabelValues(controller, image)
+	if b.state != StateClosed && r.stateFn != nil {
+		r.stateFn(controller, image, StateClosed)
+	}
+}
+
+func (r *breakers) State(controller, image string) State {
+	r.m.Lock()
+	defer r.m.Unlock()
+	b, ok := r.d[key{controller: controller, image: image}]
+	if !ok {
+		return StateClosed
+	}
+	return b.state
+}
+
+// errPanicked is reported for a request which panicked in the proxy
+var errPanicked = errors.New("request panicked")
+
+// IsFailure returns true for the transport errors of an unhealthy image, e.g.
+// a crashed or hanging function pod. The errors the function returns itself,
+// e.g. for an invalid input, do not count.
+func IsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// isIgnored returns true for the requests which did not get a result of the
+// image, e.g. canceled by the client, not admitted by the proxy or waiting
+// for an image which is not ready yet during a rollout
+func isIgnored(err error) bool {
+	if errors.Is(err, errPanicked) {
+		return true
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Canceled, codes.ResourceExhausted:
+		return true
+	}
+	for _, d := range s.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == fnrunv1alpha1.Domain {
+			// the status is returned by the proxy and not by the image
+			return true
+		}
+	}
+	return false
+}
+
+// Report reports the result of a request to done, it is deferred with the
+// named error of the request. A panic of the request gives the trial back
+// before it is propagated, so a half-open trial is not lost.
+func Report(done func(err error), err *error) {
+	if p := recover(); p != nil {
+		done(errPanicked)
+		panic(p)
+	}
+	done(*err)
+}
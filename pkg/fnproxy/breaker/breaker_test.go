@@ -0,0 +1,254 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errUnavailable = status.Error(codes.Unavailable, "connection refused")
+	errFunction    = status.Error(codes.Unknown, "invalid input")
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestBreakers(t *testing.T, cfg Config) (*breakers, *clock, *[]State) {
+	t.Helper()
+	states := []State{}
+	c := &clock{t: time.Unix(0, 0)}
+	r := New(cfg, WithStateFn(func(_, _ string, state State) {
+		states = append(states, state)
+	})).(*breakers)
+	r.now = c.now
+	return r, c, &states
+}
+
+// call lets a request through and reports its result
+func call(t *testing.T, r Breakers, err error) {
+	t.Helper()
+	done, aerr := r.Allow("c", "i")
+	if aerr != nil {
+		t.Fatalf("expected the request to be allowed, got: %v", aerr)
+	}
+	done(err)
+}
+
+func TestStateMachine(t *testing.T) {
+	r, c, states := newTestBreakers(t, Config{ConsecutiveFailures: 3, OpenTimeout: 10 * time.Second})
+
+	call(t, r, errUnavailable)
+	call(t, r, errUnavailable)
+	call(t, r, nil)
+	call(t, r, errUnavailable)
+	call(t, r, errUnavailable)
+	if s := r.State("c", "i"); s != StateClosed {
+		t.Fatalf("expected closed after a success resets the consecutive failures, got %s", s)
+	}
+	call(t, r, errUnavailable)
+	if s := r.State("c", "i"); s != StateOpen {
+		t.Fatalf("expected open after 3 consecutive failures, got %s", s)
+	}
+
+	_, err := r.Allow("c", "i")
+	oerr := &OpenError{}
+	if !errors.As(err, &oerr) || oerr.RetryAfter != 10*time.Second {
+		t.Fatalf("expected an open error with the remaining open time, got: %v", err)
+	}
+
+	c.t = c.t.Add(10 * time.Second)
+	done, err := r.Allow("c", "i")
+	if err != nil {
+		t.Fatalf("expected a trial after the open timeout, got: %v", err)
+	}
+	if s := r.State("c", "i"); s != StateHalfOpen {
+		t.Fatalf("expected half-open, got %s", s)
+	}
+	if _, err := r.Allow("c", "i"); err == nil {
+		t.Fatal("expected a second trial to be rejected")
+	}
+	done(errUnavailable)
+	if s := r.State("c", "i"); s != StateOpen {
+		t.Fatalf("expected open after a failed trial, got %s", s)
+	}
+
+	c.t = c.t.Add(10 * time.Second)
+	call(t, r, nil)
+	if s := r.State("c", "i"); s != StateClosed {
+		t.Fatalf("expected closed after a successful trial, got %s", s)
+	}
+
+	want := []State{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}
+	if len(*states) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, *states)
+	}
+	for i := range want {
+		if (*states)[i] != want[i] {
+			t.Fatalf("expected transitions %v, got %v", want, *states)
+		}
+	}
+}
+
+func TestErrorRate(t *testing.T) {
+	r, c, _ := newTestBreakers(t, Config{ConsecutiveFailures: 100, ErrorRate: 0.5, MinRequests: 4, Window: time.Minute})
+
+	// the window restarts, the failures of the earlier window do not count
+	call(t, r, errUnavailable)
+	call(t, r, errUnavailable)
+	c.t = c.t.Add(2 * time.Minute)
+	call(t, r, nil)
+	call(t, r, errUnavailable)
+	call(t, r, nil)
+	if s := r.State("c", "i"); s != StateClosed {
+		t.Fatalf("expected closed below the min requests, got %s", s)
+	}
+	call(t, r, errUnavailable)
+	if s := r.State("c", "i"); s != StateOpen {
+		t.Fatalf("expected open at the error rate, got %s", s)
+	}
+}
+
+func TestNotCounted(t *testing.T) {
+	notReady, err := status.New(codes.Unavailable, "client not ready").WithDetails(&errdetails.ErrorInfo{
+		Reason: fnrunv1alpha1.ReasonClientNotReady,
+		Domain: fnrunv1alpha1.Domain,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]error{
+		"function error": errFunction,
+		"internal":       status.Error(codes.Internal, "cannot process"),
+		"not found":      status.Error(codes.NotFound, "unknown image"),
+		"not ready":      notReady.Err(),
+		"canceled":       status.Error(codes.Canceled, "canceled"),
+		"not admitted":   status.Error(codes.ResourceExhausted, "overloaded"),
+	}
+	for name, cerr := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, _, _ := newTestBreakers(t, Config{ConsecutiveFailures: 1})
+			call(t, r, cerr)
+			if s := r.State("c", "i"); s != StateClosed {
+				t.Fatalf("expected closed, got %s", s)
+			}
+		})
+	}
+}
+
+func TestIgnoredTrial(t *testing.T) {
+	r, c, _ := newTestBreakers(t, Config{ConsecutiveFailures: 1, OpenTimeout: time.Second})
+	call(t, r, errUnavailable)
+	c.t = c.t.Add(time.Second)
+
+	// a canceled trial is given back
+	call(t, r, status.Error(codes.Canceled, "canceled"))
+	if s := r.State("c", "i"); s != StateHalfOpen {
+		t.Fatalf("expected half-open, got %s", s)
+	}
+	call(t, r, nil)
+	if s := r.State("c", "i"); s != StateClosed {
+		t.Fatalf("expected closed, got %s", s)
+	}
+}
+
+func TestStaleResult(t *testing.T) {
+	r, c, _ := newTestBreakers(t, Config{ConsecutiveFailures: 1, OpenTimeout: time.Second})
+	stale, err := r.Allow("c", "i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	call(t, r, errUnavailable)
+	c.t = c.t.Add(time.Second)
+	call(t, r, nil)
+
+	// the result of a request let through before the breaker opened is
+	// ignored
+	stale(errUnavailable)
+	if s := r.State("c", "i"); s != StateClosed {
+		t.Fatalf("expected closed, got %s", s)
+	}
+}
+
+func TestReportPanic(t *testing.T) {
+	r, c, _ := newTestBreakers(t, Config{ConsecutiveFailures: 1, OpenTimeout: time.Second})
+	call(t, r, errUnavailable)
+	c.t = c.t.Add(time.Second)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("expected the panic to be propagated, got: %v", p)
+			}
+		}()
+		done, err := r.Allow("c", "i")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rerr error
+		defer Report(done, &rerr)
+		panic("boom")
+	}()
+
+	// the trial of the panicked request is given back
+	if s := r.State("c", "i"); s != StateHalfOpen {
+		t.Fatalf("expected half-open, got %s", s)
+	}
+	call(t, r, nil)
+	if s := r.State("c", "i"); s != StateClosed {
+		t.Fatalf("expected closed, got %s", s)
+	}
+}
+
+func TestReportError(t *testing.T) {
+	r, _, _ := newTestBreakers(t, Config{ConsecutiveFailures: 1})
+	func() {
+		done, err := r.Allow("c", "i")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rerr error
+		defer Report(done, &rerr)
+		rerr = errUnavailable
+	}()
+	if s := r.State("c", "i"); s != StateOpen {
+		t.Fatalf("expected open, got %s", s)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	r, _, states := newTestBreakers(t, Config{ConsecutiveFailures: 1})
+	call(t, r, errUnavailable)
+	r.Delete("c", "i")
+	if s := r.State("c", "i"); s != StateClosed {
+		t.Fatalf("expected a deleted breaker to be closed, got %s", s)
+	}
+	if last := (*states)[len(*states)-1]; last != StateClosed {
+		t.Fatalf("expected the delete to report closed, got %s", last)
+	}
+	if len(r.d) != 0 {
+		t.Fatalf("expected no breakers, got %d", len(r.d))
+	}
+}
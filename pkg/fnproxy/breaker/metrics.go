@@ -0,0 +1,37 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package breaker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	stateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "fnrun_proxy_breaker_state",
+		Help: "State of the circuit breaker of an image, 0 closed, 1 half-open, 2 open",
+	}, []string{"controller", "image"})
+	rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fnrun_proxy_breaker_rejected_total",
+		Help: "Total number of requests rejected by the circuit breaker of an image",
+	}, []string{"controller", "image"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(stateGauge, rejected)
+}
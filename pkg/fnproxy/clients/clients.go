@@ -19,9 +19,17 @@ package clients
 import (
 	"github.com/fnrunner/fnproto/pkg/executor/execclient"
 	"github.com/fnrunner/fnproto/pkg/service/svcclient"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/execstream"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type Clients struct {
 	Execclient execclient.Client
 	Svcclient  svcclient.Client
+	// Execstream executes the functions with large resource contexts in
+	// chunks, the functions are executed with the Execclient when nil
+	Execstream execstream.Client
+	// Health checks the serving status of the images and their circuit
+	// breakers, the status is not reported when nil
+	Health healthpb.HealthClient
 }
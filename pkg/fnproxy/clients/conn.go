@@ -0,0 +1,73 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"sync"
+
+	"github.com/fnrunner/fnproto/pkg/executor/execclient"
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/execstream"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// NewFromConn returns the fn clients using an existing connection, e.g. a
+// in-memory or unix socket connection. Closing either client closes the
+// connection once.
+func NewFromConn(conn *grpc.ClientConn) *Clients {
+	closer := &connCloser{conn: conn}
+	return &Clients{
+		Execclient: &execConnClient{connCloser: closer, c: executorpb.NewFunctionExecutorClient(conn)},
+		Svcclient:  &svcConnClient{connCloser: closer, c: servicepb.NewFunctionServiceClient(conn)},
+		Execstream: execstream.NewClient(conn),
+		Health:     healthpb.NewHealthClient(conn),
+	}
+}
+
+// connCloser closes the connection shared by the clients once
+type connCloser struct {
+	conn *grpc.ClientConn
+	once sync.Once
+	err  error
+}
+
+func (r *connCloser) Close() error {
+	r.once.Do(func() {
+		r.err = r.conn.Close()
+	})
+	return r.err
+}
+
+type execConnClient struct {
+	*connCloser
+	c executorpb.FunctionExecutorClient
+}
+
+func (r *execConnClient) GetConfig() execclient.Config {
+	return execclient.Config{Address: r.conn.Target()}
+}
+
+func (r *execConnClient) Get() executorpb.FunctionExecutorClient { return r.c }
+
+type svcConnClient struct {
+	*connCloser
+	c servicepb.FunctionServiceClient
+}
+
+func (r *svcConnClient) Get() servicepb.FunctionServiceClient { return r.c }
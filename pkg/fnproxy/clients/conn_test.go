@@ -0,0 +1,47 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestNewFromConnClose(t *testing.T) {
+	_, dialer := NewInMemoryListener()
+	conn, err := grpc.Dial("passthrough:///pipe",
+		grpc.WithContextDialer(dialer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewFromConn(conn)
+	// the clients share the connection, it is closed once
+	if err := c.Execclient.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Svcclient.Close(); err != nil {
+		t.Errorf("expected closing the shared connection again to succeed, got: %v", err)
+	}
+	if s := conn.GetState(); s != connectivity.Shutdown {
+		t.Errorf("expected a closed connection, got: %s", s)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/proxyerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	defaultKeepalive = 30 * time.Second
+	keepaliveTimeout = 10 * time.Second
+	// the proxy runs next to the clients, a broken connection is retried
+	// quickly
+	reconnectMaxDelay = 5 * time.Second
+)
+
+// Config is the config of long-lived fn clients
+type Config struct {
+	Address string
+	// TLSConfig enables mTLS, insecure when nil
+	TLSConfig *tls.Config
+	// Keepalive is the interval of the keepalive pings, default 30s
+	Keepalive time.Duration
+	// MaxMsgSize is the max size of a sent and a received message, the grpc
+	// defaults apply when 0
+	MaxMsgSize int
+	// Dialer replaces the network dialer, e.g. to dial an in-memory listener
+	Dialer func(ctx context.Context, address string) (net.Conn, error)
+}
+
+// New returns fn clients sharing a connection which is kept alive and
+// reconnected with backoff when it breaks, they are meant to be shared for
+// the lifetime of the manager. The connection is established lazily.
+func New(cfg *Config) (*Clients, error) {
+	keepaliveTime := cfg.Keepalive
+	if keepaliveTime == 0 {
+		keepaliveTime = defaultKeepalive
+	}
+	bo := backoff.DefaultConfig
+	bo.MaxDelay = reconnectMaxDelay
+
+	opts := []grpc.DialOption{
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                keepaliveTime,
+			Timeout:             keepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           bo,
+			MinConnectTimeout: keepaliveTimeout,
+		}),
+		// the requests the proxy did not forward are retried after the
+		// delay the proxy asks for
+		grpc.WithChainUnaryInterceptor(proxyerr.UnaryClientInterceptor()),
+	}
+	if cfg.MaxMsgSize > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(cfg.MaxMsgSize),
+			grpc.MaxCallSendMsgSize(cfg.MaxMsgSize),
+		))
+	}
+	if cfg.TLSConfig != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(cfg.TLSConfig)))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if cfg.Dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(cfg.Dialer))
+	}
+	conn, err := grpc.Dial(cfg.Address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConn(conn), nil
+}
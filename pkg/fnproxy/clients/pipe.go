@@ -0,0 +1,79 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// NewInMemoryListener returns a listener serving a grpc server in memory and
+// the dialer of the fn clients to it, every dial is a net.Pipe of which the
+// listener accepts the server end
+func NewInMemoryListener() (net.Listener, func(ctx context.Context, address string) (net.Conn, error)) {
+	l := &pipeListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	return l, func(ctx context.Context, _ string) (net.Conn, error) {
+		return l.dial(ctx)
+	}
+}
+
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (r *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-r.conns:
+		return c, nil
+	case <-r.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (r *pipeListener) Close() error {
+	r.once.Do(func() { close(r.done) })
+	return nil
+}
+
+func (r *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+func (r *pipeListener) dial(ctx context.Context) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case r.conns <- server:
+		return client, nil
+	case <-r.done:
+		server.Close()
+		client.Close()
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		server.Close()
+		client.Close()
+		return nil, ctx.Err()
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
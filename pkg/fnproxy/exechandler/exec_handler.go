@@ -18,27 +18,93 @@ package exechandler
 
 import (
 	"context"
+	"strings"
 
 	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/memo"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/proxyerr"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
-func (r *subServer) ExecuteFuntion(ctx context.Context, req *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
+func (r *subServer) ExecuteFuntion(ctx context.Context, req *executorpb.ExecuteFunctionRequest) (resp *executorpb.ExecuteFunctionResponse, err error) {
 	r.l.Info("execute function", "image", req.Image, "controllerName", req.GetController())
 
+	// the images are resolved with the backends running them
 	imageStore := r.ctrlStore.GetImageStore(req.GetController())
-	if imageStore == nil {
-		return &executorpb.ExecuteFunctionResponse{}, ErrClientNotready
+	resolver := r.ctrlStore.GetImageResolver(req.GetController())
+	if imageStore == nil || resolver == nil {
+		return &executorpb.ExecuteFunctionResponse{}, proxyerr.UnknownController(req.GetController())
+	}
+	image := fnrunv1alpha1.Image{Name: req.GetImage(), Kind: fnrunv1alpha1.ImageKindFunction}
+	if resolver.Status(image) == imagestore.StatusUnknown {
+		return &executorpb.ExecuteFunctionResponse{}, proxyerr.UnknownImage(req.GetController(), req.GetImage())
+	}
+
+	key := ""
+	if r.memoize(req.GetController(), req.GetImage()) {
+		// without a digest the image content is unknown, so not memoized
+		if digest := imageStore.GetDigest(image); digest != "" {
+			key = memo.Key(digest, req.GetController(), req.GetResourceContext())
+		}
+	}
+	if key != "" {
+		if resp, ok := r.memo.Get(key); ok {
+			memo.Lookups.WithLabelValues(req.GetController(), req.GetImage(), memo.LookupHit).Inc()
+			if err := grpc.SetHeader(ctx, metadata.Pairs(fnrunv1alpha1.CacheHeaderKey, fnrunv1alpha1.CacheHit)); err != nil {
+				r.l.Info("cannot set cache header", "err", err)
+			}
+			return resp, nil
+		}
+		memo.Lookups.WithLabelValues(req.GetController(), req.GetImage(), memo.LookupMiss).Inc()
 	}
 
-	execclient := imageStore.GetFnClient(fnrunv1alpha1.Image{Name: req.GetImage(), Kind: fnrunv1alpha1.ImageKindFunction})
-	if execclient == nil {
-		return &executorpb.ExecuteFunctionResponse{}, ErrClientNotready
+	// an open breaker fails fast instead of waiting for the client of an
+	// image whose pods are crashing or ejected
+	done, err := r.allow(req.GetController(), req.GetImage())
+	if err != nil {
+		r.l.Info("circuit open", "image", req.GetImage(), "controllerName", req.GetController())
+		return &executorpb.ExecuteFunctionResponse{}, err
+	}
+	defer breaker.Report(done, &err)
+
+	// right after an update of the controller the image may not be ready yet,
+	// the request waits for it within its deadline
+	execclient, err := resolver.ResolveFn(ctx, image)
+	if err != nil {
+		r.l.Info("client not ready", "image", req.GetImage(), "controllerName", req.GetController(), "err", err)
+		return &executorpb.ExecuteFunctionResponse{}, proxyerr.WaitFailed(err, req.GetController(), req.GetImage())
 	}
+
 	r.l.Info("execute function", "client config", execclient.GetConfig())
-	resp, err := execclient.Get().ExecuteFunction(ctx, req)
+	resp, err = execclient.Get().ExecuteFunction(ctx, req)
 	if err != nil {
 		r.l.Info("cannot execute function", "err", err)
+		return resp, err
+	}
+	if key != "" {
+		r.memo.Add(key, resp)
+	}
+	return resp, nil
+}
+
+// memoize returns true if memoization is enabled and the image is not
+// excluded in the controller configmap
+func (r *subServer) memoize(controller, image string) bool {
+	if r.memo == nil {
+		return false
+	}
+	cm := r.ctrlStore.GetConfigMap(controller)
+	if cm == nil {
+		return true
+	}
+	for _, excluded := range strings.Split(cm.GetAnnotations()[fnrunv1alpha1.MemoizeExcludeAnnotationKey], ",") {
+		if strings.TrimSpace(excluded) == image {
+			return false
+		}
 	}
-	return resp, err
+	return true
 }
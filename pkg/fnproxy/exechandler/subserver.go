@@ -15,8 +15,12 @@ package exechandler
 
 import (
 	"context"
+	"errors"
 
 	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/memo"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/proxyerr"
 	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
 	"github.com/go-logr/logr"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -26,15 +30,55 @@ type SubServer interface {
 	ExecuteFuntion(ctx context.Context, in *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error)
 }
 
-func New(c ctrlstore.Store) SubServer {
+type Option func(*subServer)
+
+// WithMemoization serves repeated executions of the same image digest and
+// resourceContext from the cache
+func WithMemoization(c memo.Cache) Option {
+	return func(r *subServer) {
+		r.memo = c
+	}
+}
+
+// WithBreakers fails the requests to an image fast while its circuit breaker
+// is open
+func WithBreakers(b breaker.Breakers) Option {
+	return func(r *subServer) {
+		r.breakers = b
+	}
+}
+
+func New(c ctrlstore.Store, opts ...Option) SubServer {
 	r := &subServer{
 		l:         ctrl.Log.WithName("subserverExec"),
 		ctrlStore: c,
 	}
+	for _, o := range opts {
+		o(r)
+	}
 	return r
 }
 
 type subServer struct {
 	l         logr.Logger
 	ctrlStore ctrlstore.Store
+	memo      memo.Cache
+	breakers  breaker.Breakers
+}
+
+// allow returns the status of a rejected request if the circuit breaker of the
+// image is open, otherwise the result of the request is reported with done
+func (r *subServer) allow(controller, image string) (func(err error), error) {
+	if r.breakers == nil {
+		return func(error) {}, nil
+	}
+	done, err := r.breakers.Allow(controller, image)
+	if err != nil {
+		var oerr *breaker.OpenError
+		if errors.As(err, &oerr) {
+			return nil, proxyerr.CircuitOpen(oerr)
+		}
+		return nil, err
+	}
+	return done, nil
 }
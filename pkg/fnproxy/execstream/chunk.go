@@ -0,0 +1,159 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execstream
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/fnrunner/fnsdk/go/fn"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultChunkSize is the size above which a resource context is streamed
+// and the max size of the resources of a chunk
+const DefaultChunkSize = 1024 * 1024
+
+// Split splits the resource context in chunks, the first chunk holds the
+// function config and the results, the next chunks hold the resources of a
+// single gvk up to the chunk size. A resource larger than the chunk size is
+// sent in a chunk of its own.
+func Split(rctx *fn.ResourceContext, size int) ([][]byte, error) {
+	chunks := [][]byte{}
+	if err := split(rctx, size, func(chunk []byte) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// split marshals the chunks of the resource context one at a time and emits
+// them, a chunk is no longer referenced once emitted
+func split(rctx *fn.ResourceContext, size int, emit func(chunk []byte) error) error {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	b, err := json.Marshal(&fn.ResourceContext{
+		FunctionConfig: rctx.FunctionConfig,
+		Resources:      map[string][]runtime.RawExtension{},
+		Results:        rctx.Results,
+	})
+	if err != nil {
+		return err
+	}
+	if err := emit(b); err != nil {
+		return err
+	}
+
+	gvks := make([]string, 0, len(rctx.Resources))
+	for gvk := range rctx.Resources {
+		gvks = append(gvks, gvk)
+	}
+	sort.Strings(gvks)
+	for _, gvk := range gvks {
+		krmslice := rctx.Resources[gvk]
+		// an empty gvk is sent as well, the function may rely on the key
+		start, n := 0, 0
+		for idx, krm := range krmslice {
+			if idx > start && n+len(krm.Raw) > size {
+				b, err := marshalResources(gvk, krmslice[start:idx])
+				if err != nil {
+					return err
+				}
+				if err := emit(b); err != nil {
+					return err
+				}
+				start, n = idx, 0
+			}
+			n += len(krm.Raw)
+		}
+		b, err := marshalResources(gvk, krmslice[start:])
+		if err != nil {
+			return err
+		}
+		if err := emit(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalResources(gvk string, krmslice []runtime.RawExtension) ([]byte, error) {
+	return json.Marshal(&fn.ResourceContext{
+		Resources: map[string][]runtime.RawExtension{gvk: krmslice},
+	})
+}
+
+// Assembler assembles the resource context from its chunks
+type Assembler struct {
+	rctx *fn.ResourceContext
+}
+
+func NewAssembler() *Assembler {
+	return &Assembler{
+		rctx: &fn.ResourceContext{
+			Resources: map[string][]runtime.RawExtension{},
+		},
+	}
+}
+
+// Add merges the chunk in the resource context
+func (r *Assembler) Add(chunk []byte) error {
+	c := &fn.ResourceContext{}
+	if err := json.Unmarshal(chunk, c); err != nil {
+		return err
+	}
+	for k, v := range c.FunctionConfig {
+		if r.rctx.FunctionConfig == nil {
+			r.rctx.FunctionConfig = map[string]runtime.RawExtension{}
+		}
+		r.rctx.FunctionConfig[k] = v
+	}
+	for gvk, krmslice := range c.Resources {
+		if _, ok := r.rctx.Resources[gvk]; !ok {
+			r.rctx.Resources[gvk] = make([]runtime.RawExtension, 0, len(krmslice))
+		}
+		r.rctx.Resources[gvk] = append(r.rctx.Resources[gvk], krmslice...)
+	}
+	if c.Results != nil {
+		if r.rctx.Results == nil {
+			r.rctx.Results = &fn.Results{}
+		}
+		*r.rctx.Results = append(*r.rctx.Results, *c.Results...)
+	}
+	return nil
+}
+
+func (r *Assembler) ResourceContext() *fn.ResourceContext {
+	return r.rctx
+}
+
+// Size returns the approximate size of the serialized resource context
+func Size(rctx *fn.ResourceContext) int {
+	n := 0
+	for _, v := range rctx.FunctionConfig {
+		n += len(v.Raw)
+	}
+	for _, krmslice := range rctx.Resources {
+		for _, krm := range krmslice {
+			n += len(krm.Raw)
+		}
+	}
+	return n
+}
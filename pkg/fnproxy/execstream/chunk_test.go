@@ -0,0 +1,114 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fnrunner/fnsdk/go/fn"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func resource(name string, size int) runtime.RawExtension {
+	return runtime.RawExtension{Raw: []byte(fmt.Sprintf(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":%q},"data":{"a":%q}}`, name, strings.Repeat("x", size)))}
+}
+
+func testResourceContext() *fn.ResourceContext {
+	return &fn.ResourceContext{
+		FunctionConfig: map[string]runtime.RawExtension{"input": {Raw: []byte(`{"a":"b"}`)}},
+		Resources: map[string][]runtime.RawExtension{
+			"v1/ConfigMap": {resource("a", 100), resource("b", 100), resource("c", 100), resource("d", 500)},
+			"v1/Secret":    {resource("e", 10)},
+			// an empty gvk is kept, the function may rely on the key
+			"v1/Service": {},
+		},
+		Results: &fn.Results{{Message: "done"}},
+	}
+}
+
+func TestSplitAssemble(t *testing.T) {
+	for _, size := range []int{0, 1, 250, 1024} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			rctx := testResourceContext()
+			chunks, err := Split(rctx, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := NewAssembler()
+			for _, chunk := range chunks {
+				if err := a.Add(chunk); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := json.Marshal(rctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(a.ResourceContext())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("expected the assembled resource context\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestSplitSize(t *testing.T) {
+	chunks, err := Split(testResourceContext(), 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := &fn.ResourceContext{}
+	if err := json.Unmarshal(chunks[0], first); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.FunctionConfig) != 1 || first.Results == nil || len(first.Resources) != 0 {
+		t.Errorf("expected the first chunk to hold the function config and the results only, got %s", chunks[0])
+	}
+	// configmaps a,b | c | d larger than the chunk size on its own | secret
+	// e | the empty service gvk
+	if len(chunks) != 6 {
+		t.Fatalf("expected 6 chunks, got %d", len(chunks))
+	}
+	for _, chunk := range chunks[1:] {
+		c := &fn.ResourceContext{}
+		if err := json.Unmarshal(chunk, c); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Resources) != 1 {
+			t.Errorf("expected the resources of a single gvk per chunk, got %s", chunk)
+		}
+		if krmslice := c.Resources["v1/ConfigMap"]; len(krmslice) > 1 && Size(c) > 400 {
+			t.Errorf("expected the resources up to the chunk size, got %d bytes", Size(c))
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	rctx := &fn.ResourceContext{
+		FunctionConfig: map[string]runtime.RawExtension{"input": {Raw: []byte("1234")}},
+		Resources:      map[string][]runtime.RawExtension{"v1/ConfigMap": {{Raw: []byte("123456")}}},
+	}
+	if n := Size(rctx); n != 10 {
+		t.Errorf("expected size 10, got %d", n)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execstream
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnsdk/go/fn"
+	"google.golang.org/grpc"
+)
+
+// Execute executes the function with the resource context sent and received
+// in chunks over the stream, it returns the resource context and the log of
+// the function
+func Execute(ctx context.Context, c Client, image, controller string, rctx *fn.ResourceContext, size int, opts ...grpc.CallOption) (*fn.ResourceContext, []byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.ExecuteFunctionStream(ctx, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the chunks are marshaled while they are sent
+	if err := split(rctx, size, func(chunk []byte) error {
+		return stream.Send(&executorpb.ExecuteFunctionRequest{
+			ResourceContext: chunk,
+			Image:           image,
+			Controller:      controller,
+		})
+	}); err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	// on io.EOF the status of the stream is returned by recv
+	if err := stream.CloseSend(); err != nil {
+		return nil, nil, err
+	}
+	return recvAll(stream)
+}
+
+func recvAll(stream ClientStream) (*fn.ResourceContext, []byte, error) {
+	a := NewAssembler()
+	var log []byte
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return a.ResourceContext(), log, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		log = append(log, resp.GetLog()...)
+		if err := a.Add(resp.GetResourceContext()); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// ExecFn executes the function with the assembled resource context
+type ExecFn func(ctx context.Context, req *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error)
+
+// Serve receives the chunks of the resource context until the client closes
+// the send direction, executes the function and sends the resulting resource
+// context in chunks. The function is executed with the assembled resource
+// context in a single message, the pod has to accept messages of the size of
+// the resource context. The assembled resource context is released before
+// the function is executed and the response chunks are marshaled while they
+// are sent.
+func Serve(stream ServerStream, exec ExecFn, size int) error {
+	req, err := recvRequest(stream)
+	if err != nil {
+		return err
+	}
+	resp, err := exec(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	log := resp.GetLog()
+	rctx := &fn.ResourceContext{}
+	if err := json.Unmarshal(resp.GetResourceContext(), rctx); err != nil {
+		return err
+	}
+	first := true
+	return split(rctx, size, func(chunk []byte) error {
+		out := &executorpb.ExecuteFunctionResponse{ResourceContext: chunk}
+		// the log is sent once
+		if first {
+			out.Log = log
+			first = false
+		}
+		return stream.Send(out)
+	})
+}
+
+// recvRequest returns the request with the assembled resource context
+func recvRequest(stream ServerStream) (*executorpb.ExecuteFunctionRequest, error) {
+	a := NewAssembler()
+	var image, controller string
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		image, controller = req.GetImage(), req.GetController()
+		if err := a.Add(req.GetResourceContext()); err != nil {
+			return nil, err
+		}
+	}
+	b, err := json.Marshal(a.ResourceContext())
+	if err != nil {
+		return nil, err
+	}
+	return &executorpb.ExecuteFunctionRequest{
+		ResourceContext: b,
+		Image:           image,
+		Controller:      controller,
+	}, nil
+}
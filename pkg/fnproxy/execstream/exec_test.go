@@ -0,0 +1,130 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execstream_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/execstream"
+	"github.com/fnrunner/fnsdk/go/fn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type server struct {
+	exec execstream.ExecFn
+}
+
+func (r *server) ExecuteFunctionStream(stream execstream.ServerStream) error {
+	return execstream.Serve(stream, r.exec, 256)
+}
+
+// dial serves the grpc server in memory and returns a connection to it
+func dial(t *testing.T, register func(s *grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	l, dialer := clients.NewInMemoryListener()
+	s := grpc.NewServer()
+	register(s)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("pipe", grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestExecute(t *testing.T) {
+	rctx := &fn.ResourceContext{
+		FunctionConfig: map[string]runtime.RawExtension{"input": {Raw: []byte(`{"a":"b"}`)}},
+		Resources:      map[string][]runtime.RawExtension{},
+	}
+	for i := 0; i < 10; i++ {
+		rctx.Resources["v1/ConfigMap"] = append(rctx.Resources["v1/ConfigMap"], runtime.RawExtension{
+			Raw: []byte(fmt.Sprintf(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm%d"},"data":{"a":%q}}`, i, strings.Repeat("x", 100))),
+		})
+	}
+
+	var got *executorpb.ExecuteFunctionRequest
+	conn := dial(t, func(s *grpc.Server) {
+		execstream.RegisterServer(s, &server{exec: func(ctx context.Context, req *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
+			got = req
+			// the function returns the resources it got and a result
+			out := &fn.ResourceContext{}
+			if err := json.Unmarshal(req.GetResourceContext(), out); err != nil {
+				return nil, err
+			}
+			out.Results = &fn.Results{{Message: "done"}}
+			b, err := json.Marshal(out)
+			if err != nil {
+				return nil, err
+			}
+			return &executorpb.ExecuteFunctionResponse{ResourceContext: b, Log: []byte("log")}, nil
+		}})
+	})
+
+	out, log, err := execstream.Execute(context.Background(), execstream.NewClient(conn), "image", "controller", rctx, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetImage() != "image" || got.GetController() != "controller" {
+		t.Errorf("expected the image and controller of the request, got %s %s", got.GetImage(), got.GetController())
+	}
+	if string(log) != "log" {
+		t.Errorf("expected the log once, got %q", log)
+	}
+	if len(out.Resources["v1/ConfigMap"]) != 10 || out.Results == nil || len(*out.Results) != 1 {
+		t.Errorf("expected the resources and the result of the function, got %v", out)
+	}
+	for i, krm := range out.Resources["v1/ConfigMap"] {
+		if string(krm.Raw) != string(rctx.Resources["v1/ConfigMap"][i].Raw) {
+			t.Errorf("expected resource %d in order\n%s\ngot\n%s", i, rctx.Resources["v1/ConfigMap"][i].Raw, krm.Raw)
+		}
+	}
+}
+
+func TestExecuteError(t *testing.T) {
+	conn := dial(t, func(s *grpc.Server) {
+		execstream.RegisterServer(s, &server{exec: func(ctx context.Context, req *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
+			return nil, status.Error(codes.InvalidArgument, "invalid input")
+		}})
+	})
+	_, _, err := execstream.Execute(context.Background(), execstream.NewClient(conn), "image", "controller", &fn.ResourceContext{}, 256)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected the status of the function, got %v", err)
+	}
+}
+
+func TestExecuteUnimplemented(t *testing.T) {
+	// a server without the streaming service, the clients fall back to the
+	// unary call on Unimplemented
+	conn := dial(t, func(s *grpc.Server) {})
+	_, _, err := execstream.Execute(context.Background(), execstream.NewClient(conn), "image", "controller", &fn.ResourceContext{}, 256)
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected Unimplemented, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execstream
+
+import (
+	"context"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"google.golang.org/grpc"
+)
+
+// the streaming execute service of the proxy, the messages are the messages
+// of the unary FunctionExecutor service carrying a chunk of the resource
+// context each
+const (
+	ServiceName = "executor.FunctionExecutorStream"
+	MethodName  = "ExecuteFunctionStream"
+	FullMethod  = "/" + ServiceName + "/" + MethodName
+)
+
+type Server interface {
+	ExecuteFunctionStream(ServerStream) error
+}
+
+type ServerStream interface {
+	Send(*executorpb.ExecuteFunctionResponse) error
+	Recv() (*executorpb.ExecuteFunctionRequest, error)
+	grpc.ServerStream
+}
+
+type Client interface {
+	ExecuteFunctionStream(ctx context.Context, opts ...grpc.CallOption) (ClientStream, error)
+}
+
+type ClientStream interface {
+	Send(*executorpb.ExecuteFunctionRequest) error
+	Recv() (*executorpb.ExecuteFunctionResponse, error)
+	grpc.ClientStream
+}
+
+func RegisterServer(s grpc.ServiceRegistrar, srv Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+func NewClient(cc grpc.ClientConnInterface) Client {
+	return &client{cc: cc}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Server)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    MethodName,
+			Handler:       executeFunctionStreamHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+func executeFunctionStreamHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(Server).ExecuteFunctionStream(&serverStream{stream})
+}
+
+type serverStream struct {
+	grpc.ServerStream
+}
+
+func (r *serverStream) Send(m *executorpb.ExecuteFunctionResponse) error {
+	return r.ServerStream.SendMsg(m)
+}
+
+func (r *serverStream) Recv() (*executorpb.ExecuteFunctionRequest, error) {
+	m := new(executorpb.ExecuteFunctionRequest)
+	if err := r.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type client struct {
+	cc grpc.ClientConnInterface
+}
+
+func (r *client) ExecuteFunctionStream(ctx context.Context, opts ...grpc.CallOption) (ClientStream, error) {
+	stream, err := r.cc.NewStream(ctx, &serviceDesc.Streams[0], FullMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &clientStream{stream}, nil
+}
+
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (r *clientStream) Send(m *executorpb.ExecuteFunctionRequest) error {
+	return r.ClientStream.SendMsg(m)
+}
+
+func (r *clientStream) Recv() (*executorpb.ExecuteFunctionResponse, error) {
+	m := new(executorpb.ExecuteFunctionResponse)
+	if err := r.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
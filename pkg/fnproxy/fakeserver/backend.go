@@ -0,0 +1,83 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnsdk/go/fn"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type ExecuteFunc func(ctx context.Context, rctx *fn.ResourceContext) (*fn.ResourceContext, error)
+
+type ApplyResourceFunc func(ctx context.Context, u *unstructured.Unstructured) (*unstructured.Unstructured, error)
+
+type DeleteResourceFunc func(ctx context.Context, u *unstructured.Unstructured) error
+
+// Handler runs the functions of an image with Go functions. A nil apply
+// function returns the resource as is, a nil delete function is a no-op.
+type Handler struct {
+	ExecuteFn        ExecuteFunc
+	ApplyResourceFn  ApplyResourceFunc
+	DeleteResourceFn DeleteResourceFunc
+}
+
+func (r *Handler) Execute(ctx context.Context, req *executorpb.ExecuteFunctionRequest) ([]byte, error) {
+	if r.ExecuteFn == nil {
+		return nil, fmt.Errorf("image %s has no execute handler", req.GetImage())
+	}
+	rctx, err := fn.ParseResourceContext(req.GetResourceContext())
+	if err != nil {
+		return nil, err
+	}
+	rctx, err = r.ExecuteFn(ctx, rctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rctx)
+}
+
+func (r *Handler) ApplyResource(ctx context.Context, req *servicepb.FunctionServiceRequest) ([]byte, error) {
+	if r.ApplyResourceFn == nil {
+		return req.GetResource(), nil
+	}
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(req.GetResource(), u); err != nil {
+		return nil, err
+	}
+	u, err := r.ApplyResourceFn(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(u)
+}
+
+func (r *Handler) DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) error {
+	if r.DeleteResourceFn == nil {
+		return nil
+	}
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(req.GetResource(), u); err != nil {
+		return err
+	}
+	return r.DeleteResourceFn(ctx, u)
+}
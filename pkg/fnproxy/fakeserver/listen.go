@@ -0,0 +1,70 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1024 * 1024
+
+// ServeBufconn serves the server in memory until the context is done and
+// returns fn clients connected to it
+func ServeBufconn(ctx context.Context, s Server) (*clients.Clients, error) {
+	l := bufconn.Listen(bufSize)
+	go s.Serve(ctx, l)
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return clients.NewFromConn(conn), nil
+}
+
+// ServeUnix serves the server on a unix socket until the context is done and
+// returns fn clients connected to it, an existing socket file is replaced
+func ServeUnix(ctx context.Context, s Server, path string) (*clients.Clients, error) {
+	if err := os.RemoveAll(path); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	go s.Serve(ctx, l)
+
+	conn, err := grpc.DialContext(ctx, fmt.Sprintf("unix://%s", path),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return clients.NewFromConn(conn), nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakeserver provides an in-process function executor and function
+// service server for tests, which runs the functions of an image with a Go
+// handler, a local executable or a local container instead of a function pod.
+package fakeserver
+
+import (
+	"github.com/fnrunner/fnruntime/pkg/imgmanager/localserver"
+)
+
+// EnvOperation is the env variable telling an executable which service
+// operation it runs, it is not set for executions
+const EnvOperation = localserver.EnvOperation
+
+type (
+	Backend    = localserver.Backend
+	Server     = localserver.Server
+	Option     = localserver.Option
+	Executable = localserver.Executable
+	Container  = localserver.Container
+)
+
+func New(opts ...Option) Server {
+	return localserver.New(opts...)
+}
+
+// WithBackend runs the functions of the image with the backend
+func WithBackend(image string, b Backend) Option {
+	return localserver.WithBackend(image, b)
+}
+
+// WithHandler runs the functions of the image with Go handlers
+func WithHandler(image string, h *Handler) Option {
+	return localserver.WithBackend(image, h)
+}
+
+// WithExecutable runs the functions of the image with a local executable
+func WithExecutable(image string, e *Executable) Option {
+	return localserver.WithExecutable(image, e)
+}
+
+// WithContainer runs the functions of the image with a local container
+func WithContainer(image string, c *Container) Option {
+	return localserver.WithContainer(image, c)
+}
+
+// WithMaxMsgSize sets the max size of a received and a sent message, the
+// grpc defaults apply when 0
+func WithMaxMsgSize(n int) Option {
+	return localserver.WithMaxMsgSize(n)
+}
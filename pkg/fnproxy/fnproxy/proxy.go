@@ -18,26 +18,64 @@ package fnproxy
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"time"
 
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/exechandler"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/grpcserver"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/healthhandler"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/memo"
 	"github.com/fnrunner/fnruntime/pkg/fnproxy/servicehandler"
 	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
 	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 type Config struct {
 	ControllerStore ctrlstore.Store
+	// Memoization enables the memoization of the function executions
+	Memoization *memo.Config
+	// Breakers fail the requests of an image fast while its circuit breaker
+	// is open, the owner reports their state, e.g. in the health service, and
+	// deletes the breakers of the images which are no longer served
+	Breakers breaker.Breakers
+	// Limits bounds the function RPCs, the grpc server defaults apply when nil
+	Limits *Limits
+	// TLSConfig enables mTLS on the proxy, insecure when nil
+	TLSConfig *tls.Config
+	// Auth authenticates the RPCs, e.g. with the peer certificate, before
+	// they are logged and observed
+	Auth grpcserver.AuthFunc
+	// Health serves the grpc health service, a new one when nil
+	Health healthhandler.SubServer
+	// Listener serves the proxy on the listener as well, e.g. an in-memory
+	// listener for the clients in the same process
+	Listener net.Listener
+	// MaxMsgSize is the max size of a message of the proxy, the grpc
+	// defaults apply when 0
+	MaxMsgSize int
 	//Clientset      *kubernetes.Clientset
 	//FnWrapperImage string
 	//Images         []*fnrunv1alpha1.Image
 	//ConfigMap      *corev1.ConfigMap
 }
 
+type Limits struct {
+	// MaxRPC bounds the in-flight function RPCs
+	MaxRPC int64
+	// MaxRPCPerController and MaxRPCPerImage bound the in-flight function
+	// RPCs of a controller and of an image of a controller, unlimited when 0
+	MaxRPCPerController int64
+	MaxRPCPerImage      int64
+	// Timeout is the deadline of an RPC
+	Timeout time.Duration
+}
+
 type Proxy interface {
 	Start(ctx context.Context) error
 	Stop(ctx context.Context)
@@ -45,8 +83,45 @@ type Proxy interface {
 	//DeletePod(ctx context.Context, image fnrunv1alpha1.Image) error
 }
 
-func New(cfg *Config) Proxy {
+type Option func(*options)
+
+type options struct {
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	registerFns        []func(grpc.ServiceRegistrar)
+}
+
+// WithUnaryInterceptors adds the interceptors to the chain of the unary RPCs
+// after the recovery, auth, logging, metrics and deadline interceptors of
+// the proxy
+func WithUnaryInterceptors(i ...grpc.UnaryServerInterceptor) Option {
+	return func(o *options) {
+		o.unaryInterceptors = append(o.unaryInterceptors, i...)
+	}
+}
+
+// WithStreamInterceptors adds the interceptors to the chain of the stream
+// RPCs after the interceptors of the proxy
+func WithStreamInterceptors(i ...grpc.StreamServerInterceptor) Option {
+	return func(o *options) {
+		o.streamInterceptors = append(o.streamInterceptors, i...)
+	}
+}
+
+// WithServices registers additional services on the proxy server, they are
+// served behind the interceptors of the proxy
+func WithServices(fns ...func(grpc.ServiceRegistrar)) Option {
+	return func(o *options) {
+		o.registerFns = append(o.registerFns, fns...)
+	}
+}
+
+func New(cfg *Config, opts ...Option) Proxy {
 	l := ctrl.Log.WithName("fn proxy")
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
 
 	/*
 		namespace := os.Getenv("POD_NAMESPACE")
@@ -70,20 +145,75 @@ func New(cfg *Config) Proxy {
 		}
 	*/
 
-	hh := healthhandler.New()
-	sh := servicehandler.New(cfg.ControllerStore)
-	eh := exechandler.New(cfg.ControllerStore)
+	hh := cfg.Health
+	if hh == nil {
+		hh = healthhandler.New()
+	}
+	shOpts := []servicehandler.Option{}
+	ehOpts := []exechandler.Option{}
+	if cfg.Breakers != nil {
+		shOpts = append(shOpts, servicehandler.WithBreakers(cfg.Breakers))
+		ehOpts = append(ehOpts, exechandler.WithBreakers(cfg.Breakers))
+	}
+	sh := servicehandler.New(cfg.ControllerStore, shOpts...)
+	if cfg.Memoization != nil {
+		ehOpts = append(ehOpts, exechandler.WithMemoization(memo.New(cfg.Memoization)))
+	}
+	eh := exechandler.New(cfg.ControllerStore, ehOpts...)
 
-	s := grpcserver.New(grpcserver.Config{
-		Address:  fmt.Sprintf(":%d", fnrunv1alpha1.FnProxyGRPCServerPort),
-		Insecure: true,
-	},
+	sCfg := grpcserver.Config{
+		Address:    fmt.Sprintf(":%d", fnrunv1alpha1.FnProxyGRPCServerPort),
+		Insecure:   cfg.TLSConfig == nil,
+		TLSConfig:  cfg.TLSConfig,
+		MaxMsgSize: cfg.MaxMsgSize,
+	}
+	if cfg.Limits != nil {
+		sCfg.MaxRPC = cfg.Limits.MaxRPC
+		sCfg.MaxRPCPerController = cfg.Limits.MaxRPCPerController
+		sCfg.MaxRPCPerImage = cfg.Limits.MaxRPCPerImage
+		sCfg.Timeout = cfg.Limits.Timeout
+	}
+	// the deadline interceptors are the only place bounding the deadline of
+	// the RPCs
+	if sCfg.Timeout <= 0 {
+		sCfg.Timeout = grpcserver.DefaultTimeout
+	}
+	// the recovery is the outermost interceptor to recover the panics of the
+	// other interceptors as well, the unauthenticated RPCs are rejected before
+	// they are logged and observed
+	rl := l.WithName("rpc")
+	unary := []grpc.UnaryServerInterceptor{grpcserver.RecoveryUnaryInterceptor(rl)}
+	stream := []grpc.StreamServerInterceptor{grpcserver.RecoveryStreamInterceptor(rl)}
+	if cfg.Auth != nil {
+		unary = append(unary, grpcserver.AuthUnaryInterceptor(cfg.Auth))
+		stream = append(stream, grpcserver.AuthStreamInterceptor(cfg.Auth))
+	}
+	unary = append(unary,
+		grpcserver.LoggingUnaryInterceptor(rl),
+		grpcserver.MetricsUnaryInterceptor(knownImage(cfg.ControllerStore)),
+		grpcserver.DeadlineUnaryInterceptor(sCfg.Timeout),
+	)
+	unary = append(unary, o.unaryInterceptors...)
+	stream = append(stream,
+		grpcserver.LoggingStreamInterceptor(rl),
+		grpcserver.MetricsStreamInterceptor(),
+		grpcserver.DeadlineStreamInterceptor(sCfg.Timeout),
+	)
+	stream = append(stream, o.streamInterceptors...)
+	sOpts := []grpcserver.Option{
+		grpcserver.WithUnaryInterceptors(unary...),
+		grpcserver.WithStreamInterceptors(stream...),
 		grpcserver.WithServiceApplyResourceHandler(sh.ApplyResource),
 		grpcserver.WithServiceDeleteResourceHandler(sh.DeleteResource),
 		grpcserver.WithExecHandler(eh.ExecuteFuntion),
 		grpcserver.WithWatchHandler(hh.Watch),
 		grpcserver.WithCheckHandler(hh.Check),
-	)
+		grpcserver.WithServices(o.registerFns...),
+	}
+	if cfg.Listener != nil {
+		sOpts = append(sOpts, grpcserver.WithListener(cfg.Listener))
+	}
+	s := grpcserver.New(sCfg, sOpts...)
 
 	return &proxy{
 		s: s,
@@ -96,6 +226,23 @@ func New(cfg *Config) Proxy {
 	}
 }
 
+// knownImage reports whether the image is an image of a controller in the
+// store
+func knownImage(ctrlStore ctrlstore.Store) grpcserver.KnownFunc {
+	return func(controller, image string) bool {
+		is := ctrlStore.GetImageStore(controller)
+		if is == nil {
+			return false
+		}
+		for _, i := range is.List() {
+			if i.Name == image {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 type proxy struct {
 	s *grpcserver.GrpcServer
 	//clientset      *kubernetes.Clientset
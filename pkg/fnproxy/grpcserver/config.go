@@ -14,6 +14,7 @@ limitations under the License.
 package grpcserver
 
 import (
+	"crypto/tls"
 	"fmt"
 	"time"
 
@@ -21,8 +22,9 @@ import (
 )
 
 const (
-	defaultMaxRPC  = 600
-	defaultTimeout = time.Minute
+	defaultMaxRPC = 600
+	// DefaultTimeout is the default deadline of the RPCs
+	DefaultTimeout = time.Minute
 )
 
 type Config struct {
@@ -32,12 +34,26 @@ type Config struct {
 	// insecure server
 	Insecure bool
 
-	// MaxRPC
+	// MaxRPC bounds the in-flight function RPCs, the health and admin RPCs
+	// are not limited
 	MaxRPC int64
 
-	// request timeout
+	// MaxRPCPerController bounds the in-flight function RPCs of a controller,
+	// unlimited when 0
+	MaxRPCPerController int64
+
+	// MaxRPCPerImage bounds the in-flight function RPCs of an image of a
+	// controller, unlimited when 0
+	MaxRPCPerImage int64
+
+	// Timeout bounds the deadline of the RPCs, it is applied by the
+	// DeadlineUnaryInterceptor and DeadlineStreamInterceptor
 	Timeout time.Duration
 
+	// MaxMsgSize is the max size of a received and a sent message, the grpc
+	// defaults apply when 0. Larger resource contexts are streamed in chunks.
+	MaxMsgSize int
+
 	// CertDir is the directory that contains the server key and certificate. The
 	// server key and certificate.
 	CertDir string
@@ -50,6 +66,10 @@ type Config struct {
 
 	// CaName is the ca certificate name. Defaults to ca.crt.
 	CaName string
+
+	// TLSConfig is used instead of the certificates in the CertDir, e.g.
+	// a tls config issued by an in memory CA
+	TLSConfig *tls.Config
 }
 
 func (c *Config) setDefaults() {
@@ -75,6 +95,6 @@ func (c *Config) setDefaults() {
 		}
 	*/
 	if c.Timeout <= 0 {
-		c.Timeout = defaultTimeout
+		c.Timeout = DefaultTimeout
 	}
 }
@@ -20,16 +20,28 @@ import (
 	"context"
 
 	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/execstream"
 )
 
 func (r *GrpcServer) ExecuteFunction(ctx context.Context, req *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
 	r.l.Info("execute fn", "req", req)
-	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
-	defer cancel()
-	err := r.acquireSem(ctx)
+	return r.execHandler(ctx, req)
+}
+
+// ExecuteFunctionStream executes the function with the resource context
+// received and sent in chunks
+func (r *GrpcServer) ExecuteFunctionStream(stream execstream.ServerStream) error {
+	r.l.Info("execute fn stream")
+	return execstream.Serve(stream, r.executeFunction, execstream.DefaultChunkSize)
+}
+
+// executeFunction executes the assembled request of a stream, it does not
+// pass the unary interceptors so it is admitted by the limiter here
+func (r *GrpcServer) executeFunction(ctx context.Context, req *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
+	release, err := r.limiter.acquire(ctx, req.GetController(), req.GetImage())
 	if err != nil {
 		return nil, err
 	}
-	defer r.sem.Release(1)
-	return r.execHandler(ctx, req)
+	defer release()
+	return r.ExecuteFunction(ctx, req)
 }
@@ -24,23 +24,9 @@ import (
 )
 
 func (r *GrpcServer) ApplyResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (*servicepb.FunctionServiceResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
-	defer cancel()
-	err := r.acquireSem(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer r.sem.Release(1)
 	return r.applyResourceHandler(ctx, req)
 }
 
 func (r *GrpcServer) DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (*emptypb.Empty, error) {
-	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
-	defer cancel()
-	err := r.acquireSem(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer r.sem.Release(1)
 	return r.deleteResourceHandler(ctx, req)
 }
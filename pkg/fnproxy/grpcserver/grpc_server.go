@@ -20,9 +20,9 @@ import (
 
 	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
 	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/execstream"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
-	"golang.org/x/sync/semaphore"
 	"google.golang.org/grpc"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
@@ -34,7 +34,9 @@ type GrpcServer struct {
 	executorpb.UnimplementedFunctionExecutorServer
 	servicepb.UnimplementedFunctionServiceServer
 
-	sem *semaphore.Weighted
+	// limiter bounds the function RPCs with fair queuing between controllers,
+	// the unary function RPCs are admitted by its interceptor
+	limiter *limiter
 
 	// logger
 	l logr.Logger
@@ -49,6 +51,14 @@ type GrpcServer struct {
 	//health handlers
 	checkHandler CheckHandler
 	watchHandler WatchHandler
+
+	// interceptors are chained in the order they are added
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	// listeners are served next to the listener on the address
+	listeners []net.Listener
+	// registerFns register additional services on the server
+	registerFns []func(grpc.ServiceRegistrar)
 	//
 	// cached certificate
 	cm *sync.Mutex
@@ -61,7 +71,7 @@ type CheckHandler func(context.Context, *healthpb.HealthCheckRequest) (*healthpb
 
 type WatchHandler func(*healthpb.HealthCheckRequest, healthpb.Health_WatchServer) error
 
-// ExecHandler
+// Exec Handlers
 type ExecHandler func(context.Context, *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error)
 
 // Service Handlers
@@ -74,9 +84,9 @@ type Option func(*GrpcServer)
 func New(c Config, opts ...Option) *GrpcServer {
 	c.setDefaults()
 	s := &GrpcServer{
-		config: c,
-		sem:    semaphore.NewWeighted(c.MaxRPC),
-		cm:     &sync.Mutex{},
+		config:  c,
+		limiter: newLimiter(c.MaxRPC, c.MaxRPCPerController, c.MaxRPCPerImage),
+		cm:      &sync.Mutex{},
 	}
 
 	for _, o := range opts {
@@ -121,11 +131,24 @@ func (r *GrpcServer) Start(ctx context.Context) error {
 	r.l.Info("grpc server with service function...")
 
 	executorpb.RegisterFunctionExecutorServer(grpcServer, r)
+	execstream.RegisterServer(grpcServer, r)
 	r.l.Info("grpc server with exec function...")
 
 	healthpb.RegisterHealthServer(grpcServer, r)
 	r.l.Info("grpc server with health...")
 
+	for _, register := range r.registerFns {
+		register(grpcServer)
+	}
+
+	for _, el := range r.listeners {
+		go func(el net.Listener) {
+			if err := grpcServer.Serve(el); err != nil {
+				r.l.Info("gRPC serve failed", "listener", el.Addr().String(), "error", err)
+			}
+		}(el)
+	}
+
 	r.l.Info("starting grpc server...")
 	err = grpcServer.Serve(l)
 	if err != nil {
@@ -165,11 +188,34 @@ func WithServiceDeleteResourceHandler(h DeleteResourceHandler) func(*GrpcServer)
 	}
 }
 
-func (s *GrpcServer) acquireSem(ctx context.Context) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
-		return s.sem.Acquire(ctx, 1)
+// WithUnaryInterceptors adds the interceptors to the chain of the unary RPCs,
+// the first interceptor is the outermost
+func WithUnaryInterceptors(i ...grpc.UnaryServerInterceptor) func(*GrpcServer) {
+	return func(s *GrpcServer) {
+		s.unaryInterceptors = append(s.unaryInterceptors, i...)
+	}
+}
+
+// WithStreamInterceptors adds the interceptors to the chain of the stream
+// RPCs, the first interceptor is the outermost
+func WithStreamInterceptors(i ...grpc.StreamServerInterceptor) func(*GrpcServer) {
+	return func(s *GrpcServer) {
+		s.streamInterceptors = append(s.streamInterceptors, i...)
+	}
+}
+
+// WithServices registers additional services on the server, e.g. the admin
+// service
+func WithServices(fns ...func(grpc.ServiceRegistrar)) func(*GrpcServer) {
+	return func(s *GrpcServer) {
+		s.registerFns = append(s.registerFns, fns...)
+	}
+}
+
+// WithListener serves the server on the listener as well, e.g. on an in-memory
+// listener for in-process clients
+func WithListener(l net.Listener) func(*GrpcServer) {
+	return func(s *GrpcServer) {
+		s.listeners = append(s.listeners, l)
 	}
 }
@@ -23,14 +23,6 @@ import (
 
 // Check implements `service Health`.
 func (r *GrpcServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
-	defer cancel()
-	err := r.acquireSem(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer r.sem.Release(1)
-
 	if r.checkHandler != nil {
 		return r.checkHandler(ctx, in)
 	}
@@ -40,12 +32,6 @@ func (r *GrpcServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest)
 
 // Watch implements `service Health`.
 func (r *GrpcServer) Watch(in *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
-	err := r.acquireSem(stream.Context())
-	if err != nil {
-		return err
-	}
-	defer r.sem.Release(1)
-
 	if r.watchHandler != nil {
 		return r.watchHandler(in, stream)
 	}
@@ -0,0 +1,278 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// RPCDuration observes the latency of the RPCs per method, controller,
+	// image and status code. The controller and image are unknownLabel when
+	// they are not known to the proxy, e.g. for streams.
+	RPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "fnrun_proxy_rpc_duration_seconds",
+		Help:    "Latency of the proxy RPCs",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"method", "controller", "image", "code"})
+)
+
+const unknownLabel = "unknown"
+
+func init() {
+	metrics.Registry.MustRegister(RPCDuration)
+}
+
+// KnownFunc reports whether the image is an image of the controller
+type KnownFunc func(controller, image string) bool
+
+// AuthFunc authenticates an RPC, a non status error is returned as
+// unauthenticated. The returned ctx is passed to the handler.
+type AuthFunc func(ctx context.Context, fullMethod string) (context.Context, error)
+
+// functionRequest is implemented by the exec and service requests
+type functionRequest interface {
+	GetController() string
+	GetImage() string
+}
+
+func requestFields(req any) (string, string) {
+	if fr, ok := req.(functionRequest); ok {
+		return fr.GetController(), fr.GetImage()
+	}
+	return "", ""
+}
+
+// RecoveryUnaryInterceptor returns a panic of the handler as an internal
+// error instead of crashing the manager
+func RecoveryUnaryInterceptor(l logr.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(l, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func RecoveryStreamInterceptor(l logr.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(l, info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(l logr.Logger, method string, p any) error {
+	l.Error(fmt.Errorf("%v", p), "panic in grpc handler", "method", method, "stack", string(debug.Stack()))
+	return status.Errorf(codes.Internal, "panic in %s", method)
+}
+
+// LoggingUnaryInterceptor adds a logger with the method, controller and image
+// of the request to the ctx of the handler and logs the result of the RPC
+func LoggingUnaryInterceptor(l logr.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		controller, image := requestFields(req)
+		rl := l.WithValues("method", info.FullMethod, "controller", controller, "image", image)
+		start := time.Now()
+		resp, err := handler(log.IntoContext(ctx, rl), req)
+		if err != nil {
+			rl.Info("rpc failed", "code", status.Code(err).String(), "duration", time.Since(start), "err", err)
+			return resp, err
+		}
+		rl.V(1).Info("rpc done", "duration", time.Since(start))
+		return resp, nil
+	}
+}
+
+func LoggingStreamInterceptor(l logr.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rl := l.WithValues("method", info.FullMethod)
+		start := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: log.IntoContext(ss.Context(), rl)})
+		if err != nil {
+			rl.Info("stream failed", "code", status.Code(err).String(), "duration", time.Since(start), "err", err)
+			return err
+		}
+		rl.V(1).Info("stream done", "duration", time.Since(start))
+		return nil
+	}
+}
+
+// MetricsUnaryInterceptor observes the latency of the RPCs in RPCDuration,
+// the controller and image of the request are only used as labels when known
+// so the callers cannot create series
+func MetricsUnaryInterceptor(known KnownFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		controller, image := requestFields(req)
+		if known == nil || !known(controller, image) {
+			controller, image = unknownLabel, unknownLabel
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		RPCDuration.WithLabelValues(info.FullMethod, controller, image, status.Code(err).String()).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// MetricsStreamInterceptor observes the latency of the streams in
+// RPCDuration, the controller and image are sent in the stream and unknown
+// when the stream starts
+func MetricsStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		RPCDuration.WithLabelValues(info.FullMethod, unknownLabel, unknownLabel, status.Code(err).String()).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// DeadlineUnaryInterceptor bounds the deadline of the RPCs to the timeout,
+// RPCs whose deadline already expired are rejected before the handler runs.
+// The deadline is not bounded when the timeout is 0.
+func DeadlineUnaryInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// DeadlineStreamInterceptor bounds the deadline of the client streams to the
+// timeout like DeadlineUnaryInterceptor. Server streams, e.g. the health
+// watches, are long-lived and are not bounded.
+func DeadlineStreamInterceptor(timeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		if timeout <= 0 || !info.IsClientStream {
+			return handler(srv, ss)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// AuthUnaryInterceptor authenticates the RPCs with the AuthFunc
+func AuthUnaryInterceptor(fn AuthFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, fn, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func AuthStreamInterceptor(fn AuthFunc) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), fn, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authenticate(ctx context.Context, fn AuthFunc, method string) (context.Context, error) {
+	actx, err := fn(ctx, method)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if actx == nil {
+		return ctx, nil
+	}
+	return actx, nil
+}
+
+// CommonNameAuth authenticates the peers presenting a verified client
+// certificate with one of the common names
+func CommonNameAuth(commonNames ...string) AuthFunc {
+	allowed := map[string]struct{}{}
+	for _, cn := range commonNames {
+		allowed[cn] = struct{}{}
+	}
+	return func(ctx context.Context, fullMethod string) (context.Context, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "no peer")
+		}
+		tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "no verified client certificate")
+		}
+		cn := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+		if _, ok := allowed[cn]; !ok {
+			return nil, status.Errorf(codes.PermissionDenied, "client %s is not allowed", cn)
+		}
+		return ctx, nil
+	}
+}
+
+// LoopbackAuth authenticates the peers connecting over the loopback
+// interface or in memory, the proxy is only called by the reconcilers in the
+// same process when it runs without mTLS
+func LoopbackAuth() AuthFunc {
+	return func(ctx context.Context, fullMethod string) (context.Context, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "no peer")
+		}
+		if addr, ok := p.Addr.(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+			return nil, status.Errorf(codes.PermissionDenied, "client %s is not a loopback client", addr)
+		}
+		return ctx, nil
+	}
+}
+
+// serverStream replaces the ctx of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (r *serverStream) Context() context.Context { return r.ctx }
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDeadlineUnaryInterceptor(t *testing.T) {
+	cases := map[string]struct {
+		timeout        time.Duration
+		clientDeadline time.Duration
+		// expected is the max remaining time in the handler, 0 for none
+		expected time.Duration
+		code     codes.Code
+	}{
+		"Bounded":         {timeout: time.Second, expected: time.Second},
+		"ShorterClient":   {timeout: time.Minute, clientDeadline: time.Second, expected: time.Second},
+		"LongerClient":    {timeout: time.Second, clientDeadline: time.Minute, expected: time.Second},
+		"Unbounded":       {},
+		"UnboundedClient": {clientDeadline: time.Second, expected: time.Second},
+		"ExpiredClient":   {timeout: time.Minute, clientDeadline: -time.Second, code: codes.DeadlineExceeded},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.clientDeadline != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.clientDeadline)
+				defer cancel()
+			}
+			called := false
+			_, err := DeadlineUnaryInterceptor(tc.timeout)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				called = true
+				deadline, ok := ctx.Deadline()
+				if tc.expected == 0 {
+					if ok {
+						t.Errorf("expected no deadline, got %s", time.Until(deadline))
+					}
+					return nil, nil
+				}
+				if !ok || time.Until(deadline) > tc.expected {
+					t.Errorf("expected a deadline within %s, got %s", tc.expected, time.Until(deadline))
+				}
+				return nil, nil
+			})
+			if status.Code(err) != tc.code {
+				t.Errorf("expected %s, got %v", tc.code, err)
+			}
+			if called != (tc.code == codes.OK) {
+				t.Errorf("expected the handler to be called: %t", tc.code == codes.OK)
+			}
+		})
+	}
+}
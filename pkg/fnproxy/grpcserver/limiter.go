@@ -0,0 +1,204 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcserver
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// limiter bounds the in-flight function RPCs globally, per controller and per
+// image of a controller. The waiting RPCs are admitted round robin between the
+// controllers, so a controller with a burst of RPCs cannot starve the others.
+type limiter struct {
+	max           int64
+	maxController int64
+	maxImage      int64
+
+	m          sync.Mutex
+	inflight   int64
+	controller map[string]int64
+	image      map[imageKey]int64
+	// queues holds the waiters per controller, order holds the controllers
+	// with waiters in the order they are served
+	queues map[string]*list.List
+	order  []string
+	next   int
+}
+
+type imageKey struct {
+	controller string
+	image      string
+}
+
+type waiter struct {
+	key      imageKey
+	ready    chan struct{}
+	admitted bool
+}
+
+// newLimiter returns a limiter, a per controller or image limit <= 0 is
+// unlimited
+func newLimiter(max, maxController, maxImage int64) *limiter {
+	return &limiter{
+		max:           max,
+		maxController: maxController,
+		maxImage:      maxImage,
+		controller:    map[string]int64{},
+		image:         map[imageKey]int64{},
+		queues:        map[string]*list.List{},
+	}
+}
+
+// unaryInterceptor runs the handlers of the function requests within a slot
+// of the limiter, the other requests, e.g. the health checks and the admin
+// requests, are not limited. It is the innermost interceptor, so the wait for
+// a slot is bounded by the deadline of the request.
+func (r *limiter) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		fr, ok := req.(functionRequest)
+		if !ok {
+			return handler(ctx, req)
+		}
+		release, err := r.acquire(ctx, fr.GetController(), fr.GetImage())
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// acquire waits for a slot of the controller and image, the returned release
+// frees the slot
+func (r *limiter) acquire(ctx context.Context, controller, image string) (func(), error) {
+	w := &waiter{
+		key:   imageKey{controller: controller, image: image},
+		ready: make(chan struct{}),
+	}
+	r.m.Lock()
+	q, ok := r.queues[controller]
+	if !ok {
+		q = list.New()
+		r.queues[controller] = q
+		r.order = append(r.order, controller)
+	}
+	e := q.PushBack(w)
+	r.dispatch()
+	r.m.Unlock()
+
+	select {
+	case <-w.ready:
+		return func() { r.release(w.key) }, nil
+	case <-ctx.Done():
+		r.m.Lock()
+		defer r.m.Unlock()
+		if w.admitted {
+			// admitted concurrently with the cancellation
+			r.releaseLocked(w.key)
+		} else {
+			r.remove(controller, e)
+		}
+		return nil, status.Errorf(codes.ResourceExhausted, "no capacity for image %s of controller %s within the request deadline", image, controller)
+	}
+}
+
+func (r *limiter) release(key imageKey) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.releaseLocked(key)
+}
+
+func (r *limiter) releaseLocked(key imageKey) {
+	r.inflight--
+	r.controller[key.controller]--
+	if r.controller[key.controller] <= 0 {
+		delete(r.controller, key.controller)
+	}
+	r.image[key]--
+	if r.image[key] <= 0 {
+		delete(r.image, key)
+	}
+	r.dispatch()
+}
+
+// dispatch admits the waiters while there is capacity, one controller at a
+// time. Within a controller the first waiter whose image has capacity is
+// admitted. The caller holds the lock.
+func (r *limiter) dispatch() {
+	for r.inflight < r.max && len(r.order) > 0 {
+		admitted := false
+		for i := 0; i < len(r.order); i++ {
+			idx := (r.next + i) % len(r.order)
+			controller := r.order[idx]
+			if r.maxController > 0 && r.controller[controller] >= r.maxController {
+				continue
+			}
+			q := r.queues[controller]
+			for e := q.Front(); e != nil; e = e.Next() {
+				w := e.Value.(*waiter)
+				if r.maxImage > 0 && r.image[w.key] >= r.maxImage {
+					continue
+				}
+				r.inflight++
+				r.controller[controller]++
+				r.image[w.key]++
+				w.admitted = true
+				close(w.ready)
+				// the next round starts with the next controller
+				r.next = idx + 1
+				r.remove(controller, e)
+				admitted = true
+				break
+			}
+			if admitted {
+				break
+			}
+		}
+		if !admitted {
+			return
+		}
+	}
+}
+
+// remove removes the waiter from the queue of the controller and the
+// controller from the order once it has no waiters. The caller holds the lock.
+func (r *limiter) remove(controller string, e *list.Element) {
+	q := r.queues[controller]
+	q.Remove(e)
+	if q.Len() > 0 {
+		return
+	}
+	delete(r.queues, controller)
+	for i, c := range r.order {
+		if c == controller {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			if r.next > i {
+				r.next--
+			}
+			break
+		}
+	}
+	if len(r.order) == 0 || r.next >= len(r.order) {
+		r.next = 0
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// queued returns the amount of waiters of the limiter
+func (r *limiter) queued() int {
+	r.m.Lock()
+	defer r.m.Unlock()
+	n := 0
+	for _, q := range r.queues {
+		n += q.Len()
+	}
+	return n
+}
+
+func waitQueued(t *testing.T, l *limiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for l.queued() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiters, got %d", n, l.queued())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type admitted struct {
+	name    string
+	release func()
+}
+
+// enqueue acquires a slot in the background and waits until the request is
+// queued or admitted, the admitted requests are sent on the channel in the
+// order they are admitted
+func enqueue(t *testing.T, l *limiter, name, controller, image string, ch chan<- admitted) {
+	t.Helper()
+	before := l.queued()
+	go func() {
+		release, err := l.acquire(context.Background(), controller, image)
+		if err != nil {
+			t.Errorf("acquire %s: %v", name, err)
+			return
+		}
+		ch <- admitted{name: name, release: release}
+	}()
+	waitQueued(t, l, before+1)
+}
+
+func mustAcquire(t *testing.T, l *limiter, controller, image string) func() {
+	t.Helper()
+	release, err := l.acquire(context.Background(), controller, image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+func expectAdmitted(t *testing.T, ch <-chan admitted, name string) admitted {
+	t.Helper()
+	select {
+	case a := <-ch:
+		if a.name != name {
+			t.Fatalf("expected %s to be admitted, got %s", name, a.name)
+		}
+		return a
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected %s to be admitted", name)
+	}
+	return admitted{}
+}
+
+func expectNotAdmitted(t *testing.T, ch <-chan admitted) {
+	t.Helper()
+	select {
+	case a := <-ch:
+		t.Fatalf("expected no request to be admitted, got %s", a.name)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestLimiterMax(t *testing.T) {
+	l := newLimiter(2, 0, 0)
+	r1 := mustAcquire(t, l, "a", "img")
+	r2 := mustAcquire(t, l, "b", "img")
+
+	ch := make(chan admitted, 1)
+	enqueue(t, l, "3", "a", "img", ch)
+	expectNotAdmitted(t, ch)
+	r1()
+	expectAdmitted(t, ch, "3").release()
+	r2()
+	if l.inflight != 0 || len(l.controller) != 0 || len(l.image) != 0 {
+		t.Errorf("expected no in-flight requests, got %d", l.inflight)
+	}
+}
+
+func TestLimiterPerController(t *testing.T) {
+	l := newLimiter(10, 1, 0)
+	ra := mustAcquire(t, l, "a", "img")
+
+	ch := make(chan admitted, 1)
+	enqueue(t, l, "a2", "a", "img", ch)
+	// another controller is not limited by the full controller
+	mustAcquire(t, l, "b", "img")()
+	expectNotAdmitted(t, ch)
+	ra()
+	expectAdmitted(t, ch, "a2").release()
+}
+
+func TestLimiterPerImage(t *testing.T) {
+	l := newLimiter(10, 0, 1)
+	r1 := mustAcquire(t, l, "a", "img1")
+
+	ch := make(chan admitted, 1)
+	enqueue(t, l, "img1", "a", "img1", ch)
+	// a request of another image of the controller is not blocked by the
+	// waiter in front of it
+	mustAcquire(t, l, "a", "img2")()
+	expectNotAdmitted(t, ch)
+	r1()
+	expectAdmitted(t, ch, "img1").release()
+}
+
+func TestLimiterFairQueuing(t *testing.T) {
+	l := newLimiter(1, 0, 0)
+	r := mustAcquire(t, l, "a", "img")
+
+	// controller a has a burst of requests queued before the request of b
+	ch := make(chan admitted, 4)
+	enqueue(t, l, "a1", "a", "img", ch)
+	enqueue(t, l, "a2", "a", "img", ch)
+	enqueue(t, l, "a3", "a", "img", ch)
+	enqueue(t, l, "b1", "b", "img", ch)
+
+	r()
+	for _, name := range []string{"a1", "b1", "a2", "a3"} {
+		expectAdmitted(t, ch, name).release()
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := newLimiter(1, 0, 0)
+	r := mustAcquire(t, l, "a", "img")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := l.acquire(ctx, "b", "img")
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if l.queued() != 0 || len(l.order) != 0 {
+		t.Fatalf("expected the cancelled waiter to be removed, got %d waiters", l.queued())
+	}
+	r()
+	// the slot is not leaked
+	mustAcquire(t, l, "b", "img")()
+	if l.inflight != 0 {
+		t.Errorf("expected no in-flight requests, got %d", l.inflight)
+	}
+}
+
+func TestLimiterUnaryInterceptor(t *testing.T) {
+	l := newLimiter(1, 0, 0)
+	interceptor := l.unaryInterceptor()
+	info := &grpc.UnaryServerInfo{}
+
+	var inflight int64
+	handler := func(ctx context.Context, req any) (any, error) {
+		l.m.Lock()
+		inflight = l.inflight
+		l.m.Unlock()
+		return nil, nil
+	}
+	if _, err := interceptor(context.Background(), &executorpb.ExecuteFunctionRequest{Controller: "a", Image: "img"}, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if inflight != 1 || l.inflight != 0 {
+		t.Errorf("expected the function request to hold a slot in the handler, got %d in the handler and %d after", inflight, l.inflight)
+	}
+
+	// a full limiter does not limit the other requests
+	r := mustAcquire(t, l, "a", "img")
+	defer r()
+	if _, err := interceptor(context.Background(), &healthpb.HealthCheckRequest{}, info, handler); err != nil {
+		t.Errorf("expected the health check not to be limited, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := interceptor(ctx, &executorpb.ExecuteFunctionRequest{Controller: "a", Image: "img"}, info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+}
@@ -20,27 +20,51 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
 )
 
+const keepaliveMinTime = 10 * time.Second
+
 func (r *GrpcServer) serverOpts(ctx context.Context) ([]grpc.ServerOption, error) {
+	// the function requests are admitted by the limiter after the other
+	// interceptors ran
+	unaryInterceptors := make([]grpc.UnaryServerInterceptor, 0, len(r.unaryInterceptors)+1)
+	unaryInterceptors = append(unaryInterceptors, r.unaryInterceptors...)
+	unaryInterceptors = append(unaryInterceptors, r.limiter.unaryInterceptor())
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(r.streamInterceptors...),
+		// the long-lived clients of the reconcilers ping the idle connections
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             keepaliveMinTime,
+			PermitWithoutStream: true,
+		}),
+	}
+	if r.config.MaxMsgSize > 0 {
+		opts = append(opts,
+			grpc.MaxRecvMsgSize(r.config.MaxMsgSize),
+			grpc.MaxSendMsgSize(r.config.MaxMsgSize),
+		)
+	}
 	if r.config.Insecure {
-		return []grpc.ServerOption{
-			grpc.Creds(insecure.NewCredentials()),
-		}, nil
+		return append(opts, grpc.Creds(insecure.NewCredentials())), nil
 	}
 
-	tlsConfig, err := r.createTLSConfig(ctx)
-	if err != nil {
-		return nil, err
+	tlsConfig := r.config.TLSConfig
+	if tlsConfig == nil {
+		var err error
+		tlsConfig, err = r.createTLSConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return []grpc.ServerOption{
-		grpc.Creds(credentials.NewTLS(tlsConfig)),
-	}, nil
+	return append(opts, grpc.Creds(credentials.NewTLS(tlsConfig))), nil
 
 }
 
@@ -69,10 +93,12 @@ func (r *GrpcServer) createTLSConfig(ctx context.Context) (*tls.Config, error) {
 	tlsConfig := &tls.Config{
 		GetCertificate: certWatcher.GetCertificate,
 	}
+	// the clients have to present a certificate issued by the ca
 	if len(ca) != 0 {
 		caCertPool := x509.NewCertPool()
 		caCertPool.AppendCertsFromPEM(ca)
-		tlsConfig.RootCAs = caCertPool
+		tlsConfig.ClientCAs = caCertPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
 	}
 
 	return tlsConfig, nil
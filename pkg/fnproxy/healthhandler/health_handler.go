@@ -24,12 +24,10 @@ import (
 
 // Check implements `service Health`.
 func (s *subServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
-	s.l = log.FromContext(ctx)
-	s.l.Info("grpc server health check", "service", in.Service)
+	log.FromContext(ctx).Info("grpc server health check", "service", in.Service)
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	//if servingStatus, ok := s.statusMap[in.Service]; ok {
-	if servingStatus, ok := s.statusMap[""]; ok {
+	if servingStatus, ok := s.statusMap[in.Service]; ok {
 		return &healthpb.HealthCheckResponse{
 			Status: servingStatus,
 		}, nil
@@ -41,8 +39,7 @@ func (s *subServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest)
 func (s *subServer) Watch(in *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
 	s.l.Info("grpc server health watch", "service", in.Service)
 
-	//service := in.Service
-	service := ""
+	service := in.Service
 	// update channel is used for getting service status updates.
 	update := make(chan healthpb.HealthCheckResponse_ServingStatus, 1)
 	s.mu.Lock()
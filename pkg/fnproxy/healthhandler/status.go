@@ -0,0 +1,168 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthhandler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ImageService returns the name of the health service of an image of a
+// controller
+func ImageService(controllerName, imageName string) string {
+	return controllerName + "/" + imageName
+}
+
+// BreakerService returns the name of the health service of the circuit
+// breakers of the images of a controller, it is not serving while the breaker
+// of an image is not closed
+func BreakerService(controllerName string) string {
+	return controllerName + ":breakers"
+}
+
+func (s *subServer) SetImageStatus(controllerName string, image fnrunv1alpha1.Image, status imagestore.ServingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.l.Info("image serving status", "controller", controllerName, "image", image.Name, "status", status)
+
+	switch status {
+	case imagestore.StatusUnknown:
+		delete(s.images[controllerName], image.Name)
+		delete(s.tripped, ImageService(controllerName, image.Name))
+		s.removeServiceLocked(ImageService(controllerName, image.Name))
+	case imagestore.StatusServing:
+		s.setImageLocked(controllerName, image.Name, healthpb.HealthCheckResponse_SERVING)
+	default:
+		s.setImageLocked(controllerName, image.Name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	s.setControllerLocked(controllerName)
+}
+
+func (s *subServer) SetBreakerState(controllerName, imageName string, open bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.l.Info("image circuit breaker", "controller", controllerName, "image", imageName, "open", open)
+
+	service := ImageService(controllerName, imageName)
+	if open {
+		s.tripped[service] = true
+	} else {
+		delete(s.tripped, service)
+	}
+	if _, ok := s.images[controllerName][imageName]; !ok {
+		delete(s.tripped, service)
+		return
+	}
+	s.setServiceLocked(service, s.imageStatusLocked(controllerName, imageName))
+	s.setControllerLocked(controllerName)
+}
+
+func (s *subServer) Ready() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	notServing := []string{}
+	for controllerName, images := range s.images {
+		for imageName, st := range images {
+			if st != healthpb.HealthCheckResponse_SERVING {
+				notServing = append(notServing, ImageService(controllerName, imageName))
+			}
+		}
+	}
+	if len(notServing) > 0 {
+		sort.Strings(notServing)
+		return fmt.Errorf("images not serving: %s", strings.Join(notServing, ", "))
+	}
+	return nil
+}
+
+func (s *subServer) setImageLocked(controllerName, imageName string, status healthpb.HealthCheckResponse_ServingStatus) {
+	if _, ok := s.images[controllerName]; !ok {
+		s.images[controllerName] = map[string]healthpb.HealthCheckResponse_ServingStatus{}
+	}
+	s.images[controllerName][imageName] = status
+	s.setServiceLocked(ImageService(controllerName, imageName), s.imageStatusLocked(controllerName, imageName))
+}
+
+// imageStatusLocked returns the status of the service of an image, an image
+// with an open circuit breaker is not serving
+func (s *subServer) imageStatusLocked(controllerName, imageName string) healthpb.HealthCheckResponse_ServingStatus {
+	if s.tripped[ImageService(controllerName, imageName)] {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return s.images[controllerName][imageName]
+}
+
+// setControllerLocked sets the status of the controller, it is serving when
+// all its images are serving, and the status of the breakers of its images
+func (s *subServer) setControllerLocked(controllerName string) {
+	images, ok := s.images[controllerName]
+	if !ok || len(images) == 0 {
+		delete(s.images, controllerName)
+		s.removeServiceLocked(controllerName)
+		s.removeServiceLocked(BreakerService(controllerName))
+		return
+	}
+	aggregate := healthpb.HealthCheckResponse_SERVING
+	breakers := healthpb.HealthCheckResponse_SERVING
+	for imageName := range images {
+		if s.imageStatusLocked(controllerName, imageName) != healthpb.HealthCheckResponse_SERVING {
+			aggregate = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		if s.tripped[ImageService(controllerName, imageName)] {
+			breakers = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	s.setServiceLocked(controllerName, aggregate)
+	s.setServiceLocked(BreakerService(controllerName), breakers)
+}
+
+// setServiceLocked sets the status of the service and notifies the watchers
+// on a transition
+func (s *subServer) setServiceLocked(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	if st, ok := s.statusMap[service]; ok && st == status {
+		return
+	}
+	s.statusMap[service] = status
+	s.notifyLocked(service, status)
+}
+
+// removeServiceLocked removes the service, the watchers get the service as
+// unknown
+func (s *subServer) removeServiceLocked(service string) {
+	if _, ok := s.statusMap[service]; !ok {
+		return
+	}
+	delete(s.statusMap, service)
+	s.notifyLocked(service, healthpb.HealthCheckResponse_SERVICE_UNKNOWN)
+}
+
+func (s *subServer) notifyLocked(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, update := range s.updates[service] {
+		// drop the status the watcher did not consume yet, it only needs the
+		// latest status
+		select {
+		case <-update:
+		default:
+		}
+		update <- status
+	}
+}
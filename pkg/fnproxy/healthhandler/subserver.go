@@ -18,17 +18,32 @@ import (
 	"context"
 	"sync"
 
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
 	"github.com/go-logr/logr"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 type SubServer interface {
 	Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error)
 	Watch(in *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error
+	// SetImageStatus sets the serving status of the service <controller>/<image>
+	// and of the aggregated service <controller> of the images of a controller
+	SetImageStatus(controllerName string, image fnrunv1alpha1.Image, status imagestore.ServingStatus)
+	// SetBreakerState marks the service of the image and the breaker service
+	// of the controller as not serving while its circuit breaker is not closed
+	SetBreakerState(controllerName, imageName string, open bool)
+	// Ready returns an error until the endpoints of all images of every
+	// controller are serving, an open circuit breaker does not count
+	Ready() error
 }
 
 func New() SubServer {
 	s := &subServer{
+		l:         ctrl.Log.WithName("health"),
+		images:    map[string]map[string]healthpb.HealthCheckResponse_ServingStatus{},
+		tripped:   map[string]bool{},
 		mu:        sync.RWMutex{},
 		statusMap: map[string]healthpb.HealthCheckResponse_ServingStatus{"": healthpb.HealthCheckResponse_SERVING},
 		updates:   make(map[string]map[healthpb.Health_WatchServer]chan healthpb.HealthCheckResponse_ServingStatus),
@@ -40,5 +55,10 @@ type subServer struct {
 	l         logr.Logger
 	mu        sync.RWMutex
 	statusMap map[string]healthpb.HealthCheckResponse_ServingStatus
-	updates   map[string]map[healthpb.Health_WatchServer]chan healthpb.HealthCheckResponse_ServingStatus
+	// images holds the serving status of the images per controller
+	images map[string]map[string]healthpb.HealthCheckResponse_ServingStatus
+	// tripped holds the image services with a circuit breaker which is not
+	// closed
+	tripped map[string]bool
+	updates map[string]map[healthpb.Health_WatchServer]chan healthpb.HealthCheckResponse_ServingStatus
 }
@@ -0,0 +1,153 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memo
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultMaxEntries = 1000
+	defaultMaxBytes   = 64 * 1024 * 1024
+)
+
+// Cache is a size bounded LRU cache of function execution responses, the
+// responses are copied in and out so callers cannot modify the cached ones
+type Cache interface {
+	// Get returns a copy of the cached response of the key
+	Get(key string) (*executorpb.ExecuteFunctionResponse, bool)
+	// Add adds the response, the least recently used entries are evicted
+	// when the cache exceeds the max entries or bytes
+	Add(key string, resp *executorpb.ExecuteFunctionResponse)
+	// Len returns the amount of cached entries
+	Len() int
+}
+
+type Config struct {
+	// MaxEntries is the max amount of cached responses
+	MaxEntries int
+	// MaxBytes is the max size of the cached responses
+	MaxBytes int
+	// TTL expires the cached responses after the duration, no expiry if 0
+	TTL time.Duration
+}
+
+// Key returns the content address of an execution, the digest identifies the
+// function binary and the resourceContext all of its input
+func Key(digest, controller string, resourceContext []byte) string {
+	h := sha256.New()
+	for _, b := range [][]byte{[]byte(digest), []byte(controller), resourceContext} {
+		h.Write(b)
+		// separator so the concatenation is unambiguous
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func New(c *Config) Cache {
+	r := &cache{
+		maxEntries: defaultMaxEntries,
+		maxBytes:   defaultMaxBytes,
+		ll:         list.New(),
+		d:          map[string]*list.Element{},
+	}
+	if c != nil {
+		if c.MaxEntries > 0 {
+			r.maxEntries = c.MaxEntries
+		}
+		if c.MaxBytes > 0 {
+			r.maxBytes = c.MaxBytes
+		}
+		r.ttl = c.TTL
+	}
+	return r
+}
+
+type cache struct {
+	maxEntries int
+	maxBytes   int
+	ttl        time.Duration
+
+	m     sync.Mutex
+	ll    *list.List
+	d     map[string]*list.Element
+	bytes int
+}
+
+type entry struct {
+	key     string
+	resp    *executorpb.ExecuteFunctionResponse
+	size    int
+	expires time.Time
+}
+
+func (r *cache) Get(key string) (*executorpb.ExecuteFunctionResponse, bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	e, ok := r.d[key]
+	if !ok {
+		return nil, false
+	}
+	ent := e.Value.(*entry)
+	if !ent.expires.IsZero() && time.Now().After(ent.expires) {
+		r.remove(e)
+		return nil, false
+	}
+	r.ll.MoveToFront(e)
+	return proto.Clone(ent.resp).(*executorpb.ExecuteFunctionResponse), true
+}
+
+func (r *cache) Add(key string, resp *executorpb.ExecuteFunctionResponse) {
+	size := len(resp.GetResourceContext()) + len(resp.GetLog())
+	if size > r.maxBytes {
+		return
+	}
+	ent := &entry{key: key, resp: proto.Clone(resp).(*executorpb.ExecuteFunctionResponse), size: size}
+	if r.ttl > 0 {
+		ent.expires = time.Now().Add(r.ttl)
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	if e, ok := r.d[key]; ok {
+		r.remove(e)
+	}
+	r.d[key] = r.ll.PushFront(ent)
+	r.bytes += size
+	for r.ll.Len() > r.maxEntries || r.bytes > r.maxBytes {
+		r.remove(r.ll.Back())
+	}
+}
+
+func (r *cache) remove(e *list.Element) {
+	ent := r.ll.Remove(e).(*entry)
+	delete(r.d, ent.key)
+	r.bytes -= ent.size
+}
+
+func (r *cache) Len() int {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.ll.Len()
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+)
+
+func newResp(s string) *executorpb.ExecuteFunctionResponse {
+	return &executorpb.ExecuteFunctionResponse{ResourceContext: []byte(s)}
+}
+
+func expectKeys(t *testing.T, c Cache, present, absent []string) {
+	t.Helper()
+	for _, k := range present {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("expected %s to be cached", k)
+		}
+	}
+	for _, k := range absent {
+		if _, ok := c.Get(k); ok {
+			t.Errorf("expected %s to be evicted", k)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	k := Key("sha256:a", "ctrl", []byte("rc"))
+	if k != Key("sha256:a", "ctrl", []byte("rc")) {
+		t.Error("expected the key to be stable")
+	}
+	cases := map[string]string{
+		"Digest":     Key("sha256:b", "ctrl", []byte("rc")),
+		"Controller": Key("sha256:a", "other", []byte("rc")),
+		"Input":      Key("sha256:a", "ctrl", []byte("other")),
+		"Separator":  Key("sha256:ac", "trl", []byte("rc")),
+	}
+	for name, other := range cases {
+		if other == k {
+			t.Errorf("%s: expected a different key", name)
+		}
+	}
+}
+
+func TestGetCopy(t *testing.T) {
+	c := New(nil)
+	resp := newResp("a")
+	c.Add("a", resp)
+	// the caller modifying its response does not change the cached one
+	resp.ResourceContext[0] = 'x'
+
+	got, ok := c.Get("a")
+	if !ok {
+		t.Fatal("expected a cached response")
+	}
+	if string(got.GetResourceContext()) != "a" {
+		t.Errorf("expected a, got %s", got.GetResourceContext())
+	}
+	got.ResourceContext[0] = 'y'
+	got, _ = c.Get("a")
+	if string(got.GetResourceContext()) != "a" {
+		t.Errorf("expected a, got %s", got.GetResourceContext())
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	c := New(&Config{MaxEntries: 2})
+	c.Add("a", newResp("a"))
+	c.Add("b", newResp("b"))
+	// a becomes the most recently used entry
+	expectKeys(t, c, []string{"a"}, nil)
+	c.Add("c", newResp("c"))
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+	expectKeys(t, c, []string{"a", "c"}, []string{"b"})
+}
+
+func TestMaxBytes(t *testing.T) {
+	c := New(&Config{MaxBytes: 10})
+	c.Add("a", newResp("aaaa"))
+	c.Add("b", newResp("bbbb"))
+	c.Add("c", newResp("cccc"))
+	expectKeys(t, c, []string{"b", "c"}, []string{"a"})
+
+	// a response larger than the cache is not cached and evicts nothing
+	c.Add("d", newResp("ddddddddddd"))
+	expectKeys(t, c, []string{"b", "c"}, []string{"d"})
+}
+
+func TestReplace(t *testing.T) {
+	c := New(&Config{MaxBytes: 10})
+	c.Add("a", newResp("aaaa"))
+	c.Add("a", newResp("aaaaaaaa"))
+	if c.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", c.Len())
+	}
+	got, _ := c.Get("a")
+	if string(got.GetResourceContext()) != "aaaaaaaa" {
+		t.Errorf("expected the replaced response, got %s", got.GetResourceContext())
+	}
+	// the replaced entry no longer counts against the max bytes
+	c.Add("b", newResp("b"))
+	expectKeys(t, c, []string{"a", "b"}, nil)
+}
+
+func TestTTL(t *testing.T) {
+	c := New(&Config{TTL: time.Millisecond})
+	c.Add("a", newResp("a"))
+	time.Sleep(5 * time.Millisecond)
+	expectKeys(t, c, nil, []string{"a"})
+	if c.Len() != 0 {
+		t.Errorf("expected the expired entry to be removed, got %d entries", c.Len())
+	}
+
+	c = New(nil)
+	c.Add("a", newResp("a"))
+	time.Sleep(5 * time.Millisecond)
+	expectKeys(t, c, []string{"a"}, nil)
+}
+
+func TestConcurrent(t *testing.T) {
+	c := New(&Config{MaxEntries: 10})
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < 100; j++ {
+				k := fmt.Sprintf("%d", (i+j)%20)
+				c.Add(k, newResp(k))
+				c.Get(k)
+			}
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+	if c.Len() > 10 {
+		t.Errorf("expected at most 10 entries, got %d", c.Len())
+	}
+}
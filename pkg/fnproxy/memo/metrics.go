@@ -14,10 +14,26 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package exechandler
+package memo
 
-import "errors"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	LookupHit  = "hit"
+	LookupMiss = "miss"
+)
 
 var (
-	ErrClientNotready = errors.New("client not ready")
+	// Lookups counts the memoization cache lookups per controller and image
+	Lookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fnrun_fn_cache_lookups_total",
+		Help: "Total number of function execution cache lookups by result",
+	}, []string{"controller", "image", "result"})
 )
+
+func init() {
+	metrics.Registry.MustRegister(Lookups)
+}
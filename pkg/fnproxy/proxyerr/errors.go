@@ -0,0 +1,121 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package proxyerr holds the statuses the proxy returns for the requests it
+// does not forward to an image and the client side handling of their retry
+// info.
+package proxyerr
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RetryDelay is the delay after which a client retries a request which was
+// not forwarded to the image
+const RetryDelay = 2 * time.Second
+
+// UnknownController returns the status of a request for a controller the
+// proxy does not serve
+func UnknownController(controller string) error {
+	return status.Errorf(codes.NotFound, "unknown controller %s", controller)
+}
+
+// UnknownImage returns the status of a request for an image the controller
+// does not have
+func UnknownImage(controller, image string) error {
+	return status.Errorf(codes.NotFound, "unknown image %s of controller %s", image, controller)
+}
+
+// WaitFailed returns the status of a request which did not get a client of
+// the image: the NotFound status of an image which was removed meanwhile, the
+// status of a canceled request and NotReady otherwise
+func WaitFailed(err error, controller, image string) error {
+	switch {
+	case status.Code(err) == codes.NotFound:
+		return err
+	case errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	}
+	return NotReady(controller, image)
+}
+
+// NotReady returns a retryable status for a client that did not get ready
+// within the request deadline
+func NotReady(controller, image string) error {
+	return withRetryInfo(
+		status.Newf(codes.Unavailable, "client of image %s of controller %s not ready", image, controller),
+		fnrunv1alpha1.ReasonClientNotReady, controller, image, RetryDelay)
+}
+
+// CircuitOpen returns the status of a request rejected by the circuit
+// breaker of the image. It has no retry info, the request fails fast while
+// the circuit is open instead of being retried by the client.
+func CircuitOpen(err *breaker.OpenError) error {
+	return withDetails(status.New(codes.Unavailable, err.Error()),
+		fnrunv1alpha1.ReasonCircuitOpen, err.Controller, err.Image, nil)
+}
+
+func withRetryInfo(s *status.Status, reason, controller, image string, delay time.Duration) error {
+	return withDetails(s, reason, controller, image, &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+}
+
+// withDetails adds the error info of the proxy and the retry info when not
+// nil to the status
+func withDetails(s *status.Status, reason, controller, image string, retryInfo *errdetails.RetryInfo) error {
+	info := &errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: fnrunv1alpha1.Domain,
+		Metadata: map[string]string{
+			"controller": controller,
+			"image":      image,
+		},
+	}
+	var d *status.Status
+	var err error
+	if retryInfo != nil {
+		d, err = s.WithDetails(info, retryInfo)
+	} else {
+		d, err = s.WithDetails(info)
+	}
+	if err != nil {
+		return s.Err()
+	}
+	return d.Err()
+}
+
+// RetryAfter returns the retry delay of the status of the error, false if the
+// error is not retryable
+func RetryAfter(err error) (time.Duration, bool) {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.Unavailable {
+		return 0, false
+	}
+	for _, d := range s.Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok {
+			return ri.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
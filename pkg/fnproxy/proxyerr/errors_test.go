@@ -0,0 +1,50 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxyerr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
+	"google.golang.org/grpc"
+)
+
+func TestRetryAfter(t *testing.T) {
+	if _, ok := RetryAfter(CircuitOpen(&breaker.OpenError{Controller: "c", Image: "i", RetryAfter: 30 * time.Second})); ok {
+		t.Error("expected an open circuit not to be retried")
+	}
+	if d, ok := RetryAfter(NotReady("c", "i")); !ok || d != RetryDelay {
+		t.Errorf("expected a not ready client to be retried after %s, got %s %t", RetryDelay, d, ok)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	calls := 0
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return CircuitOpen(&breaker.OpenError{Controller: "c", Image: "i", RetryAfter: 30 * time.Second})
+	}
+	start := time.Now()
+	if err := UnaryClientInterceptor()(context.Background(), "/m", nil, nil, nil, invoker); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("expected an open circuit to fail fast, got %d calls in %s", calls, time.Since(start))
+	}
+}
@@ -20,36 +20,78 @@ import (
 	"context"
 
 	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnproto/pkg/service/svcclient"
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/proxyerr"
+	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
-func (r *subServer) ApplyResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (*servicepb.FunctionServiceResponse, error) {
+func (r *subServer) ApplyResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (resp *servicepb.FunctionServiceResponse, err error) {
 	r.l.Info("service apply", "image", req.Image, "controllerName", req.GetController())
 
-	imageStore := r.ctrlStore.GetImageStore(req.GetController())
-	if imageStore == nil {
-		return &servicepb.FunctionServiceResponse{}, ErrClientNotready
+	resolver, image, err := r.getImage(req)
+	if err != nil {
+		return &servicepb.FunctionServiceResponse{}, err
 	}
-
-	svcclient := imageStore.GetSvcClient(fnrunv1alpha1.Image{Name: req.GetImage(), Kind: fnrunv1alpha1.ImageKindService})
-	if svcclient == nil {
-		return &servicepb.FunctionServiceResponse{}, ErrClientNotready
+	done, err := r.allow(req.GetController(), req.GetImage())
+	if err != nil {
+		r.l.Info("circuit open", "image", req.GetImage(), "controllerName", req.GetController())
+		return &servicepb.FunctionServiceResponse{}, err
+	}
+	defer breaker.Report(done, &err)
+	svcclient, err := r.waitClient(ctx, resolver, image, req)
+	if err != nil {
+		return &servicepb.FunctionServiceResponse{}, err
 	}
 	return svcclient.Get().ApplyResource(ctx, req)
 }
 
-func (r *subServer) DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (*emptypb.Empty, error) {
+func (r *subServer) DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (resp *emptypb.Empty, err error) {
 	r.l.Info("service delete", "image", req.Image, "controllerName", req.GetController())
 
-	imageStore := r.ctrlStore.GetImageStore(req.GetController())
-	if imageStore == nil {
-		return &emptypb.Empty{}, ErrClientNotready
+	resolver, image, err := r.getImage(req)
+	if err != nil {
+		return &emptypb.Empty{}, err
 	}
-
-	svcclient := imageStore.GetSvcClient(fnrunv1alpha1.Image{Name: req.GetImage(), Kind: fnrunv1alpha1.ImageKindService})
-	if svcclient == nil {
-		return &emptypb.Empty{}, ErrClientNotready
+	done, err := r.allow(req.GetController(), req.GetImage())
+	if err != nil {
+		r.l.Info("circuit open", "image", req.GetImage(), "controllerName", req.GetController())
+		return &emptypb.Empty{}, err
+	}
+	defer breaker.Report(done, &err)
+	svcclient, err := r.waitClient(ctx, resolver, image, req)
+	if err != nil {
+		return &emptypb.Empty{}, err
 	}
 	return svcclient.Get().DeleteResource(ctx, req)
 }
+
+// getImage returns the resolver of the images of the controller and the
+// service image of the request, the images are resolved with the backends
+// running them. Unknown images are rejected before they get a breaker.
+func (r *subServer) getImage(req *servicepb.FunctionServiceRequest) (ctrlstore.ImageResolver, fnrunv1alpha1.Image, error) {
+	image := fnrunv1alpha1.Image{Name: req.GetImage(), Kind: fnrunv1alpha1.ImageKindService}
+	resolver := r.ctrlStore.GetImageResolver(req.GetController())
+	if resolver == nil {
+		return nil, image, proxyerr.UnknownController(req.GetController())
+	}
+	if resolver.Status(image) == imagestore.StatusUnknown {
+		return nil, image, proxyerr.UnknownImage(req.GetController(), req.GetImage())
+	}
+	return resolver, image, nil
+}
+
+// waitClient waits for a client of the image within the request deadline,
+// the breaker is checked before so an open breaker fails fast instead of
+// waiting for an image whose pods are crashing or ejected
+func (r *subServer) waitClient(ctx context.Context, resolver ctrlstore.ImageResolver, image fnrunv1alpha1.Image, req *servicepb.FunctionServiceRequest) (svcclient.Client, error) {
+	svcclient, err := resolver.ResolveSvc(ctx, image)
+	if err != nil {
+		r.l.Info("client not ready", "image", req.GetImage(), "controllerName", req.GetController(), "err", err)
+		return nil, proxyerr.WaitFailed(err, req.GetController(), req.GetImage())
+	}
+	return svcclient, nil
+}
@@ -18,8 +18,11 @@ package servicehandler
 
 import (
 	"context"
+	"errors"
 
 	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/breaker"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/proxyerr"
 	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
 	"github.com/go-logr/logr"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
@@ -31,15 +34,46 @@ type SubServer interface {
 	DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (*emptypb.Empty, error)
 }
 
-func New(c ctrlstore.Store) SubServer {
+type Option func(*subServer)
+
+// WithBreakers fails the requests to an image fast while its circuit breaker
+// is open
+func WithBreakers(b breaker.Breakers) Option {
+	return func(r *subServer) {
+		r.breakers = b
+	}
+}
+
+func New(c ctrlstore.Store, opts ...Option) SubServer {
 	r := &subServer{
 		l:         ctrl.Log.WithName("subserverService"),
 		ctrlStore: c,
 	}
+	for _, o := range opts {
+		o(r)
+	}
 	return r
 }
 
 type subServer struct {
 	l         logr.Logger
 	ctrlStore ctrlstore.Store
+	breakers  breaker.Breakers
+}
+
+// allow returns the status of a rejected request if the circuit breaker of the
+// image is open, otherwise the result of the request is reported with done
+func (r *subServer) allow(controller, image string) (func(err error), error) {
+	if r.breakers == nil {
+		return func(error) {}, nil
+	}
+	done, err := r.breakers.Allow(controller, image)
+	if err != nil {
+		var oerr *breaker.OpenError
+		if errors.As(err, &oerr) {
+			return nil, proxyerr.CircuitOpen(oerr)
+		}
+		return nil, err
+	}
+	return done, nil
 }
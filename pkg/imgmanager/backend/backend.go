@@ -0,0 +1,180 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fnrunner/fnproto/pkg/executor/execclient"
+	"github.com/fnrunner/fnproto/pkg/service/svcclient"
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/certs"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Kind string
+
+const (
+	// KindPod runs the images in pods of a deployment per image
+	KindPod Kind = "pod"
+	// KindExec runs the images with local executables
+	KindExec Kind = "exec"
+	// KindContainer runs the images with local containers
+	KindContainer Kind = "container"
+)
+
+// Backend runs the images of a controller. The ready endpoints of an image
+// are reported in the image store of the controller, which balances the
+// requests of the proxy over them.
+type Backend interface {
+	// Ensure starts the image if it is not started yet, it returns before
+	// the image is serving
+	Ensure(ctx context.Context, image fnrunv1alpha1.Image) error
+	// ResolveFn and ResolveSvc wait for a client of the image within the ctx
+	// deadline
+	ResolveFn(ctx context.Context, image fnrunv1alpha1.Image) (execclient.Client, error)
+	ResolveSvc(ctx context.Context, image fnrunv1alpha1.Image) (svcclient.Client, error)
+	// Release stops the image
+	Release(ctx context.Context, image fnrunv1alpha1.Image) error
+	// Status returns the serving status of the image
+	Status(image fnrunv1alpha1.Image) imagestore.ServingStatus
+}
+
+// Selection selects the backend of the images, e.g. the pod backend in the
+// cluster and the exec backend on a laptop for the same controller config
+type Selection struct {
+	// Default is the backend of the images, default pod
+	Default Kind
+	// Images selects the backend per image name
+	Images map[string]Kind
+	// ExecDir is the dir of the executables of the exec backend, they are
+	// named after the repository of the image. The executables are looked
+	// up in the PATH when empty.
+	ExecDir string
+}
+
+// Kind returns the backend of the image
+func (r *Selection) Kind(image string) Kind {
+	if r == nil {
+		return KindPod
+	}
+	if kind, ok := r.Images[image]; ok {
+		return kind
+	}
+	if r.Default != "" {
+		return r.Default
+	}
+	return KindPod
+}
+
+// GetExecDir returns the dir of the executables of the exec backend
+func (r *Selection) GetExecDir() string {
+	if r == nil {
+		return ""
+	}
+	return r.ExecDir
+}
+
+func (r *Selection) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.Default != "" && !r.Default.valid() {
+		return fmt.Errorf("unknown backend: %s", r.Default)
+	}
+	for image, kind := range r.Images {
+		if !kind.valid() {
+			return fmt.Errorf("unknown backend %s of image %s", kind, image)
+		}
+	}
+	return nil
+}
+
+func (r Kind) valid() bool {
+	switch r {
+	case KindPod, KindExec, KindContainer:
+		return true
+	}
+	return false
+}
+
+// ParseImages parses the backends per image of a comma separated list of
+// <image>=<backend>
+func ParseImages(s string) (map[string]Kind, error) {
+	images := map[string]Kind{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		image, kind, ok := strings.Cut(entry, "=")
+		if !ok || image == "" {
+			return nil, fmt.Errorf("invalid image backend %q, expected <image>=<backend>", entry)
+		}
+		images[image] = Kind(kind)
+	}
+	return images, nil
+}
+
+type Config struct {
+	ControllerName string
+	ImageStore     imagestore.Store
+	// Client, Namespace, ConfigMap and Authority are used by the pod backend
+	Client    *kubernetes.Clientset
+	Namespace string
+	ConfigMap *corev1.ConfigMap
+	// Authority issues the certificates of the function pods when mTLS is
+	// enabled
+	Authority certs.Authority
+	// ExecDir is the dir of the executables of the exec backend
+	ExecDir string
+	// MaxMsgSize is the max size of a message the images receive and send,
+	// the grpc defaults apply when 0
+	MaxMsgSize int
+}
+
+func New(kind Kind, cfg *Config) (Backend, error) {
+	switch kind {
+	case KindPod:
+		return newPod(cfg)
+	case KindExec, KindContainer:
+		return newLocal(kind, cfg), nil
+	}
+	return nil, fmt.Errorf("unknown backend: %s", kind)
+}
+
+// resolver resolves the clients and the status of the images with the
+// image store the backends report the endpoints in
+type resolver struct {
+	imageStore imagestore.Store
+}
+
+func (r *resolver) ResolveFn(ctx context.Context, image fnrunv1alpha1.Image) (execclient.Client, error) {
+	return r.imageStore.WaitFnClient(ctx, image)
+}
+
+func (r *resolver) ResolveSvc(ctx context.Context, image fnrunv1alpha1.Image) (svcclient.Client, error) {
+	return r.imageStore.WaitSvcClient(ctx, image)
+}
+
+func (r *resolver) Status(image fnrunv1alpha1.Image) imagestore.ServingStatus {
+	return r.imageStore.GetStatus(image)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/exec/fnruntime"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"github.com/fnrunner/fnruntime/pkg/imgmanager/localserver"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// local runs the images in the manager process with local executables or
+// local containers, an image is served in memory as a single endpoint
+type local struct {
+	resolver
+	kind       Kind
+	execDir    string
+	maxMsgSize int
+
+	m      sync.Mutex
+	cancel map[fnrunv1alpha1.Image]context.CancelFunc
+	l      logr.Logger
+}
+
+func newLocal(kind Kind, cfg *Config) Backend {
+	return &local{
+		resolver:   resolver{imageStore: cfg.ImageStore},
+		kind:       kind,
+		execDir:    cfg.ExecDir,
+		maxMsgSize: cfg.MaxMsgSize,
+		cancel:     map[fnrunv1alpha1.Image]context.CancelFunc{},
+		l:          ctrl.Log.WithName(fmt.Sprintf("%s backend", kind)).WithValues("controller", cfg.ControllerName),
+	}
+}
+
+func (r *local) Ensure(ctx context.Context, image fnrunv1alpha1.Image) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if _, ok := r.cancel[image]; ok {
+		return nil
+	}
+	b, err := r.newRunner(image)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	l, dialer := clients.NewInMemoryListener()
+	s := localserver.New(localserver.WithBackend(image.Name, b), localserver.WithMaxMsgSize(r.maxMsgSize))
+	go func() {
+		if err := s.Serve(ctx, l); err != nil {
+			r.l.Error(err, "cannot serve image", "image", image.Name)
+		}
+	}()
+	if err := r.imageStore.SetEndpoints(image, []imagestore.Endpoint{{
+		PodName: string(r.kind),
+		Address: fmt.Sprintf("%s/%s", r.kind, image.Name),
+		Dialer:  dialer,
+	}}); err != nil {
+		cancel()
+		return err
+	}
+	r.cancel[image] = cancel
+	return nil
+}
+
+func (r *local) Release(ctx context.Context, image fnrunv1alpha1.Image) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	cancel, ok := r.cancel[image]
+	if !ok {
+		return nil
+	}
+	cancel()
+	delete(r.cancel, image)
+	// the image may be deleted from the store already
+	if !r.imageStore.Exists(image) {
+		return nil
+	}
+	return r.imageStore.SetEndpoints(image, nil)
+}
+
+// newRunner returns the runner of the functions of the image
+func (r *local) newRunner(image fnrunv1alpha1.Image) (localserver.Backend, error) {
+	if r.kind == KindContainer {
+		return &localserver.Container{
+			Image: image.Name,
+			// services apply the resources over the network
+			Perm: fnruntime.ContainerFnPermission{AllowNetwork: image.Kind == fnrunv1alpha1.ImageKindService},
+		}, nil
+	}
+	path, err := r.execPath(image.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &localserver.Executable{Path: path}, nil
+}
+
+// execPath returns the path of the executable named after the repository of
+// the image
+func (r *local) execPath(image string) (string, error) {
+	name, err := repositoryName(image)
+	if err != nil {
+		return "", err
+	}
+	if r.execDir == "" {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("cannot find executable of image %s: %w", image, err)
+		}
+		return path, nil
+	}
+	path := filepath.Join(r.execDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("cannot find executable of image %s: %w", image, err)
+	}
+	return path, nil
+}
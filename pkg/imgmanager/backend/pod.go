@@ -0,0 +1,132 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+	"sync"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/certs"
+	"github.com/fnrunner/fnruntime/pkg/imgmanager/imgcontroller"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// pod runs the images in pods of a deployment per image, the img controller
+// of an image reports the ready pods as endpoints
+type pod struct {
+	resolver
+	controllerName string
+	client         *kubernetes.Clientset
+	namespace      string
+	cm             *corev1.ConfigMap
+	replicas       int32
+	authority      certs.Authority
+	maxMsgSize     int
+
+	m      sync.Mutex
+	cancel map[fnrunv1alpha1.Image]context.CancelFunc
+	l      logr.Logger
+}
+
+func newPod(cfg *Config) (Backend, error) {
+	replicas, err := getReplicas(cfg.ConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	return &pod{
+		resolver:       resolver{imageStore: cfg.ImageStore},
+		controllerName: cfg.ControllerName,
+		client:         cfg.Client,
+		namespace:      cfg.Namespace,
+		cm:             cfg.ConfigMap,
+		replicas:       replicas,
+		authority:      cfg.Authority,
+		maxMsgSize:     cfg.MaxMsgSize,
+		cancel:         map[fnrunv1alpha1.Image]context.CancelFunc{},
+		l:              ctrl.Log.WithName("pod backend").WithValues("controller", cfg.ControllerName),
+	}, nil
+}
+
+func (r *pod) Ensure(ctx context.Context, image fnrunv1alpha1.Image) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if _, ok := r.cancel[image]; ok {
+		return nil
+	}
+	de, err := getImageDigestAndEntrypoint(ctx, image.Name)
+	if err != nil {
+		return err
+	}
+	r.imageStore.SetDigest(image, de.Digest)
+
+	name, err := deploymentName(r.controllerName, image.Name)
+	if err != nil {
+		return err
+	}
+	imgc := imgcontroller.New(&imgcontroller.Config{
+		Namespace:      r.namespace,
+		ControllerName: r.controllerName,
+		Client:         r.client,
+		Image:          image,
+		Name:           name,
+		Replicas:       r.replicas,
+		De:             de,
+		ConfigMap:      r.cm,
+		SetEndpointsFn: r.imageStore.SetEndpoints,
+		Authority:      r.authority,
+		MaxMsgSize:     r.maxMsgSize,
+	})
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel[image] = cancel
+	go func() {
+		if err := imgc.Start(ctx); err != nil {
+			r.l.Error(err, "cannot start/crash img controller", "image", image.Name)
+		}
+	}()
+	return nil
+}
+
+// Release stops the img controller of the image, the deployment is deleted
+// with the configmap which owns it or by Prune when the image is removed
+func (r *pod) Release(ctx context.Context, image fnrunv1alpha1.Image) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if cancel, ok := r.cancel[image]; ok {
+		cancel()
+		delete(r.cancel, image)
+	}
+	return nil
+}
+
+// Prune deletes the deployments of the images of the controller which no
+// longer run in the pod backend, i.e. the images removed from the controller
+// config or moved to another backend
+func Prune(ctx context.Context, cfg *Config, images []fnrunv1alpha1.Image) error {
+	names := map[string]struct{}{}
+	for _, image := range images {
+		name, err := deploymentName(cfg.ControllerName, image.Name)
+		if err != nil {
+			return err
+		}
+		names[name] = struct{}{}
+	}
+	return imgcontroller.Prune(ctx, cfg.Client, cfg.Namespace, cfg.ConfigMap, names)
+}
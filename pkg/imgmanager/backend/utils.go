@@ -14,11 +14,14 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package imgmanager
+package backend
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,9 +29,19 @@ import (
 	"github.com/google/go-containerregistry/pkg/gcrane"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+const (
+	// defaultReplicas keeps a pod of the image serving while the pod
+	// disruption budget lets a drain evict the other one
+	defaultReplicas = 2
+	// maxNameLength is the max length of a service name
+	maxNameLength = 63
+	hashLength    = 8
+)
+
 func getImageDigestAndEntrypoint(ctx context.Context, image string) (*fnrunv1alpha1.DigestAndEntrypoint, error) {
 	l := log.FromContext(ctx)
 	start := time.Now()
@@ -67,14 +80,44 @@ func getImageDigestAndEntrypoint(ctx context.Context, image string) (*fnrunv1alp
 	}, nil
 }
 
-func podName(controllerName, image, hash string) (string, error) {
+// deploymentName is stable across digests of the image, so a new digest
+// rolls out the deployment. The hash of the image reference keeps the names
+// of images with the same repository name in other registries apart.
+func deploymentName(controllerName, image string) (string, error) {
+	repoName, err := repositoryName(image)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256([]byte(image))
+	hash := hex.EncodeToString(h[:])[:hashLength]
+	name := strings.ReplaceAll(fmt.Sprintf("%s-%s", controllerName, repoName), "_", "-")
+	if len(name) > maxNameLength-hashLength-1 {
+		name = strings.TrimRight(name[:maxNameLength-hashLength-1], "-.")
+	}
+	return fmt.Sprintf("%s-%s", name, hash), nil
+}
+
+// repositoryName returns the last element of the repository of the image,
+// e.g. set-labels of ghcr.io/fnrunner/set-labels:v0.1.0
+func repositoryName(image string) (string, error) {
 	ref, err := name.ParseReference(image)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse image reference %v: %w", image, err)
 	}
+	parts := strings.Split(ref.Context().Name(), "/")
+	return parts[len(parts)-1], nil
+}
 
-	repoName := ref.Context().Name()
-	parts := strings.Split(repoName, "/")
-	name := strings.ReplaceAll(parts[len(parts)-1], "_", "-")
-	return fmt.Sprintf("%s-%s-%v", controllerName, name, hash[:8]), nil
+// getReplicas returns the replicas of the replicas annotation of the
+// configmap, default 2
+func getReplicas(cm *corev1.ConfigMap) (int32, error) {
+	v, ok := cm.GetAnnotations()[fnrunv1alpha1.ReplicasAnnotationKey]
+	if !ok {
+		return defaultReplicas, nil
+	}
+	replicas, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || replicas < 1 {
+		return 0, fmt.Errorf("invalid %s annotation: %s", fnrunv1alpha1.ReplicasAnnotationKey, v)
+	}
+	return int32(replicas), nil
 }
@@ -0,0 +1,91 @@
+package imgcontroller
+
+import (
+	"context"
+	"fmt"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/certs"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreapplyv1 "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+// mtls returns true if the pods of the image serve mTLS. Service images serve
+// grpc themselves without the fnwrapper, they get no certificate and are
+// called in plaintext even when mTLS is enabled.
+func (r *controller) mtls() bool {
+	return r.authority != nil && r.image.Kind == fnrunv1alpha1.ImageKindFunction
+}
+
+const (
+	// probeCommonName is the common name of the client certificate of the
+	// readiness probe of the pods
+	probeCommonName = "fnrun-probe"
+	probeCertKey    = "probe.crt"
+	probeKeyKey     = "probe.key"
+)
+
+func certSecretName(name string) string {
+	return name + "-tls"
+}
+
+// serverName is the name the certificate of the pods is issued for
+func (r *controller) serverName(name string) string {
+	return fmt.Sprintf("%s.%s.svc", name, r.namespace)
+}
+
+// applyCertSecret issues the certificate of the pods of the image when the
+// secret does not exist, the certificate is due for renewal or the CA bundle
+// changed. The kubelet updates the mounted secret in the running pods.
+// The key of the server certificate is readable by the function, so it is
+// issued for server authentication only. The readiness probe gets a client
+// certificate whose common name the proxy does not accept.
+func (r *controller) applyCertSecret(ctx context.Context, name string) error {
+	if !r.mtls() {
+		return nil
+	}
+	secret, err := r.client.CoreV1().Secrets(r.namespace).Get(ctx, certSecretName(name), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	bundle := r.authority.CABundle()
+	if err == nil &&
+		!r.authority.NeedsRenewal(secret.Data[corev1.TLSCertKey]) &&
+		!r.authority.NeedsRenewal(secret.Data[probeCertKey]) &&
+		certs.EqualBundle(secret.Data[certs.CABundleKey], bundle) {
+		return nil
+	}
+	kp, err := r.authority.Issue(r.serverName(name), []string{
+		name,
+		fmt.Sprintf("%s.%s", name, r.namespace),
+		r.serverName(name),
+		r.serverName(name) + ".cluster.local",
+	}, nil, certs.UsageServer)
+	if err != nil {
+		return err
+	}
+	probeKp, err := r.authority.Issue(probeCommonName, nil, nil, certs.UsageClient)
+	if err != nil {
+		return err
+	}
+	s := coreapplyv1.Secret(certSecretName(name), r.namespace)
+	s.WithLabels(map[string]string{
+		fnrunv1alpha1.FunctionLabelKey: name,
+	})
+	s.WithOwnerReferences(r.buildOwnerReference())
+	s.WithType(corev1.SecretTypeTLS)
+	s.WithData(map[string][]byte{
+		corev1.TLSCertKey:       kp.Cert,
+		corev1.TLSPrivateKeyKey: kp.Key,
+		certs.CABundleKey:       bundle,
+		probeCertKey:            probeKp.Cert,
+		probeKeyKey:             probeKp.Key,
+	})
+	if _, err := r.client.CoreV1().Secrets(r.namespace).Apply(ctx, s, metav1.ApplyOptions{FieldManager: fieldManager}); err != nil {
+		return err
+	}
+	r.l.Info("certificate issued", "secret", certSecretName(name))
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/certs"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,10 +20,12 @@ import (
 const (
 	defaultWaitTime = time.Second * 5
 	finalizer       = "fnrun.io/finalizer"
+	// certCheckInterval is the interval the certificate of the pods is
+	// checked for renewal
+	certCheckInterval = 10 * time.Minute
 )
 
-type CreateClientFn func(image fnrunv1alpha1.Image, podName, podIP string) error
-type DeleteCLientFn func(image fnrunv1alpha1.Image)
+type SetEndpointsFn func(image fnrunv1alpha1.Image, endpoints []imagestore.Endpoint) error
 
 type Controller interface {
 	Start(ctx context.Context) error
@@ -33,11 +37,17 @@ type Config struct {
 	Client         *kubernetes.Clientset
 	ControllerName string
 	Image          fnrunv1alpha1.Image
-	PodName        string
+	// Name of the deployment, service and pod disruption budget of the image
+	Name           string
+	Replicas       int32
 	De             *fnrunv1alpha1.DigestAndEntrypoint
 	ConfigMap      *corev1.ConfigMap
-	CreateClientFn CreateClientFn
-	DeleteClientFn DeleteCLientFn
+	SetEndpointsFn SetEndpointsFn
+	// Authority issues the certificates of the pods when mTLS is enabled
+	Authority certs.Authority
+	// MaxMsgSize is the max size of a message the pods receive and send, the
+	// grpc defaults of the wrapper apply when 0
+	MaxMsgSize int
 }
 
 func New(cfg *Config) Controller {
@@ -57,24 +67,28 @@ func New(cfg *Config) Controller {
 		fnWrapperImage: fnWrapperImage,
 		cm:             cfg.ConfigMap,
 		l:              l,
-		createClientFn: cfg.CreateClientFn,
-		deleteClientFn: cfg.DeleteClientFn,
+		setEndpointsFn: cfg.SetEndpointsFn,
 		de:             cfg.De,
-		podName:        cfg.PodName,
+		name:           cfg.Name,
+		replicas:       cfg.Replicas,
+		authority:      cfg.Authority,
+		maxMsgSize:     cfg.MaxMsgSize,
 	}
 }
 
 type controller struct {
 	client         *kubernetes.Clientset
-	podName        string
+	name           string
+	replicas       int32
 	namespace      string
 	image          fnrunv1alpha1.Image
 	fnWrapperImage string
 	cm             *corev1.ConfigMap
 	l              logr.Logger
-	createClientFn CreateClientFn
-	deleteClientFn DeleteCLientFn
+	setEndpointsFn SetEndpointsFn
 	de             *fnrunv1alpha1.DigestAndEntrypoint
+	authority      certs.Authority
+	maxMsgSize     int
 }
 
 /*
@@ -94,22 +108,38 @@ func (r *controller) Stop(ctx context.Context) error {
 */
 
 func (r *controller) Start(ctx context.Context) error {
+	if r.authority != nil && !r.mtls() {
+		r.l.Info("mTLS is not supported by service images, the pods of the image are called in plaintext")
+	}
 	for {
 		select {
 		default:
 		INIT:
-			if _, err := r.applyPod(ctx, r.podName); err != nil {
-				r.l.Error(err, "cannot apply pod")
+			if err := r.applyCertSecret(ctx, r.name); err != nil {
+				r.l.Error(err, "cannot apply certificate secret")
+				time.Sleep(defaultWaitTime)
+				goto INIT
+			}
+			if _, err := r.applyDeployment(ctx, r.name); err != nil {
+				r.l.Error(err, "cannot apply deployment")
 				time.Sleep(defaultWaitTime)
 				goto INIT
 			}
-			if _, err := r.applyService(ctx, r.podName); err != nil {
+			if _, err := r.applyService(ctx, r.name); err != nil {
 				r.l.Error(err, "cannot apply service")
 				time.Sleep(defaultWaitTime)
 				goto INIT
 			}
+			if _, err := r.applyPodDisruptionBudget(ctx, r.name); err != nil {
+				r.l.Error(err, "cannot apply pod disruption budget")
+				time.Sleep(defaultWaitTime)
+				goto INIT
+			}
+			if err := r.updateEndpoints(ctx, r.name); err != nil {
+				r.l.Error(err, "cannot update endpoints")
+			}
 
-			if err := r.start(ctx, r.podName); err != nil {
+			if err := r.start(ctx, r.name); err != nil {
 				//r.l.Error(err, "watch failed")
 				time.Sleep(defaultWaitTime)
 				goto INIT
@@ -121,42 +151,56 @@ func (r *controller) Start(ctx context.Context) error {
 	}
 }
 
-func (r *controller) start(ctx context.Context, podName string) error {
-	labelSelector := metav1.LabelSelector{
-		MatchLabels: map[string]string{
-			fnrunv1alpha1.FunctionLabelKey: podName,
-		}}
-	wpi, err := r.client.CoreV1().Pods(r.namespace).Watch(
-		ctx,
-		metav1.ListOptions{
-			LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
-			Watch:         true,
-		})
+func (r *controller) start(ctx context.Context, name string) error {
+	opts := metav1.ListOptions{
+		LabelSelector: labels.Set(map[string]string{
+			fnrunv1alpha1.FunctionLabelKey: name,
+		}).String(),
+		Watch: true,
+	}
+	wdi, err := r.client.AppsV1().Deployments(r.namespace).Watch(ctx, opts)
 	if err != nil {
-		r.l.Error(err, "cannot create pod watch")
+		r.l.Error(err, "cannot create deployment watch")
 		return err
 	}
-	wsi, err := r.client.CoreV1().Services(r.namespace).Watch(
-		ctx,
-		metav1.ListOptions{
-			LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
-			Watch:         true,
-		})
+	defer wdi.Stop()
+	wsi, err := r.client.CoreV1().Services(r.namespace).Watch(ctx, opts)
 	if err != nil {
 		r.l.Error(err, "cannot create service watch")
 		return err
 	}
+	defer wsi.Stop()
+	wpi, err := r.client.PolicyV1().PodDisruptionBudgets(r.namespace).Watch(ctx, opts)
+	if err != nil {
+		r.l.Error(err, "cannot create pod disruption budget watch")
+		return err
+	}
+	defer wpi.Stop()
+	wei, err := r.client.DiscoveryV1().EndpointSlices(r.namespace).Watch(ctx, metav1.ListOptions{
+		LabelSelector: endpointSliceSelector(name),
+		Watch:         true,
+	})
+	if err != nil {
+		r.l.Error(err, "cannot create endpointslice watch")
+		return err
+	}
+	defer wei.Stop()
+	certTicker := time.NewTicker(certCheckInterval)
+	defer certTicker.Stop()
 
 	for {
 		select {
-		case we, ok := <-wpi.ResultChan():
+		case <-certTicker.C:
+			if err := r.applyCertSecret(ctx, name); err != nil {
+				r.l.Error(err, "cannot apply certificate secret")
+			}
+		case we, ok := <-wdi.ResultChan():
 			if !ok {
 				err := fmt.Errorf("watch result nok, we: %v", we)
-				r.l.Error(err, "cannot watch pod channel")
+				r.l.Error(err, "cannot watch deployment channel")
 				return err
 			}
-			//r.l.Info("pod event", "event", we)
-			if _, err := r.applyPod(ctx, podName); err != nil {
+			if _, err := r.applyDeployment(ctx, name); err != nil {
 				r.l.Error(err, "cannot apply")
 			}
 		case we, ok := <-wsi.ResultChan():
@@ -165,11 +209,27 @@ func (r *controller) start(ctx context.Context, podName string) error {
 				r.l.Error(err, "cannot watch svc channel")
 				return err
 			}
-			//r.l.Info("service event", "event", we)
-			if _, err := r.applyService(ctx, podName); err != nil {
+			if _, err := r.applyService(ctx, name); err != nil {
 				r.l.Error(err, "cannot apply")
 			}
-
+		case we, ok := <-wpi.ResultChan():
+			if !ok {
+				err := fmt.Errorf("watch result nok, we: %v", we)
+				r.l.Error(err, "cannot watch pod disruption budget channel")
+				return err
+			}
+			if _, err := r.applyPodDisruptionBudget(ctx, name); err != nil {
+				r.l.Error(err, "cannot apply")
+			}
+		case we, ok := <-wei.ResultChan():
+			if !ok {
+				err := fmt.Errorf("watch result nok, we: %v", we)
+				r.l.Error(err, "cannot watch endpointslice channel")
+				return err
+			}
+			if err := r.updateEndpoints(ctx, name); err != nil {
+				r.l.Error(err, "cannot update endpoints")
+			}
 		case <-ctx.Done():
 			return nil
 		}
@@ -0,0 +1,68 @@
+package imgcontroller
+
+import (
+	"context"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	appsapplyv1 "k8s.io/client-go/applyconfigurations/apps/v1"
+	metaapplyv1 "k8s.io/client-go/applyconfigurations/meta/v1"
+)
+
+const fieldManager = "application/apply-patch"
+
+func (r *controller) applyDeployment(ctx context.Context, name string) (*appsv1.Deployment, error) {
+	d, err := r.buildDeployment(r.image, name)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.AppsV1().Deployments(r.namespace).Apply(ctx, d, metav1.ApplyOptions{FieldManager: fieldManager})
+}
+
+func (r *controller) buildDeployment(image fnrunv1alpha1.Image, name string) (*appsapplyv1.DeploymentApplyConfiguration, error) {
+	podTemplate, err := r.buildPodTemplate(image, name)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &appsapplyv1.DeploymentApplyConfiguration{}
+	d.WithAPIVersion("apps/v1")
+	d.WithKind("Deployment")
+	d.WithNamespace(r.namespace)
+	d.WithName(name)
+	d.WithLabels(map[string]string{
+		fnrunv1alpha1.FunctionLabelKey: name,
+	})
+	d.WithOwnerReferences(r.buildOwnerReference())
+
+	selector := &metaapplyv1.LabelSelectorApplyConfiguration{}
+	selector.WithMatchLabels(map[string]string{fnrunv1alpha1.FunctionLabelKey: name})
+
+	// a new pod has to be ready before an old one is removed
+	rollingUpdate := &appsapplyv1.RollingUpdateDeploymentApplyConfiguration{}
+	rollingUpdate.WithMaxUnavailable(intstr.FromInt(0))
+	rollingUpdate.WithMaxSurge(intstr.FromInt(1))
+	strategy := &appsapplyv1.DeploymentStrategyApplyConfiguration{}
+	strategy.WithType(appsv1.RollingUpdateDeploymentStrategyType)
+	strategy.WithRollingUpdate(rollingUpdate)
+
+	spec := &appsapplyv1.DeploymentSpecApplyConfiguration{}
+	spec.WithReplicas(r.replicas)
+	spec.WithSelector(selector)
+	spec.WithStrategy(strategy)
+	spec.WithTemplate(podTemplate)
+	d.WithSpec(spec)
+	return d, nil
+}
+
+func (r *controller) buildOwnerReference() *metaapplyv1.OwnerReferenceApplyConfiguration {
+	ownerRef := &metaapplyv1.OwnerReferenceApplyConfiguration{}
+	ownerRef.WithAPIVersion("v1")
+	ownerRef.WithKind("ConfigMap")
+	ownerRef.WithName(r.cm.GetName())
+	ownerRef.WithUID(r.cm.GetUID())
+	ownerRef.WithController(true)
+	return ownerRef
+}
@@ -0,0 +1,45 @@
+package imgcontroller
+
+import (
+	"context"
+	"sort"
+
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func endpointSliceSelector(name string) string {
+	return labels.Set(map[string]string{discoveryv1.LabelServiceName: name}).String()
+}
+
+// updateEndpoints sets the ready endpoints of the endpointslices of the
+// service of the image
+func (r *controller) updateEndpoints(ctx context.Context, name string) error {
+	l, err := r.client.DiscoveryV1().EndpointSlices(r.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: endpointSliceSelector(name),
+	})
+	if err != nil {
+		return err
+	}
+	endpoints := []imagestore.Endpoint{}
+	for _, eps := range l.Items {
+		for _, ep := range eps.Endpoints {
+			// a nil ready condition means ready
+			if (ep.Conditions.Ready != nil && !*ep.Conditions.Ready) || len(ep.Addresses) == 0 {
+				continue
+			}
+			e := imagestore.Endpoint{Address: ep.Addresses[0], ServerName: r.serverName(name)}
+			if ep.TargetRef != nil {
+				e.PodName = ep.TargetRef.Name
+			}
+			endpoints = append(endpoints, e)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Address < endpoints[j].Address
+	})
+	r.l.Info("endpoints updated", "name", name, "endpoints", endpoints)
+	return r.setEndpointsFn(r.image, endpoints)
+}
@@ -0,0 +1,39 @@
+package imgcontroller
+
+import (
+	"context"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	metaapplyv1 "k8s.io/client-go/applyconfigurations/meta/v1"
+	policyapplyv1 "k8s.io/client-go/applyconfigurations/policy/v1"
+)
+
+func (r *controller) applyPodDisruptionBudget(ctx context.Context, name string) (*policyv1.PodDisruptionBudget, error) {
+	return r.client.PolicyV1().PodDisruptionBudgets(r.namespace).Apply(ctx, r.buildPodDisruptionBudget(name), metav1.ApplyOptions{FieldManager: fieldManager})
+}
+
+// buildPodDisruptionBudget allows a single pod of the image to be disrupted
+// at a time, so a drain does not take all the replicas down at once
+func (r *controller) buildPodDisruptionBudget(name string) *policyapplyv1.PodDisruptionBudgetApplyConfiguration {
+	pdb := &policyapplyv1.PodDisruptionBudgetApplyConfiguration{}
+	pdb.WithAPIVersion("policy/v1")
+	pdb.WithKind("PodDisruptionBudget")
+	pdb.WithNamespace(r.namespace)
+	pdb.WithName(name)
+	pdb.WithLabels(map[string]string{
+		fnrunv1alpha1.FunctionLabelKey: name,
+	})
+	pdb.WithOwnerReferences(r.buildOwnerReference())
+
+	selector := &metaapplyv1.LabelSelectorApplyConfiguration{}
+	selector.WithMatchLabels(map[string]string{fnrunv1alpha1.FunctionLabelKey: name})
+
+	spec := &policyapplyv1.PodDisruptionBudgetSpecApplyConfiguration{}
+	spec.WithSelector(selector)
+	spec.WithMaxUnavailable(intstr.FromInt(1))
+	pdb.WithSpec(spec)
+	return pdb
+}
@@ -1,86 +1,50 @@
 package imgcontroller
 
 import (
-	"context"
 	"fmt"
 	"path/filepath"
 	"strconv"
 
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/certs"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	coreapplyv1 "k8s.io/client-go/applyconfigurations/core/v1"
-	metaapplyv1 "k8s.io/client-go/applyconfigurations/meta/v1"
+	"k8s.io/utils/pointer"
 )
 
-func (r *controller) applyPod(ctx context.Context, podName string) (*corev1.Pod, error) {
-	r.l.Info("apply pod", "podName", podName)
-	// apply pod
-	p, err := r.buildPod(r.image, podName)
-	if err != nil {
-		return nil, err
-	}
-
-	pod, err := r.client.CoreV1().Pods(r.namespace).Apply(ctx, p, metav1.ApplyOptions{FieldManager: "application/apply-patch"})
-	if err != nil {
-		return nil, err
-	}
-
-	pod, err = r.client.CoreV1().Pods(r.namespace).Get(ctx, podName, metav1.GetOptions{})
-	if err != nil {
-		r.deleteClientFn(r.image)
-		r.l.Info("pod client deleted", "podName", podName, "image", r.image.Name)
-		return nil, err
-	}
-	if pod.Status.Phase != "Running" {
-		r.deleteClientFn(r.image)
-		r.l.Info("pod client deleted", "podName", podName, "image", r.image.Name)
-		return pod, nil
-	}
-	for _, cond := range pod.Status.Conditions {
-		if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
-			r.l.Info("pod client created", "podName", podName, "image", r.image.Name, "IP", pod.Status.PodIP)
-			r.createClientFn(r.image, podName, pod.Status.PodIP)
-			return pod, nil
-		}
-		r.deleteClientFn(r.image)
-		r.l.Info("pod client deleted", "podName", podName, "image", r.image.Name)
-	}
-	return pod, nil
-}
-
-func (r *controller) deletePod(ctx context.Context, podName string) error {
-	// delete pod
-	return r.client.CoreV1().Pods(r.namespace).Delete(ctx, podName, metav1.DeleteOptions{})
-}
-
-func (r *controller) buildPod(image fnrunv1alpha1.Image, podName string) (*coreapplyv1.PodApplyConfiguration, error) {
-	pod := &coreapplyv1.PodApplyConfiguration{}
-	pod.WithAPIVersion("v1")
-	pod.WithKind("Pod")
-	pod.WithNamespace(r.namespace)
-	pod.WithName(podName)
+// buildPodTemplate builds the pod template of the deployment of the image
+func (r *controller) buildPodTemplate(image fnrunv1alpha1.Image, name string) (*coreapplyv1.PodTemplateSpecApplyConfiguration, error) {
+	pod := &coreapplyv1.PodTemplateSpecApplyConfiguration{}
 	pod.WithLabels(map[string]string{
-		fnrunv1alpha1.FunctionLabelKey: podName,
+		fnrunv1alpha1.FunctionLabelKey: name,
+	})
+	// a new digest of the image rolls out the deployment
+	pod.WithAnnotations(map[string]string{
+		fnrunv1alpha1.DigestAnnotationKey: r.de.GetDigest(),
 	})
 
-	r.l.Info("buildPod configmap info", "key", types.NamespacedName{Namespace: r.cm.GetNamespace(), Name: r.cm.GetName()}, "uid", r.cm.GetUID())
-
-	ownerRef := &metaapplyv1.OwnerReferenceApplyConfiguration{}
-	ownerRef.WithAPIVersion("v1")
-	ownerRef.WithKind("ConfigMap")
-	ownerRef.WithName(r.cm.GetName())
-	ownerRef.WithUID(r.cm.GetUID())
-	ownerRef.WithController(true)
-	pod.WithOwnerReferences(ownerRef)
-
-	// probe
+	// probe, the kubelet grpc probe does not support tls so the grpc health
+	// probe copied from the fnwrapper image is executed with mTLS
 	probe := &coreapplyv1.ProbeApplyConfiguration{}
-	grpc := &coreapplyv1.GRPCActionApplyConfiguration{}
-	grpc.WithPort(fnrunv1alpha1.FnGRPCServerPort)
-	grpc.WithService(podName)
-	probe.WithGRPC(grpc)
+	if r.mtls() {
+		exec := &coreapplyv1.ExecActionApplyConfiguration{}
+		exec.WithCommand(
+			filepath.Join(fnrunv1alpha1.VolumeMountPath, fnrunv1alpha1.HealthProbeBin),
+			fmt.Sprintf("-addr=localhost:%d", fnrunv1alpha1.FnGRPCServerPort),
+			fmt.Sprintf("-service=%s", name),
+			"-tls",
+			fmt.Sprintf("-tls-ca-cert=%s", filepath.Join(fnrunv1alpha1.TLSMountPath, certs.CABundleKey)),
+			fmt.Sprintf("-tls-client-cert=%s", filepath.Join(fnrunv1alpha1.TLSMountPath, probeCertKey)),
+			fmt.Sprintf("-tls-client-key=%s", filepath.Join(fnrunv1alpha1.TLSMountPath, probeKeyKey)),
+			fmt.Sprintf("-tls-server-name=%s", r.serverName(name)),
+		)
+		probe.WithExec(exec)
+	} else {
+		grpc := &coreapplyv1.GRPCActionApplyConfiguration{}
+		grpc.WithPort(fnrunv1alpha1.FnGRPCServerPort)
+		grpc.WithService(name)
+		probe.WithGRPC(grpc)
+	}
 
 	switch image.Kind {
 	case fnrunv1alpha1.ImageKindFunction:
@@ -99,10 +63,21 @@ func (r *controller) buildPod(image fnrunv1alpha1.Image, podName string) (*corea
 		initContainerVolumeMount.WithMountPath(fnrunv1alpha1.VolumeMountPath)
 		initContainer.WithVolumeMounts(initContainerVolumeMount)
 		// container
-		cmd := append([]string{
+		cmd := []string{
 			filepath.Join(fnrunv1alpha1.VolumeMountPath, fnrunv1alpha1.WrapperServerBin),
-			"--port", strconv.Itoa(fnrunv1alpha1.FnGRPCServerPort), "--",
-		}, r.de.GetEntrypoint()...)
+			"--port", strconv.Itoa(fnrunv1alpha1.FnGRPCServerPort),
+		}
+		if r.mtls() {
+			cmd = append(cmd,
+				"--tls-cert", filepath.Join(fnrunv1alpha1.TLSMountPath, corev1.TLSCertKey),
+				"--tls-key", filepath.Join(fnrunv1alpha1.TLSMountPath, corev1.TLSPrivateKeyKey),
+				"--tls-ca", filepath.Join(fnrunv1alpha1.TLSMountPath, certs.CABundleKey),
+			)
+		}
+		if r.maxMsgSize > 0 {
+			cmd = append(cmd, "--max-msg-size", strconv.Itoa(r.maxMsgSize))
+		}
+		cmd = append(append(cmd, "--"), r.de.GetEntrypoint()...)
 		container := &coreapplyv1.ContainerApplyConfiguration{}
 		container.WithName(fnrunv1alpha1.FnContainerName)
 		container.WithImage(r.image.Name)
@@ -119,8 +94,22 @@ func (r *controller) buildPod(image fnrunv1alpha1.Image, podName string) (*corea
 
 		podSpec := &coreapplyv1.PodSpecApplyConfiguration{}
 		podSpec.WithInitContainers(initContainer)
-		podSpec.WithContainers(container)
 		podSpec.WithVolumes(volume)
+		if r.mtls() {
+			tlsVolumeMount := &coreapplyv1.VolumeMountApplyConfiguration{}
+			tlsVolumeMount.WithName(fnrunv1alpha1.TLSVolumeName)
+			tlsVolumeMount.WithMountPath(fnrunv1alpha1.TLSMountPath)
+			tlsVolumeMount.WithReadOnly(true)
+			container.WithVolumeMounts(tlsVolumeMount)
+
+			tlsVolume := &coreapplyv1.VolumeApplyConfiguration{}
+			tlsVolume.WithName(fnrunv1alpha1.TLSVolumeName)
+			tlsVolume.WithSecret(&coreapplyv1.SecretVolumeSourceApplyConfiguration{
+				SecretName: pointer.String(certSecretName(name)),
+			})
+			podSpec.WithVolumes(tlsVolume)
+		}
+		podSpec.WithContainers(container)
 
 		pod.WithSpec(podSpec)
 		return pod, nil
@@ -140,7 +129,7 @@ func (r *controller) buildPod(image fnrunv1alpha1.Image, podName string) (*corea
 		pod.WithSpec(podSpec)
 		return pod, nil
 	default:
-		err := fmt.Errorf("cannot build pod with unknown image kind, got: %s", image.Kind)
+		err := fmt.Errorf("cannot build pod template with unknown image kind, got: %s", image.Kind)
 		r.l.Error(err, "unknown image kind")
 		return nil, err
 	}
@@ -0,0 +1,86 @@
+package imgcontroller
+
+import (
+	"context"
+
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Prune deletes the deployments, services, pod disruption budgets and
+// certificate secrets the configmap owns of the images which are no longer
+// in names, e.g. after the image was removed from the controller config
+func Prune(ctx context.Context, client *kubernetes.Clientset, namespace string, cm *corev1.ConfigMap, names map[string]struct{}) error {
+	opts := metav1.ListOptions{LabelSelector: fnrunv1alpha1.FunctionLabelKey}
+	stale := func(o metav1.Object) bool {
+		if _, ok := names[o.GetLabels()[fnrunv1alpha1.FunctionLabelKey]]; ok {
+			return false
+		}
+		return ownedBy(o, cm.GetUID())
+	}
+	ignoreNotFound := func(err error) error {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	dl, err := client.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, o := range dl.Items {
+		if stale(&o) {
+			if err := client.AppsV1().Deployments(namespace).Delete(ctx, o.GetName(), metav1.DeleteOptions{}); ignoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	sl, err := client.CoreV1().Services(namespace).List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, o := range sl.Items {
+		if stale(&o) {
+			if err := client.CoreV1().Services(namespace).Delete(ctx, o.GetName(), metav1.DeleteOptions{}); ignoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	pl, err := client.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, o := range pl.Items {
+		if stale(&o) {
+			if err := client.PolicyV1().PodDisruptionBudgets(namespace).Delete(ctx, o.GetName(), metav1.DeleteOptions{}); ignoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	secl, err := client.CoreV1().Secrets(namespace).List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, o := range secl.Items {
+		if stale(&o) {
+			if err := client.CoreV1().Secrets(namespace).Delete(ctx, o.GetName(), metav1.DeleteOptions{}); ignoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func ownedBy(o metav1.Object, uid types.UID) bool {
+	for _, ref := range o.GetOwnerReferences() {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
@@ -8,12 +8,11 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	coreapplyv1 "k8s.io/client-go/applyconfigurations/core/v1"
-	metaapplyv1 "k8s.io/client-go/applyconfigurations/meta/v1"
 )
 
 func (r *controller) applyService(ctx context.Context, podName string) (*corev1.Service, error) {
 	// apply service
-	return r.client.CoreV1().Services(r.namespace).Apply(ctx, r.buildService(r.image, podName), metav1.ApplyOptions{FieldManager: "application/apply-patch"})
+	return r.client.CoreV1().Services(r.namespace).Apply(ctx, r.buildService(r.image, podName), metav1.ApplyOptions{FieldManager: fieldManager})
 }
 
 func (r *controller) deleteService(ctx context.Context, podName string) error {
@@ -30,13 +29,7 @@ func (r *controller) buildService(image fnrunv1alpha1.Image, podName string) *co
 	svc.WithLabels(map[string]string{
 		fnrunv1alpha1.FunctionLabelKey: podName,
 	})
-	ownerRef := &metaapplyv1.OwnerReferenceApplyConfiguration{}
-	ownerRef.WithAPIVersion("v1")
-	ownerRef.WithKind("ConfigMap")
-	ownerRef.WithName(r.cm.GetName())
-	ownerRef.WithUID(r.cm.GetUID())
-	ownerRef.WithController(true)
-	svc.WithOwnerReferences(ownerRef)
+	svc.WithOwnerReferences(r.buildOwnerReference())
 
 	// spec
 	svcSpec := &coreapplyv1.ServiceSpecApplyConfiguration{}
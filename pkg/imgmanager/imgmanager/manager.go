@@ -20,10 +20,16 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/fnrunner/fnproto/pkg/executor/execclient"
+	"github.com/fnrunner/fnproto/pkg/service/svcclient"
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
-	"github.com/fnrunner/fnruntime/pkg/imgmanager/imgcontroller"
+	"github.com/fnrunner/fnruntime/pkg/certs"
+	"github.com/fnrunner/fnruntime/pkg/imgmanager/backend"
 	"github.com/fnrunner/fnruntime/pkg/store/ctrlstore"
+	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
 	"github.com/go-logr/logr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -41,6 +47,15 @@ type Config struct {
 	Namespace       string
 	Images          []*fnrunv1alpha1.Image
 	ConfigMap       *corev1.ConfigMap
+	// Authority issues the certificates of the function pods when mTLS is
+	// enabled
+	Authority certs.Authority
+	// Backends selects the backend of the images, all images run in pods
+	// when nil
+	Backends *backend.Selection
+	// MaxMsgSize is the max size of a message to and from the function
+	// pods, the grpc defaults apply when 0
+	MaxMsgSize int
 }
 
 func New(cfg *Config) (Manager, error) {
@@ -50,69 +65,87 @@ func New(cfg *Config) (Manager, error) {
 	if imageStore == nil {
 		return nil, fmt.Errorf("cannot create img manager, respective controller not initialize in store")
 	}
+	bcfg := &backend.Config{
+		ControllerName: cfg.ControllerName,
+		ImageStore:     imageStore,
+		Client:         cfg.Client,
+		Namespace:      cfg.Namespace,
+		ConfigMap:      cfg.ConfigMap,
+		Authority:      cfg.Authority,
+		ExecDir:        cfg.Backends.GetExecDir(),
+		MaxMsgSize:     cfg.MaxMsgSize,
+	}
+	backends := map[backend.Kind]backend.Backend{}
+	images := map[fnrunv1alpha1.Image]backend.Backend{}
+	podImages := []fnrunv1alpha1.Image{}
 	for _, image := range cfg.Images {
-		imageStore.Create(*image)
+		kind := cfg.Backends.Kind(image.Name)
+		if _, ok := backends[kind]; !ok {
+			b, err := backend.New(kind, bcfg)
+			if err != nil {
+				return nil, err
+			}
+			backends[kind] = b
+		}
+		if kind == backend.KindPod {
+			podImages = append(podImages, *image)
+		}
+		images[*image] = backends[kind]
 	}
-	return &imgmgr{
+	r := &imgmgr{
 		controllerName: cfg.ControllerName,
 		errChan:        make(chan error),
 		ctrlStore:      cfg.ControllerStore,
-		client:         cfg.Client,
-		namespace:      cfg.Namespace,
-		cm:             cfg.ConfigMap,
+		bcfg:           bcfg,
+		images:         images,
+		podImages:      podImages,
 		l:              l,
-	}, nil
+	}
+	// the proxy resolves the images with the backends running them, the
+	// resolver is set before the images exist in the store
+	if err := cfg.ControllerStore.SetImageResolver(cfg.ControllerName, r); err != nil {
+		return nil, err
+	}
+	for image := range images {
+		imageStore.Create(image)
+	}
+	// remove the images the controller no longer uses, otherwise they are
+	// never serving
+	for _, image := range imageStore.List() {
+		if _, ok := images[image]; !ok {
+			imageStore.Delete(image)
+		}
+	}
+	return r, nil
 }
 
 type imgmgr struct {
 	controllerName string
 	errChan        chan error
 	ctrlStore      ctrlstore.Store
-	client         *kubernetes.Clientset
-	namespace      string
-	//mgr            manager.Manager
-	cm     *corev1.ConfigMap
-	l      logr.Logger
-	cancel context.CancelFunc
+	bcfg           *backend.Config
+	// images holds the backend per image
+	images map[fnrunv1alpha1.Image]backend.Backend
+	// podImages are the images running in the pod backend, the deployments
+	// of the other images are pruned
+	podImages []fnrunv1alpha1.Image
+	l         logr.Logger
+	cancel    context.CancelFunc
 }
 
 func (r *imgmgr) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	r.cancel = cancel
-	imageStore := r.ctrlStore.GetImageStore(r.controllerName)
-	if imageStore == nil {
-		return fmt.Errorf("cannot create img manager, respective controller not initialized in store")
+	if r.bcfg.Client != nil {
+		if err := backend.Prune(ctx, r.bcfg, r.podImages); err != nil {
+			r.l.Error(err, "cannot prune the deployments of the removed images")
+		}
 	}
-	for _, image := range imageStore.List() {
+	for image, b := range r.images {
 		r.l.Info("imgmr start", "imageInfo", image)
-		de, err := getImageDigestAndEntrypoint(ctx, image.Name)
-		if err != nil {
-			return err
-		}
-
-		podName, err := podName(r.controllerName, image.Name, de.Digest)
-		if err != nil {
+		if err := b.Ensure(ctx, image); err != nil {
 			return err
 		}
-
-		imgc := imgcontroller.New(&imgcontroller.Config{
-			Namespace:      r.namespace,
-			ControllerName: r.controllerName,
-			Client:         r.client,
-			Image:          image,
-			PodName:        podName,
-			De:             de,
-			ConfigMap:      r.cm,
-			CreateClientFn: imageStore.SetClient,
-			DeleteClientFn: imageStore.DeleteClient,
-		})
-		go func() {
-			if err := imgc.Start(ctx); err != nil {
-				r.l.Error(err, "cannot start/crash img controller")
-			}
-			// cancel the controllers
-			r.cancel()
-		}()
 	}
 	/*
 		for {
@@ -131,7 +164,46 @@ func (r *imgmgr) Start(ctx context.Context) error {
 
 func (r *imgmgr) Stop() {
 	if r.cancel != nil {
+		for image, b := range r.images {
+			if err := b.Release(context.Background(), image); err != nil {
+				r.l.Error(err, "cannot release image", "image", image.Name)
+			}
+		}
 		r.cancel()
 		r.cancel = nil
 	}
 }
+
+// ResolveFn resolves the client of the image with the backend running it
+func (r *imgmgr) ResolveFn(ctx context.Context, image fnrunv1alpha1.Image) (execclient.Client, error) {
+	b, err := r.getBackend(image)
+	if err != nil {
+		return nil, err
+	}
+	return b.ResolveFn(ctx, image)
+}
+
+// ResolveSvc resolves the client of the image with the backend running it
+func (r *imgmgr) ResolveSvc(ctx context.Context, image fnrunv1alpha1.Image) (svcclient.Client, error) {
+	b, err := r.getBackend(image)
+	if err != nil {
+		return nil, err
+	}
+	return b.ResolveSvc(ctx, image)
+}
+
+func (r *imgmgr) Status(image fnrunv1alpha1.Image) imagestore.ServingStatus {
+	b, ok := r.images[image]
+	if !ok {
+		return imagestore.StatusUnknown
+	}
+	return b.Status(image)
+}
+
+func (r *imgmgr) getBackend(image fnrunv1alpha1.Image) (backend.Backend, error) {
+	b, ok := r.images[image]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "image %s is not used by controller %s", image.Name, r.controllerName)
+	}
+	return b, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnruntime/pkg/exec/fnruntime"
+	fnresultv1alpha1 "github.com/fnrunner/fnsyntax/apis/fnresult/v1alpha1"
+)
+
+// EnvOperation is the env variable telling an executable which service
+// operation it runs, it is not set for executions
+const EnvOperation = "FNRUN_OPERATION"
+
+const (
+	operationApply  = "apply"
+	operationDelete = "delete"
+)
+
+// Executable runs the functions of an image with a local executable using the
+// stdin/stdout protocol of fnruntime.ExecFn. Executions get the serialized
+// resourceContext on stdin and write the resulting resourceContext on stdout.
+// Service operations get the resource on stdin, the operation in the
+// FNRUN_OPERATION env variable and write the resulting resource on stdout.
+type Executable struct {
+	Path    string
+	Args    []string
+	Env     map[string]string
+	Timeout time.Duration
+}
+
+func (r *Executable) run(ctx context.Context, in []byte, operation string) ([]byte, error) {
+	env := map[string]string{}
+	for k, v := range r.Env {
+		env[k] = v
+	}
+	if operation != "" {
+		env[EnvOperation] = operation
+	}
+	f := &fnruntime.ExecFn{
+		Path:     r.Path,
+		Args:     r.Args,
+		Env:      env,
+		Timeout:  r.Timeout,
+		FnResult: &fnresultv1alpha1.Result{ExecPath: r.Path},
+	}
+	out := &bytes.Buffer{}
+	if err := f.FnRun(ctx, bytes.NewReader(in), out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (r *Executable) Execute(ctx context.Context, req *executorpb.ExecuteFunctionRequest) ([]byte, error) {
+	return r.run(ctx, req.GetResourceContext(), "")
+}
+
+func (r *Executable) ApplyResource(ctx context.Context, req *servicepb.FunctionServiceRequest) ([]byte, error) {
+	return r.run(ctx, req.GetResource(), operationApply)
+}
+
+func (r *Executable) DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) error {
+	_, err := r.run(ctx, req.GetResource(), operationDelete)
+	return err
+}
+
+// Container runs the functions of an image with a local container using the
+// stdin/stdout protocol of fnruntime.ContainerFn, the same protocol as the
+// Executable. The container runtime is selected with the KPT_FN_RUNTIME env
+// variable.
+type Container struct {
+	Image   string
+	Env     map[string]string
+	Timeout time.Duration
+	Perm    fnruntime.ContainerFnPermission
+}
+
+func (r *Container) run(ctx context.Context, in []byte, operation string) ([]byte, error) {
+	env := []string{}
+	for k, v := range r.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	if operation != "" {
+		env = append(env, fmt.Sprintf("%s=%s", EnvOperation, operation))
+	}
+	f := &fnruntime.ContainerFn{
+		Ctx:      ctx,
+		Image:    r.Image,
+		Timeout:  r.Timeout,
+		Perm:     r.Perm,
+		Env:      env,
+		FnResult: &fnresultv1alpha1.Result{Image: r.Image},
+	}
+	out := &bytes.Buffer{}
+	if err := f.FnRun(ctx, bytes.NewReader(in), out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (r *Container) Execute(ctx context.Context, req *executorpb.ExecuteFunctionRequest) ([]byte, error) {
+	return r.run(ctx, req.GetResourceContext(), "")
+}
+
+func (r *Container) ApplyResource(ctx context.Context, req *servicepb.FunctionServiceRequest) ([]byte, error) {
+	return r.run(ctx, req.GetResource(), operationApply)
+}
+
+func (r *Container) DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) error {
+	_, err := r.run(ctx, req.GetResource(), operationDelete)
+	return err
+}
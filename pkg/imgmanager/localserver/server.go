@@ -0,0 +1,180 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package localserver provides an in-process function executor and function
+// service server, which runs the functions of an image with a local
+// executable or a local container instead of a function pod.
+package localserver
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Backend runs the functions of an image
+type Backend interface {
+	// Execute executes the function with the serialized resourceContext and
+	// returns the resulting serialized resourceContext
+	Execute(ctx context.Context, req *executorpb.ExecuteFunctionRequest) ([]byte, error)
+	// ApplyResource applies the serialized resource and returns the
+	// resulting resource, including status
+	ApplyResource(ctx context.Context, req *servicepb.FunctionServiceRequest) ([]byte, error)
+	// DeleteResource deletes the serialized resource
+	DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) error
+}
+
+type Server interface {
+	executorpb.FunctionExecutorServer
+	servicepb.FunctionServiceServer
+	// AddBackend adds or replaces the backend of an image
+	AddBackend(image string, b Backend)
+	// Register registers the executor and service server on a grpc server
+	Register(s *grpc.Server)
+	// Serve serves the functions on the listener until the context is done
+	Serve(ctx context.Context, l net.Listener) error
+}
+
+type Option func(*server)
+
+// WithBackend runs the functions of the image with the backend
+func WithBackend(image string, b Backend) Option {
+	return func(s *server) {
+		s.backends[image] = b
+	}
+}
+
+// WithExecutable runs the functions of the image with a local executable
+func WithExecutable(image string, e *Executable) Option {
+	return WithBackend(image, e)
+}
+
+// WithContainer runs the functions of the image with a local container
+func WithContainer(image string, c *Container) Option {
+	return WithBackend(image, c)
+}
+
+// WithMaxMsgSize sets the max size of a received and a sent message, the
+// grpc defaults apply when 0
+func WithMaxMsgSize(n int) Option {
+	return func(s *server) {
+		s.maxMsgSize = n
+	}
+}
+
+func New(opts ...Option) Server {
+	s := &server{
+		backends: map[string]Backend{},
+		l:        ctrl.Log.WithName("local fn server"),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+type server struct {
+	executorpb.UnimplementedFunctionExecutorServer
+	servicepb.UnimplementedFunctionServiceServer
+
+	m        sync.RWMutex
+	backends map[string]Backend
+	// maxMsgSize is the max size of a message, the grpc defaults apply when 0
+	maxMsgSize int
+	l          logr.Logger
+}
+
+func (r *server) AddBackend(image string, b Backend) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.backends[image] = b
+}
+
+func (r *server) getBackend(image string) (Backend, error) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	b, ok := r.backends[image]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no backend for image %s", image)
+	}
+	return b, nil
+}
+
+func (r *server) Register(s *grpc.Server) {
+	executorpb.RegisterFunctionExecutorServer(s, r)
+	servicepb.RegisterFunctionServiceServer(s, r)
+}
+
+func (r *server) Serve(ctx context.Context, l net.Listener) error {
+	opts := []grpc.ServerOption{}
+	if r.maxMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(r.maxMsgSize), grpc.MaxSendMsgSize(r.maxMsgSize))
+	}
+	s := grpc.NewServer(opts...)
+	r.Register(s)
+	go func() {
+		<-ctx.Done()
+		s.Stop()
+	}()
+	return s.Serve(l)
+}
+
+func (r *server) ExecuteFunction(ctx context.Context, req *executorpb.ExecuteFunctionRequest) (*executorpb.ExecuteFunctionResponse, error) {
+	r.l.Info("execute fn", "image", req.GetImage(), "controller", req.GetController())
+	b, err := r.getBackend(req.GetImage())
+	if err != nil {
+		return nil, err
+	}
+	rctx, err := b.Execute(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &executorpb.ExecuteFunctionResponse{ResourceContext: rctx}, nil
+}
+
+func (r *server) ApplyResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (*servicepb.FunctionServiceResponse, error) {
+	r.l.Info("apply resource", "image", req.GetImage())
+	b, err := r.getBackend(req.GetImage())
+	if err != nil {
+		return nil, err
+	}
+	resource, err := b.ApplyResource(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &servicepb.FunctionServiceResponse{Resource: string(resource)}, nil
+}
+
+func (r *server) DeleteResource(ctx context.Context, req *servicepb.FunctionServiceRequest) (*emptypb.Empty, error) {
+	r.l.Info("delete resource", "image", req.GetImage())
+	b, err := r.getBackend(req.GetImage())
+	if err != nil {
+		return nil, err
+	}
+	if err := b.DeleteResource(ctx, req); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -17,10 +17,15 @@ limitations under the License.
 package ctrlstore
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
+	"github.com/fnrunner/fnproto/pkg/executor/execclient"
+	"github.com/fnrunner/fnproto/pkg/service/svcclient"
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
 	"github.com/fnrunner/fnruntime/pkg/store/imagestore"
+	"github.com/fnrunner/fnsyntax/pkg/ccsyntax"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -31,23 +36,79 @@ type Store interface {
 	Delete(controllerName string)
 	SetConfigMap(controllerName string, cm *corev1.ConfigMap) error
 	GetConfigMap(controllerName string) *corev1.ConfigMap
+	SetExecutionContext(controllerName string, ceCtx ccsyntax.ConfigExecutionContext) error
+	GetExecutionContext(controllerName string) ccsyntax.ConfigExecutionContext
+	SetExecController(controllerName string, c ExecController) error
+	GetExecController(controllerName string) ExecController
+	SetImageResolver(controllerName string, r ImageResolver) error
+	GetImageResolver(controllerName string) ImageResolver
 
 	GetImageStore(controllerName string) imagestore.Store
 }
 
-func New() Store {
-	return &store{
+// ExecController is the fnexec controller running the execution context of
+// a controller
+type ExecController interface {
+	IsRunning() bool
+	Error() error
+}
+
+// ImageResolver resolves the clients and the serving status of the images of
+// a controller with the backends running them
+type ImageResolver interface {
+	// ResolveFn and ResolveSvc wait for a client of the image within the ctx
+	// deadline
+	ResolveFn(ctx context.Context, image fnrunv1alpha1.Image) (execclient.Client, error)
+	ResolveSvc(ctx context.Context, image fnrunv1alpha1.Image) (svcclient.Client, error)
+	// Status returns the serving status of the image, StatusUnknown when the
+	// controller does not use the image
+	Status(image fnrunv1alpha1.Image) imagestore.ServingStatus
+}
+
+type Option func(*store)
+
+// WithImageStoreOptions applies the options to the image store of every
+// controller
+func WithImageStoreOptions(opts ...imagestore.Option) Option {
+	return func(r *store) {
+		r.opts = append(r.opts, opts...)
+	}
+}
+
+// ImageStatusFn is called on the transitions of the serving status of the
+// images of a controller
+type ImageStatusFn func(controllerName string, image fnrunv1alpha1.Image, status imagestore.ServingStatus)
+
+// WithImageStatusFn reports the serving status transitions of the images of
+// every controller
+func WithImageStatusFn(fn ImageStatusFn) Option {
+	return func(r *store) {
+		r.statusFn = fn
+	}
+}
+
+func New(opts ...Option) Store {
+	r := &store{
 		d: map[string]*controllerCtx{},
 	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
 }
 
 type store struct {
-	m sync.RWMutex
-	d map[string]*controllerCtx
+	m        sync.RWMutex
+	d        map[string]*controllerCtx
+	opts     []imagestore.Option
+	statusFn ImageStatusFn
 }
 
 type controllerCtx struct {
 	configMap  *corev1.ConfigMap
+	ceCtx      ccsyntax.ConfigExecutionContext
+	execCtrl   ExecController
+	resolver   ImageResolver
 	imageStore imagestore.Store
 }
 
@@ -74,8 +135,14 @@ func (r *store) Create(controllerName string) {
 	r.m.Lock()
 	defer r.m.Unlock()
 	if _, ok := r.d[controllerName]; !ok {
+		opts := r.opts
+		if r.statusFn != nil {
+			opts = append(append([]imagestore.Option{}, r.opts...), imagestore.WithStatusFn(func(image fnrunv1alpha1.Image, status imagestore.ServingStatus) {
+				r.statusFn(controllerName, image, status)
+			}))
+		}
 		r.d[controllerName] = &controllerCtx{
-			imageStore: imagestore.New(),
+			imageStore: imagestore.New(opts...),
 		}
 	}
 	// if the entry already exists we dont want to reinitialize
@@ -84,6 +151,12 @@ func (r *store) Create(controllerName string) {
 func (r *store) Delete(controllerName string) {
 	r.m.Lock()
 	defer r.m.Unlock()
+	if ctrlCtx, ok := r.d[controllerName]; ok {
+		// closes the clients and reports the images as deleted
+		for _, image := range ctrlCtx.imageStore.List() {
+			ctrlCtx.imageStore.Delete(image)
+		}
+	}
 	delete(r.d, controllerName)
 }
 
@@ -108,6 +181,27 @@ func (r *store) GetConfigMap(controllerName string) *corev1.ConfigMap {
 	return nil
 }
 
+func (r *store) SetExecutionContext(controllerName string, ceCtx ccsyntax.ConfigExecutionContext) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	ctrlCtx, ok := r.d[controllerName]
+	if !ok {
+		return fmt.Errorf("cannot set execution context, controller entry is not initialized")
+	}
+	ctrlCtx.ceCtx = ceCtx
+	return nil
+}
+
+func (r *store) GetExecutionContext(controllerName string) ccsyntax.ConfigExecutionContext {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	ctrlCtx, ok := r.d[controllerName]
+	if ok {
+		return ctrlCtx.ceCtx
+	}
+	return nil
+}
+
 func (r *store) GetImageStore(controllerName string) imagestore.Store {
 	r.m.RLock()
 	defer r.m.RUnlock()
@@ -117,3 +211,45 @@ func (r *store) GetImageStore(controllerName string) imagestore.Store {
 	}
 	return nil
 }
+
+func (r *store) SetExecController(controllerName string, c ExecController) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	ctrlCtx, ok := r.d[controllerName]
+	if !ok {
+		return fmt.Errorf("cannot set exec controller, controller entry is not initialized")
+	}
+	ctrlCtx.execCtrl = c
+	return nil
+}
+
+func (r *store) GetExecController(controllerName string) ExecController {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	ctrlCtx, ok := r.d[controllerName]
+	if ok {
+		return ctrlCtx.execCtrl
+	}
+	return nil
+}
+
+func (r *store) SetImageResolver(controllerName string, resolver ImageResolver) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	ctrlCtx, ok := r.d[controllerName]
+	if !ok {
+		return fmt.Errorf("cannot set image resolver, controller entry is not initialized")
+	}
+	ctrlCtx.resolver = resolver
+	return nil
+}
+
+func (r *store) GetImageResolver(controllerName string) ImageResolver {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	ctrlCtx, ok := r.d[controllerName]
+	if ok {
+		return ctrlCtx.resolver
+	}
+	return nil
+}
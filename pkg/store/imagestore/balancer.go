@@ -0,0 +1,324 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fnrunner/fnproto/pkg/executor/execclient"
+	"github.com/fnrunner/fnproto/pkg/executor/executorpb"
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	"github.com/fnrunner/fnproto/pkg/service/svcclient"
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"github.com/fnrunner/fnruntime/pkg/fnproxy/clients"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type BalancePolicy string
+
+const (
+	BalanceRoundRobin       BalancePolicy = "roundRobin"
+	BalanceLeastOutstanding BalancePolicy = "leastOutstanding"
+
+	// an ejected endpoint is used again after the eject time, or when it
+	// becomes ready again: an endpoint which is no longer ready is removed
+	// and added without ejection when it is ready again, a new pod on the
+	// address of an ejected endpoint resets the ejection
+	ejectTime = 30 * time.Second
+)
+
+// EndpointStats are the balancing stats of an endpoint
+type EndpointStats struct {
+	Endpoint
+	Outstanding  int64
+	Requests     uint64
+	Failures     uint64
+	Ejected      bool
+	EjectedUntil time.Time
+}
+
+type endpoint struct {
+	Endpoint
+	execclient execclient.Client
+	svcclient  svcclient.Client
+
+	outstanding  atomic.Int64
+	requests     atomic.Uint64
+	failures     atomic.Uint64
+	ejectedUntil atomic.Int64 // unix nano
+
+	// the clients of a removed endpoint are closed when its outstanding
+	// requests are done
+	removed   atomic.Bool
+	closeOnce sync.Once
+}
+
+func (r *store) newEndpoint(image fnrunv1alpha1.Image, e Endpoint) (*endpoint, error) {
+	ep := &endpoint{Endpoint: e}
+	address := fmt.Sprintf("%s:%d", e.Address, fnrunv1alpha1.FnGRPCServerPort)
+	opts := []grpc.DialOption{}
+	if r.maxMsgSize > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(r.maxMsgSize),
+			grpc.MaxCallSendMsgSize(r.maxMsgSize),
+		))
+	}
+	if e.Dialer != nil {
+		conn, err := grpc.Dial(fmt.Sprintf("passthrough:///%s", e.Address), append(opts,
+			grpc.WithContextDialer(e.Dialer),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)...)
+		if err != nil {
+			return nil, err
+		}
+		c := clients.NewFromConn(conn)
+		ep.execclient, ep.svcclient = c.Execclient, c.Svcclient
+		return ep, nil
+	}
+	switch image.Kind {
+	case fnrunv1alpha1.ImageKindFunction:
+		if r.tlsConfigFn != nil {
+			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(r.tlsConfigFn(e.ServerName))))
+		} else {
+			opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		}
+		conn, err := grpc.Dial(address, opts...)
+		if err != nil {
+			return nil, err
+		}
+		ep.execclient = clients.NewFromConn(conn).Execclient
+	case fnrunv1alpha1.ImageKindService:
+		// service images serve grpc themselves without the fnwrapper, they
+		// are called in plaintext
+		conn, err := grpc.Dial(address, append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+		if err != nil {
+			return nil, err
+		}
+		ep.svcclient = clients.NewFromConn(conn).Svcclient
+	default:
+		return nil, fmt.Errorf("cannot set client with unknown image kind, got: %s", image.Kind)
+	}
+	return ep, nil
+}
+
+// close removes the endpoint, its clients are closed right away or by the
+// last outstanding request. It is called with the store locked, so no new
+// requests are started on the endpoint.
+func (r *endpoint) close() {
+	r.removed.Store(true)
+	if r.outstanding.Load() == 0 {
+		r.closeClients()
+	}
+}
+
+func (r *endpoint) closeClients() {
+	r.closeOnce.Do(func() {
+		if r.execclient != nil {
+			r.execclient.Close()
+		}
+		if r.svcclient != nil {
+			r.svcclient.Close()
+		}
+	})
+}
+
+func (r *endpoint) ejected(now time.Time) bool {
+	return now.UnixNano() < r.ejectedUntil.Load()
+}
+
+func (r *endpoint) stats() EndpointStats {
+	s := EndpointStats{
+		Endpoint:    r.Endpoint,
+		Outstanding: r.outstanding.Load(),
+		Requests:    r.requests.Load(),
+		Failures:    r.failures.Load(),
+		Ejected:     r.ejected(time.Now()),
+	}
+	if s.Ejected {
+		s.EjectedUntil = time.Unix(0, r.ejectedUntil.Load())
+	}
+	return s
+}
+
+// start accounts a request to the endpoint, it is called by pick with the
+// store locked. done accounts its result and ejects the endpoint when it is
+// unavailable.
+func (r *endpoint) start() {
+	r.requests.Add(1)
+	r.outstanding.Add(1)
+}
+
+func (r *endpoint) done(err error) {
+	if r.outstanding.Add(-1) == 0 && r.removed.Load() {
+		r.closeClients()
+	}
+	if err == nil {
+		return
+	}
+	r.failures.Add(1)
+	if status.Code(err) == codes.Unavailable {
+		r.ejectedUntil.Store(time.Now().Add(ejectTime).UnixNano())
+	}
+}
+
+// pick returns the endpoint for the next request of the image and starts
+// the request on it, nil if no endpoint is available. The caller reports the
+// result of the request with done.
+func (r *store) pick(image fnrunv1alpha1.Image) *endpoint {
+	r.m.Lock()
+	defer r.m.Unlock()
+	c, ok := r.d[image]
+	if !ok || len(c.endpoints) == 0 {
+		return nil
+	}
+	now := time.Now()
+	n := len(c.endpoints)
+	picked := -1
+	for i := 0; i < n; i++ {
+		idx := int((c.next + uint64(i)) % uint64(n))
+		ep := c.endpoints[idx]
+		if ep.ejected(now) {
+			continue
+		}
+		if r.policy != BalanceLeastOutstanding {
+			picked = idx
+			break
+		}
+		if picked < 0 || ep.outstanding.Load() < c.endpoints[picked].outstanding.Load() {
+			picked = idx
+		}
+	}
+	if picked < 0 {
+		return nil
+	}
+	// the next request starts after the picked endpoint, so the successor of
+	// a skipped endpoint does not get its requests as well
+	c.next = uint64(picked) + 1
+	ep := c.endpoints[picked]
+	ep.start()
+	return ep
+}
+
+// available returns true if the image has an endpoint which is not ejected
+func (r *store) available(image fnrunv1alpha1.Image) bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	c, ok := r.d[image]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	for _, ep := range c.endpoints {
+		if !ep.ejected(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// peek returns the endpoint the next request is likely sent to without
+// advancing the balancer
+func (r *store) peek(image fnrunv1alpha1.Image) *endpoint {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	c, ok := r.d[image]
+	if !ok || len(c.endpoints) == 0 {
+		return nil
+	}
+	return c.endpoints[int(c.next%uint64(len(c.endpoints)))]
+}
+
+// balancedExecClient picks an endpoint of the image per request
+type balancedExecClient struct {
+	store *store
+	image fnrunv1alpha1.Image
+}
+
+func (r *balancedExecClient) GetConfig() execclient.Config {
+	ep := r.store.peek(r.image)
+	if ep == nil || ep.execclient == nil {
+		return execclient.Config{}
+	}
+	return ep.execclient.GetConfig()
+}
+
+func (r *balancedExecClient) Get() executorpb.FunctionExecutorClient { return r }
+
+// Close is a no-op, the connections are owned by the store
+func (r *balancedExecClient) Close() error { return nil }
+
+func (r *balancedExecClient) ExecuteFunction(ctx context.Context, in *executorpb.ExecuteFunctionRequest, opts ...grpc.CallOption) (*executorpb.ExecuteFunctionResponse, error) {
+	ep := r.store.pick(r.image)
+	if ep == nil {
+		return nil, status.Errorf(codes.Unavailable, "no endpoint available for image %s", r.image.Name)
+	}
+	if ep.execclient == nil {
+		ep.done(nil)
+		return nil, status.Errorf(codes.Unavailable, "no endpoint available for image %s", r.image.Name)
+	}
+	resp, err := ep.execclient.Get().ExecuteFunction(ctx, in, opts...)
+	ep.done(err)
+	return resp, err
+}
+
+// balancedSvcClient picks an endpoint of the image per request
+type balancedSvcClient struct {
+	store *store
+	image fnrunv1alpha1.Image
+}
+
+func (r *balancedSvcClient) Get() servicepb.FunctionServiceClient { return r }
+
+// Close is a no-op, the connections are owned by the store
+func (r *balancedSvcClient) Close() error { return nil }
+
+func (r *balancedSvcClient) ApplyResource(ctx context.Context, in *servicepb.FunctionServiceRequest, opts ...grpc.CallOption) (*servicepb.FunctionServiceResponse, error) {
+	ep := r.store.pick(r.image)
+	if ep == nil {
+		return nil, status.Errorf(codes.Unavailable, "no endpoint available for image %s", r.image.Name)
+	}
+	if ep.svcclient == nil {
+		ep.done(nil)
+		return nil, status.Errorf(codes.Unavailable, "no endpoint available for image %s", r.image.Name)
+	}
+	resp, err := ep.svcclient.Get().ApplyResource(ctx, in, opts...)
+	ep.done(err)
+	return resp, err
+}
+
+func (r *balancedSvcClient) DeleteResource(ctx context.Context, in *servicepb.FunctionServiceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	ep := r.store.pick(r.image)
+	if ep == nil {
+		return nil, status.Errorf(codes.Unavailable, "no endpoint available for image %s", r.image.Name)
+	}
+	if ep.svcclient == nil {
+		ep.done(nil)
+		return nil, status.Errorf(codes.Unavailable, "no endpoint available for image %s", r.image.Name)
+	}
+	resp, err := ep.svcclient.Get().DeleteResource(ctx, in, opts...)
+	ep.done(err)
+	return resp, err
+}
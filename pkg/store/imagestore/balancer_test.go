@@ -0,0 +1,223 @@
+/*
+Copyright 2023 Nokia.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagestore
+
+import (
+	"testing"
+
+	"github.com/fnrunner/fnproto/pkg/service/servicepb"
+	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testImage = fnrunv1alpha1.Image{Name: "image", Kind: fnrunv1alpha1.ImageKindService}
+
+type fakeSvcClient struct {
+	closed int
+}
+
+func (r *fakeSvcClient) Get() servicepb.FunctionServiceClient { return nil }
+
+func (r *fakeSvcClient) Close() error {
+	r.closed++
+	return nil
+}
+
+func newTestStore(t *testing.T, policy BalancePolicy, addresses ...string) *store {
+	t.Helper()
+	r := New(WithBalancePolicy(policy)).(*store)
+	r.Create(testImage)
+	endpoints := make([]Endpoint, 0, len(addresses))
+	for _, a := range addresses {
+		endpoints = append(endpoints, Endpoint{PodName: a, Address: a})
+	}
+	if err := r.SetEndpoints(testImage, endpoints); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func pickN(r *store, n int) map[string]int {
+	picks := map[string]int{}
+	for i := 0; i < n; i++ {
+		ep := r.pick(testImage)
+		if ep == nil {
+			picks[""]++
+			continue
+		}
+		picks[ep.Address]++
+		ep.done(nil)
+	}
+	return picks
+}
+
+func TestPickRoundRobin(t *testing.T) {
+	r := newTestStore(t, BalanceRoundRobin, "10.0.0.1", "10.0.0.2", "10.0.0.3")
+	picks := pickN(r, 30)
+	for _, a := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		if picks[a] != 10 {
+			t.Errorf("expected 10 picks of %s, got: %v", a, picks)
+		}
+	}
+}
+
+func TestPickRoundRobinEjected(t *testing.T) {
+	r := newTestStore(t, BalanceRoundRobin, "10.0.0.1", "10.0.0.2", "10.0.0.3")
+	ep := r.pick(testImage)
+	if ep.Address != "10.0.0.1" {
+		t.Fatalf("expected 10.0.0.1, got: %s", ep.Address)
+	}
+	ep.done(status.Error(codes.Unavailable, "unavailable"))
+
+	// the load of the ejected endpoint is spread over the others
+	picks := pickN(r, 30)
+	if picks["10.0.0.1"] != 0 || picks["10.0.0.2"] != 15 || picks["10.0.0.3"] != 15 {
+		t.Errorf("unexpected picks: %v", picks)
+	}
+	if stats := ep.stats(); !stats.Ejected || stats.Failures != 1 {
+		t.Errorf("expected an ejected endpoint with 1 failure, got: %+v", stats)
+	}
+}
+
+func TestPickAllEjected(t *testing.T) {
+	r := newTestStore(t, BalanceRoundRobin, "10.0.0.1", "10.0.0.2")
+	for i := 0; i < 2; i++ {
+		r.pick(testImage).done(status.Error(codes.Unavailable, "unavailable"))
+	}
+	if ep := r.pick(testImage); ep != nil {
+		t.Errorf("expected no endpoint, got: %s", ep.Address)
+	}
+	if r.available(testImage) {
+		t.Error("expected the image to be unavailable")
+	}
+}
+
+func TestDoneEject(t *testing.T) {
+	cases := map[string]struct {
+		err     error
+		ejected bool
+	}{
+		"Success":     {},
+		"Unavailable": {err: status.Error(codes.Unavailable, "unavailable"), ejected: true},
+		"Internal":    {err: status.Error(codes.Internal, "internal")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := newTestStore(t, BalanceRoundRobin, "10.0.0.1")
+			ep := r.pick(testImage)
+			ep.done(tc.err)
+			stats := ep.stats()
+			if stats.Ejected != tc.ejected {
+				t.Errorf("expected ejected %t, got: %t", tc.ejected, stats.Ejected)
+			}
+			if stats.Outstanding != 0 || stats.Requests != 1 {
+				t.Errorf("unexpected stats: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestPickLeastOutstanding(t *testing.T) {
+	r := newTestStore(t, BalanceLeastOutstanding, "10.0.0.1", "10.0.0.2", "10.0.0.3")
+	// requests which are not done
+	ep1 := r.pick(testImage)
+	ep2 := r.pick(testImage)
+	ep3 := r.pick(testImage)
+	if ep1 == ep2 || ep2 == ep3 || ep1 == ep3 {
+		t.Fatalf("expected 3 different endpoints, got: %s, %s, %s", ep1.Address, ep2.Address, ep3.Address)
+	}
+	ep2.done(nil)
+	if ep := r.pick(testImage); ep != ep2 {
+		t.Errorf("expected %s, got: %s", ep2.Address, ep.Address)
+	}
+}
+
+func TestSetEndpoints(t *testing.T) {
+	r := newTestStore(t, BalanceRoundRobin, "10.0.0.1", "10.0.0.2")
+	eps := r.d[testImage].endpoints
+	kept, removed := eps[0], eps[1]
+	fake := &fakeSvcClient{}
+	removed.svcclient = fake
+
+	if err := r.SetEndpoints(testImage, []Endpoint{
+		{PodName: "pod3", Address: "10.0.0.3"},
+		{PodName: "10.0.0.1", Address: "10.0.0.1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eps = r.d[testImage].endpoints
+	if len(eps) != 2 || eps[0] != kept || eps[1].Address != "10.0.0.3" {
+		t.Fatalf("unexpected endpoints: %v", r.GetEndpoints(testImage))
+	}
+	if fake.closed != 1 {
+		t.Errorf("expected the removed endpoint to be closed once, got: %d", fake.closed)
+	}
+}
+
+func TestSetEndpointsNewPod(t *testing.T) {
+	r := newTestStore(t, BalanceRoundRobin, "10.0.0.1")
+	r.pick(testImage).done(status.Error(codes.Unavailable, "unavailable"))
+	if r.available(testImage) {
+		t.Fatal("expected the image to be unavailable")
+	}
+	if err := r.SetEndpoints(testImage, []Endpoint{{PodName: "pod2", Address: "10.0.0.1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !r.available(testImage) {
+		t.Error("expected a new pod to reset the ejection")
+	}
+}
+
+func TestSetEndpointsOutstanding(t *testing.T) {
+	r := newTestStore(t, BalanceRoundRobin, "10.0.0.1")
+	ep := r.pick(testImage)
+	fake := &fakeSvcClient{}
+	ep.svcclient = fake
+
+	// the endpoint is removed while a request uses it
+	if err := r.SetEndpoints(testImage, nil); err != nil {
+		t.Fatal(err)
+	}
+	if fake.closed != 0 {
+		t.Fatal("expected the endpoint to be closed after the outstanding request")
+	}
+	if ep := r.pick(testImage); ep != nil {
+		t.Fatalf("expected no endpoint, got: %s", ep.Address)
+	}
+	ep.done(nil)
+	if fake.closed != 1 {
+		t.Errorf("expected the endpoint to be closed once, got: %d", fake.closed)
+	}
+}
+
+func TestDeleteOutstanding(t *testing.T) {
+	r := newTestStore(t, BalanceRoundRobin, "10.0.0.1")
+	ep := r.pick(testImage)
+	fake := &fakeSvcClient{}
+	ep.svcclient = fake
+
+	r.Delete(testImage)
+	if fake.closed != 0 {
+		t.Fatal("expected the endpoint to be closed after the outstanding request")
+	}
+	ep.done(nil)
+	ep.close()
+	if fake.closed != 1 {
+		t.Errorf("expected the endpoint to be closed once, got: %d", fake.closed)
+	}
+}
@@ -17,14 +17,19 @@ limitations under the License.
 package imagestore
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"os"
-	"strings"
+	"net"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/fnrunner/fnproto/pkg/executor/execclient"
 	"github.com/fnrunner/fnproto/pkg/service/svcclient"
 	fnrunv1alpha1 "github.com/fnrunner/fnruntime/apis/fnrun/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Store interface {
@@ -34,30 +39,128 @@ type Store interface {
 	Delete(image fnrunv1alpha1.Image)
 	//SetConfigMap(image fnrunv1alpha1.Image, cm *corev1.ConfigMap) error
 	//GetConfigMap(image fnrunv1alpha1.Image) *corev1.ConfigMap
-	SetClient(image fnrunv1alpha1.Image, podName, IPaddress string) error
-	DeleteClient(image fnrunv1alpha1.Image)
+	// SetEndpoints sets the ready endpoints of the image, a client is kept
+	// per endpoint
+	SetEndpoints(image fnrunv1alpha1.Image, endpoints []Endpoint) error
+	GetEndpoints(image fnrunv1alpha1.Image) []Endpoint
+	// GetEndpointStats returns the balancing stats per endpoint of the image
+	GetEndpointStats(image fnrunv1alpha1.Image) []EndpointStats
+	// GetFnClient and GetSvcClient return a client balancing the requests
+	// over the endpoints of the image, nil if no endpoint is available
 	GetFnClient(image fnrunv1alpha1.Image) execclient.Client
 	GetSvcClient(image fnrunv1alpha1.Image) svcclient.Client
+	// WaitFnClient and WaitSvcClient wait for a client of the image to become
+	// available, they return the ctx error when the ctx is done first and a
+	// NotFound status for an image which is not in the store
+	WaitFnClient(ctx context.Context, image fnrunv1alpha1.Image) (execclient.Client, error)
+	WaitSvcClient(ctx context.Context, image fnrunv1alpha1.Image) (svcclient.Client, error)
+	// SetDigest sets the digest the image is resolved to
+	SetDigest(image fnrunv1alpha1.Image, digest string)
+	// GetDigest returns the digest of the image, empty if not resolved
+	GetDigest(image fnrunv1alpha1.Image) string
+	// GetStatus returns the serving status of the image
+	GetStatus(image fnrunv1alpha1.Image) ServingStatus
 }
 
-func New() Store {
-	return &store{
-		d: map[fnrunv1alpha1.Image]*imageCtx{},
+const waitPollInterval = time.Second
+
+// ServingStatus is the serving status of an image
+type ServingStatus int
+
+const (
+	// StatusUnknown is reported when the image is deleted
+	StatusUnknown ServingStatus = iota
+	StatusNotServing
+	// StatusServing is reported when the image has a ready endpoint
+	StatusServing
+)
+
+// StatusFn is called on the transitions of the serving status of an image.
+// It is called with the store locked and must not call the store.
+type StatusFn func(image fnrunv1alpha1.Image, status ServingStatus)
+
+type Option func(*store)
+
+// WithStatusFn reports the serving status transitions of the images
+func WithStatusFn(fn StatusFn) Option {
+	return func(r *store) {
+		r.statusFn = fn
+	}
+}
+
+// WithBalancePolicy sets how the requests are balanced over the endpoints
+// of an image, default round robin
+func WithBalancePolicy(p BalancePolicy) Option {
+	return func(r *store) {
+		if p != "" {
+			r.policy = p
+		}
+	}
+}
+
+// WithTLS enables mTLS to the function endpoints, the tls config is created
+// per endpoint with its server name. The service endpoints stay plaintext.
+func WithTLS(fn func(serverName string) *tls.Config) Option {
+	return func(r *store) {
+		r.tlsConfigFn = fn
+	}
+}
+
+// WithMaxMsgSize sets the max size of the messages to and from the
+// endpoints, the grpc defaults apply when 0
+func WithMaxMsgSize(n int) Option {
+	return func(r *store) {
+		r.maxMsgSize = n
 	}
 }
 
+func New(opts ...Option) Store {
+	r := &store{
+		d:       map[fnrunv1alpha1.Image]*imageCtx{},
+		policy:  BalanceRoundRobin,
+		changed: make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Endpoint is a ready endpoint of the service of an image
+type Endpoint struct {
+	PodName string
+	Address string
+	// ServerName is the name the certificate of the endpoint is verified
+	// with when mTLS is enabled
+	ServerName string
+	// Dialer connects to an endpoint which is not served on the network,
+	// e.g. an image running in the same process. The address only identifies
+	// the endpoint and the connection is plaintext.
+	Dialer func(ctx context.Context, address string) (net.Conn, error)
+}
+
 type store struct {
-	m sync.RWMutex
-	d map[fnrunv1alpha1.Image]*imageCtx
+	m      sync.RWMutex
+	d      map[fnrunv1alpha1.Image]*imageCtx
+	policy BalancePolicy
+	// tlsConfigFn enables mTLS to the function endpoints when set
+	tlsConfigFn func(serverName string) *tls.Config
+	maxMsgSize  int
+	statusFn    StatusFn
+	// changed is closed and replaced on every change of the images to wake
+	// up the waiters
+	changed chan struct{}
 }
 
 type imageCtx struct {
 	imageType fnrunv1alpha1.ImageKind
+	digest    string
 	//de         *fnrunv1alpha1.DigestAndEntrypoint
 	//podName    string
 	//cm         *corev1.ConfigMap
-	execclient execclient.Client
-	svcclient  svcclient.Client
+	// endpoints are sorted by address
+	endpoints []*endpoint
+	next      uint64
 }
 
 func (r *store) List() []fnrunv1alpha1.Image {
@@ -84,6 +187,8 @@ func (r *store) Create(image fnrunv1alpha1.Image) {
 	defer r.m.Unlock()
 	if _, ok := r.d[image]; !ok {
 		r.d[image] = &imageCtx{}
+		r.notify()
+		r.reportStatus(image, StatusNotServing)
 	}
 
 	// if the entry already exists we dont want to reinitialize
@@ -92,68 +197,201 @@ func (r *store) Create(image fnrunv1alpha1.Image) {
 func (r *store) Delete(image fnrunv1alpha1.Image) {
 	r.m.Lock()
 	defer r.m.Unlock()
-	delete(r.d, image)
+	if c, ok := r.d[image]; ok {
+		c.closeClients()
+	}
+	if _, ok := r.d[image]; ok {
+		delete(r.d, image)
+		r.reportStatus(image, StatusUnknown)
+	}
+	r.notify()
 }
 
-func (r *store) SetClient(image fnrunv1alpha1.Image, podName, ipAddr string) error {
+func (r *store) SetEndpoints(image fnrunv1alpha1.Image, endpoints []Endpoint) error {
 	r.m.Lock()
 	defer r.m.Unlock()
-	// TBD if we need to deal with updating IP addresses
-	address := strings.Join([]string{podName, os.Getenv("POD_NAMESPACE"), "svc.cluster.local"}, ".")
-	switch image.Kind {
-	case fnrunv1alpha1.ImageKindFunction:
-		cl, err := execclient.New(&execclient.Config{
-			Address: fmt.Sprintf("%s:%d", address, fnrunv1alpha1.FnGRPCServerPort),
-			//Address:  fmt.Sprintf("%s:%d", ipAddr, fnrunv1alpha1.FnGRPCServerPort),
-			Insecure: true,
-		})
-		if err != nil {
-			return err
+	c, ok := r.d[image]
+	if !ok {
+		return fmt.Errorf("cannot set endpoints of unknown image %s", image.Name)
+	}
+	current := map[string]*endpoint{}
+	for _, ep := range c.endpoints {
+		current[ep.Address] = ep
+	}
+	eps := make([]*endpoint, 0, len(endpoints))
+	created := []*endpoint{}
+	for _, e := range endpoints {
+		if ep, ok := current[e.Address]; ok {
+			// a new pod on the address is not affected by the ejection of
+			// the previous one
+			if ep.PodName != e.PodName {
+				ep.ejectedUntil.Store(0)
+			}
+			ep.Endpoint = e
+			eps = append(eps, ep)
+			delete(current, e.Address)
+			continue
 		}
-		r.d[image].execclient = cl
-		return nil
-	case fnrunv1alpha1.ImageKindService:
-		cl, err := svcclient.New(&svcclient.Config{
-			Address: fmt.Sprintf("%s:%d", address, fnrunv1alpha1.FnGRPCServerPort),
-			//Address:  fmt.Sprintf("%s:%d", ipAddr, fnrunv1alpha1.FnGRPCServerPort),
-			Insecure: true,
-		})
+		ep, err := r.newEndpoint(image, e)
 		if err != nil {
+			for _, ep := range created {
+				ep.close()
+			}
 			return err
 		}
-		r.d[image].svcclient = cl
+		created = append(created, ep)
+		eps = append(eps, ep)
+	}
+	// the endpoints which are no longer ready
+	for _, ep := range current {
+		ep.close()
+	}
+	sort.Slice(eps, func(i, j int) bool {
+		return eps[i].Address < eps[j].Address
+	})
+	serving := len(c.endpoints) > 0
+	c.endpoints = eps
+	r.notify()
+	if serving != (len(eps) > 0) {
+		r.reportStatus(image, servingStatus(len(eps) > 0))
+	}
+	return nil
+}
+
+func (r *store) GetEndpoints(image fnrunv1alpha1.Image) []Endpoint {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	c, ok := r.d[image]
+	if !ok {
+		return nil
+	}
+	endpoints := make([]Endpoint, 0, len(c.endpoints))
+	for _, ep := range c.endpoints {
+		endpoints = append(endpoints, ep.Endpoint)
+	}
+	return endpoints
+}
+
+func (r *store) GetEndpointStats(image fnrunv1alpha1.Image) []EndpointStats {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	c, ok := r.d[image]
+	if !ok {
+		return nil
+	}
+	stats := make([]EndpointStats, 0, len(c.endpoints))
+	for _, ep := range c.endpoints {
+		stats = append(stats, ep.stats())
+	}
+	return stats
+}
+
+func (r *store) GetFnClient(image fnrunv1alpha1.Image) execclient.Client {
+	if !r.available(image) {
+		return nil
+	}
+	return &balancedExecClient{store: r, image: image}
+}
+
+func (r *store) GetSvcClient(image fnrunv1alpha1.Image) svcclient.Client {
+	if !r.available(image) {
 		return nil
-	default:
-		return fmt.Errorf("cannot set client with unknown image kind, got: %s", image.Kind)
 	}
+	return &balancedSvcClient{store: r, image: image}
+}
+
+func (r *store) WaitFnClient(ctx context.Context, image fnrunv1alpha1.Image) (execclient.Client, error) {
+	if err := r.wait(ctx, image); err != nil {
+		return nil, err
+	}
+	return &balancedExecClient{store: r, image: image}, nil
+}
+
+func (r *store) WaitSvcClient(ctx context.Context, image fnrunv1alpha1.Image) (svcclient.Client, error) {
+	if err := r.wait(ctx, image); err != nil {
+		return nil, err
+	}
+	return &balancedSvcClient{store: r, image: image}, nil
+}
+
+// wait waits for an available endpoint of the image, it fails fast with
+// NotFound for an image which is not in the store
+func (r *store) wait(ctx context.Context, image fnrunv1alpha1.Image) error {
+	for {
+		r.m.RLock()
+		changed := r.changed
+		_, ok := r.d[image]
+		r.m.RUnlock()
+		if !ok {
+			return status.Errorf(codes.NotFound, "unknown image %s", image.Name)
+		}
+		if r.available(image) {
+			return nil
+		}
+		// ejected endpoints become available without a change
+		t := time.NewTimer(waitPollInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-changed:
+		case <-t.C:
+		}
+		t.Stop()
+	}
+}
+
+func (r *store) reportStatus(image fnrunv1alpha1.Image, status ServingStatus) {
+	if r.statusFn != nil {
+		r.statusFn(image, status)
+	}
+}
+
+func servingStatus(serving bool) ServingStatus {
+	if serving {
+		return StatusServing
+	}
+	return StatusNotServing
+}
+
+// notify wakes up the waiters, the caller holds the write lock
+func (r *store) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
 }
 
-func (r *store) DeleteClient(image fnrunv1alpha1.Image) {
+func (r *imageCtx) closeClients() {
+	for _, ep := range r.endpoints {
+		ep.close()
+	}
+	r.endpoints = nil
+}
+
+func (r *store) SetDigest(image fnrunv1alpha1.Image, digest string) {
 	r.m.Lock()
 	defer r.m.Unlock()
 	if _, ok := r.d[image]; !ok {
 		return
 	}
-	r.d[image].execclient = nil
-	r.d[image].svcclient = nil
+	r.d[image].digest = digest
 }
 
-func (r *store) GetFnClient(image fnrunv1alpha1.Image) execclient.Client {
+func (r *store) GetDigest(image fnrunv1alpha1.Image) string {
 	r.m.RLock()
 	defer r.m.RUnlock()
 	c, ok := r.d[image]
 	if !ok {
-		return nil
+		return ""
 	}
-	return c.execclient
+	return c.digest
 }
 
-func (r *store) GetSvcClient(image fnrunv1alpha1.Image) svcclient.Client {
+func (r *store) GetStatus(image fnrunv1alpha1.Image) ServingStatus {
 	r.m.RLock()
 	defer r.m.RUnlock()
 	c, ok := r.d[image]
 	if !ok {
-		return nil
+		return StatusUnknown
 	}
-	return c.svcclient
+	return servingStatus(len(c.endpoints) > 0)
 }